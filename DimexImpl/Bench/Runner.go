@@ -0,0 +1,100 @@
+/*
+  Executa a carga de um processo sobre um modulo de exclusao mutua qualquer (enter/exit).
+  A latencia de aquisicao vai da chegada do pedido ate a liberacao da SC: no laco aberto
+  inclui a espera na fila local, como veria um cliente que chega naquele instante.
+  Depois de Duration nenhum pedido novo e' feito; no laco aberto os que ficaram na fila
+  sao contados em Pending. O modulo continua no ar para responder aos outros processos.
+*/
+
+package Bench
+
+import (
+	"SD/Units"
+	"math/rand"
+	"time"
+)
+
+// Mutex e' o que o benchmark usa do modulo de exclusao mutua
+type Mutex struct {
+	Enter func() bool // pede a SC e espera a liberacao; false se desistiu (processo encerrando)
+	Exit  func()
+	Sent  func() uint64 // mensagens enviadas pelo processo ate agora (PP2PLink.Metrics)
+}
+
+func Run(w Workload, id int, mx Mutex) Result {
+	seed := w.Seed + int64(id)
+	if w.Seed == 0 {
+		seed = time.Now().UnixNano() + int64(id)
+	}
+	r := rand.New(rand.NewSource(seed))
+	weight := w.Weight(id)
+	res := Result{Id: id, Arrival: w.Arrival, Weight: weight}
+
+	sent0 := mx.Sent()
+	start := time.Now()
+	deadline := start.Add(time.Duration(w.Duration))
+
+	aborted := false
+	enter := func(arrival time.Time) bool {
+		if !mx.Enter() {
+			aborted = true
+			return false
+		}
+		res.Latencies = append(res.Latencies, time.Since(arrival))
+		time.Sleep(w.Hold.Sample(r))
+		mx.Exit()
+		res.Entries++
+		return true
+	}
+
+	if w.Arrival == Poisson {
+		arrivals, quit := make(chan time.Time, w.Backlog), make(chan struct{})
+		go generate(arrivals, quit, w.Rate*weight, deadline, seed, &res.Dropped)
+		for arrival := range arrivals { // ate o gerador fechar: depois disso Dropped pode ser lido
+			if aborted || time.Now().After(deadline) {
+				res.Pending++
+				continue
+			}
+			if !enter(arrival) {
+				close(quit)
+			}
+		}
+	} else {
+		think := w.Think.Scale(1 / weight)
+		for time.Now().Before(deadline) {
+			if !enter(time.Now()) {
+				break
+			}
+			time.Sleep(think.Sample(r))
+		}
+	}
+
+	res.Elapsed = Units.Duration(time.Since(start))
+	res.Messages = mx.Sent() - sent0
+	res.Aborted = aborted
+	return res
+}
+
+// generate produz chegadas de Poisson (intervalos exponenciais) ate deadline.
+// O instante e' o planejado, nao o do envio no canal: atrasos do gerador nao escondem latencia.
+func generate(arrivals chan<- time.Time, quit <-chan struct{}, rate float64, deadline time.Time, seed int64, dropped *int) {
+	defer close(arrivals)
+	r := rand.New(rand.NewSource(seed ^ 0x5bd1e995))
+	next := time.Now()
+	for {
+		next = next.Add(time.Duration(r.ExpFloat64() / rate * float64(time.Second)))
+		if next.After(deadline) {
+			return
+		}
+		select {
+		case <-quit:
+			return
+		case <-time.After(time.Until(next)):
+		}
+		select {
+		case arrivals <- next:
+		default:
+			*dropped++ // fila local cheia
+		}
+	}
+}
@@ -0,0 +1,166 @@
+/*
+  Resultado do benchmark de cada processo (gravado em JSON) e resumo do cluster:
+    - vazao: entradas na SC por segundo (soma dos processos / maior tempo de execucao)
+    - latencia de aquisicao: media e percentis de todas as entradas juntas
+    - mensagens por entrada: mensagens enviadas pelo PP2PLink / entradas na SC
+    - justica: indice de Jain das entradas de cada processo divididas pelo seu peso (skew);
+      1 = cada processo foi atendido na proporcao da carga que gerou, 1/n = um so foi atendido
+*/
+
+package Bench
+
+import (
+	"SD/Units"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"time"
+)
+
+type Result struct {
+	Id        int             `json:"id"`
+	Arrival   string          `json:"arrival"`
+	Weight    float64         `json:"weight"`
+	Entries   int             `json:"entries"`
+	Elapsed   Units.Duration  `json:"elapsed"`
+	Messages  uint64          `json:"messages"`
+	Dropped   int             `json:"dropped,omitempty"` // poisson: chegadas descartadas com a fila cheia
+	Pending   int             `json:"pending,omitempty"` // poisson: pedidos na fila no fim
+	Aborted   bool            `json:"aborted,omitempty"` // interrompido antes de Duration
+	Latencies []time.Duration `json:"latencies"`         // em ns, na ordem das entradas
+}
+
+func (r Result) WriteFile(path string) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+func ReadResult(path string) (Result, error) {
+	var r Result
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return r, err
+	}
+	if err := json.Unmarshal(data, &r); err != nil {
+		return r, fmt.Errorf("%s: %w", path, err)
+	}
+	return r, nil
+}
+
+type Summary struct {
+	Processes        int
+	Entries          int
+	Elapsed          time.Duration
+	Throughput       float64 // entradas por segundo
+	Mean             time.Duration
+	P50, P90, P99    time.Duration
+	Max              time.Duration
+	Messages         uint64
+	MessagesPerEntry float64
+	Fairness         float64
+	Dropped, Pending int
+}
+
+func Summarize(results []Result) Summary {
+	s := Summary{Processes: len(results)}
+	var all []time.Duration
+	var total time.Duration
+	shares := make([]float64, 0, len(results))
+	for _, r := range results {
+		s.Entries += r.Entries
+		s.Messages += r.Messages
+		s.Dropped += r.Dropped
+		s.Pending += r.Pending
+		if time.Duration(r.Elapsed) > s.Elapsed {
+			s.Elapsed = time.Duration(r.Elapsed)
+		}
+		for _, l := range r.Latencies {
+			total += l
+		}
+		all = append(all, r.Latencies...)
+		weight := r.Weight
+		if weight <= 0 {
+			weight = 1
+		}
+		shares = append(shares, float64(r.Entries)/weight)
+	}
+	if s.Elapsed > 0 {
+		s.Throughput = float64(s.Entries) / s.Elapsed.Seconds()
+	}
+	if s.Entries > 0 {
+		s.MessagesPerEntry = float64(s.Messages) / float64(s.Entries)
+	}
+	if len(all) > 0 {
+		sort.Slice(all, func(i, j int) bool { return all[i] < all[j] })
+		s.Mean = total / time.Duration(len(all))
+		s.P50, s.P90, s.P99 = Percentile(all, 50), Percentile(all, 90), Percentile(all, 99)
+		s.Max = all[len(all)-1]
+	}
+	s.Fairness = JainFairness(shares)
+	return s
+}
+
+// Percentile pelo metodo do posto mais proximo; sorted deve estar em ordem crescente
+func Percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p * float64(len(sorted)) / 100)) // menor posto com p% dos valores ate ele
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
+
+// JainFairness: (soma x)^2 / (n * soma x^2); 0 se nao ha dados
+func JainFairness(x []float64) float64 {
+	var sum, squares float64
+	for _, v := range x {
+		sum += v
+		squares += v * v
+	}
+	if squares == 0 {
+		return 0
+	}
+	return sum * sum / (float64(len(x)) * squares)
+}
+
+// Report mostra uma linha por processo e o resumo do cluster
+func Report(w io.Writer, results []Result) Summary {
+	sort.Slice(results, func(i, j int) bool { return results[i].Id < results[j].Id })
+	fmt.Fprintln(w, "proc  peso   entradas   entr/s     lat media  lat p99    msgs/entr")
+	for _, r := range results {
+		one := Summarize([]Result{r})
+		fmt.Fprintf(w, "%-5d %-6.3f %-10d %-10.1f %-10v %-10v %.2f\n", r.Id, r.Weight, r.Entries,
+			one.Throughput, round(one.Mean), round(one.P99), one.MessagesPerEntry)
+	}
+	s := Summarize(results)
+	fmt.Fprintf(w, "vazao: %.1f entradas/s (%d entradas em %v)\n", s.Throughput, s.Entries, round(s.Elapsed))
+	fmt.Fprintf(w, "latencia de aquisicao: media %v  p50 %v  p90 %v  p99 %v  max %v\n",
+		round(s.Mean), round(s.P50), round(s.P90), round(s.P99), round(s.Max))
+	fmt.Fprintf(w, "mensagens por entrada: %.2f (%d mensagens)\n", s.MessagesPerEntry, s.Messages)
+	fmt.Fprintf(w, "justica (Jain): %.3f\n", s.Fairness)
+	if s.Dropped > 0 || s.Pending > 0 {
+		fmt.Fprintf(w, "pedidos descartados (fila cheia): %d, na fila no fim: %d\n", s.Dropped, s.Pending)
+	}
+	return s
+}
+
+func round(d time.Duration) time.Duration {
+	switch {
+	case d >= time.Second:
+		return d.Round(time.Millisecond)
+	case d >= time.Millisecond:
+		return d.Round(10 * time.Microsecond)
+	}
+	return d.Round(time.Microsecond)
+}
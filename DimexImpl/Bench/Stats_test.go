@@ -0,0 +1,91 @@
+package Bench
+
+import (
+	"SD/Units"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestPercentile(t *testing.T) {
+	ten := []time.Duration{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	cases := []struct {
+		name   string
+		sorted []time.Duration
+		p      float64
+		want   time.Duration
+	}{
+		{"vazio", nil, 50, 0},
+		{"um valor, p0", []time.Duration{7}, 0, 7},
+		{"um valor, p50", []time.Duration{7}, 50, 7},
+		{"um valor, p100", []time.Duration{7}, 100, 7},
+		{"p0 e' o menor", ten, 0, 1},
+		{"p100 e' o maior", ten, 100, 10},
+		{"p50", ten, 50, 5},
+		{"p90", ten, 90, 9},
+		{"p99 arredonda para cima", ten, 99, 10},
+		{"p14 arredonda para cima", ten, 14, 2},
+		{"p7 de 100 valores", seq(100), 7, 7},
+	}
+	for _, c := range cases {
+		if got := Percentile(c.sorted, c.p); got != c.want {
+			t.Errorf("%s: %v, esperado %v", c.name, got, c.want)
+		}
+	}
+}
+
+func seq(n int) []time.Duration {
+	s := make([]time.Duration, n)
+	for i := range s {
+		s[i] = time.Duration(i + 1)
+	}
+	return s
+}
+
+func TestJainFairness(t *testing.T) {
+	cases := []struct {
+		name string
+		x    []float64
+		want float64
+	}{
+		{"sem dados", nil, 0},
+		{"ninguem atendido", []float64{0, 0, 0}, 0},
+		{"todos iguais", []float64{4, 4, 4, 4}, 1},
+		{"um so atendido entre 4", []float64{9, 0, 0, 0}, 0.25},
+		{"um so atendido entre 2", []float64{0, 3}, 0.5},
+		{"um processo", []float64{5}, 1},
+	}
+	for _, c := range cases {
+		if got := JainFairness(c.x); math.Abs(got-c.want) > 1e-9 {
+			t.Errorf("%s: %v, esperado %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestSummarizeWeights(t *testing.T) {
+	cases := []struct {
+		name     string
+		results  []Result
+		fairness float64
+	}{
+		{"peso proporcional as entradas", []Result{{Weight: 2, Entries: 20}, {Weight: 1, Entries: 10}}, 1},
+		{"peso 0 vale 1", []Result{{Weight: 0, Entries: 10}, {Weight: 1, Entries: 10}}, 1},
+		{"peso negativo vale 1", []Result{{Weight: -3, Entries: 10}, {Weight: 1, Entries: 10}}, 1},
+		{"peso 0 nao atendido", []Result{{Weight: 0, Entries: 0}, {Weight: 1, Entries: 10}}, 0.5},
+	}
+	for _, c := range cases {
+		s := Summarize(c.results)
+		if math.Abs(s.Fairness-c.fairness) > 1e-9 || math.IsNaN(s.Fairness) || math.IsInf(s.Fairness, 0) {
+			t.Errorf("%s: justica %v, esperada %v", c.name, s.Fairness, c.fairness)
+		}
+	}
+
+	s := Summarize([]Result{
+		{Weight: 1, Entries: 2, Elapsed: Units.Duration(2 * time.Second), Messages: 8, Latencies: []time.Duration{1 * time.Millisecond, 3 * time.Millisecond}},
+		{Weight: 1, Entries: 2, Elapsed: Units.Duration(time.Second), Messages: 4, Latencies: []time.Duration{2 * time.Millisecond, 4 * time.Millisecond}},
+	})
+	if s.Entries != 4 || s.Elapsed != 2*time.Second || s.Throughput != 2 || s.MessagesPerEntry != 3 ||
+		s.Mean != 2500*time.Microsecond || s.P50 != 2*time.Millisecond || s.Max != 4*time.Millisecond {
+		t.Fatalf("resumo %+v", s)
+	}
+}
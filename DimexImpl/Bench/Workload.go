@@ -0,0 +1,180 @@
+/*
+  Carga do modo benchmark do DIMEX.
+    - chegada dos pedidos: "closed" (laco fechado: pede, entra, sai, pensa, pede de novo)
+      ou "poisson" (laco aberto: pedidos chegam a uma taxa, independente de quando sao atendidos;
+      os que chegam com a SC ainda pedida esperam numa fila local)
+    - tempos (SC e think) seguem uma distribuicao, escrita como texto:
+        "2ms" ou "const:2ms"    sempre 2ms
+        "uniform:1ms,3ms"       uniforme entre 1ms e 3ms
+        "exp:2ms"               exponencial com media 2ms
+    - skew: o processo i tem peso 1/(i+1)^skew (0: todos iguais). No laco aberto a taxa e'
+      multiplicada pelo peso, no laco fechado o think e' dividido por ele (think 0 nao tem skew)
+*/
+
+package Bench
+
+import (
+	"SD/Units"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+const (
+	Closed  = "closed"
+	Poisson = "poisson"
+)
+
+type Workload struct {
+	Arrival  string         `json:"arrival"`  // Closed ou Poisson
+	Rate     float64        `json:"rate"`     // poisson: pedidos por segundo do processo 0
+	Hold     Distribution   `json:"hold"`     // tempo dentro da SC
+	Think    Distribution   `json:"think"`    // closed: tempo entre sair da SC e o proximo pedido
+	Skew     float64        `json:"skew"`     // peso do processo i: 1/(i+1)^skew
+	Duration Units.Duration `json:"duration"` // tempo em que novos pedidos sao feitos
+	Seed     int64          `json:"seed"`     // 0: aleatoria; senao cada processo usa Seed+id
+	Backlog  int            `json:"backlog"`  // poisson: pedidos que podem esperar na fila local
+}
+
+func DefaultWorkload() Workload {
+	return Workload{
+		Arrival:  Closed,
+		Rate:     100,
+		Duration: Units.Duration(10 * time.Second),
+		Backlog:  10000}
+}
+
+func (w Workload) Validate() error {
+	switch {
+	case w.Arrival != Closed && w.Arrival != Poisson:
+		return fmt.Errorf("arrival deve ser %s ou %s (%q)", Closed, Poisson, w.Arrival)
+	case w.Arrival == Poisson && w.Rate <= 0:
+		return fmt.Errorf("rate deve ser positiva no laco aberto (%v)", w.Rate)
+	case w.Arrival == Poisson && w.Backlog < 1:
+		return fmt.Errorf("backlog deve ser pelo menos 1 (%d)", w.Backlog)
+	case w.Skew < 0:
+		return fmt.Errorf("skew nao pode ser negativo (%v)", w.Skew)
+	case w.Duration <= 0:
+		return fmt.Errorf("duration do benchmark deve ser positiva (%v)", time.Duration(w.Duration))
+	}
+	return nil
+}
+
+// Weight e' a fracao da carga do processo 0 que o processo id gera
+func (w Workload) Weight(id int) float64 {
+	return 1 / math.Pow(float64(id+1), w.Skew)
+}
+
+func (w Workload) String() string {
+	s := fmt.Sprintf("arrival=%s hold=%v", w.Arrival, w.Hold)
+	if w.Arrival == Poisson {
+		s += fmt.Sprintf(" rate=%v/s", w.Rate)
+	} else {
+		s += fmt.Sprintf(" think=%v", w.Think)
+	}
+	return s + fmt.Sprintf(" skew=%v duration=%v", w.Skew, time.Duration(w.Duration))
+}
+
+// ------------------------------------------------------------------------------------
+// ------- distribuicoes de tempo
+// ------------------------------------------------------------------------------------
+
+type Distribution struct {
+	Kind string // "const", "uniform" ou "exp"
+	A, B time.Duration
+}
+
+func ParseDistribution(s string) (Distribution, error) {
+	kind, params := "const", strings.TrimSpace(s)
+	if i := strings.Index(params, ":"); i >= 0 {
+		kind, params = params[:i], params[i+1:]
+	}
+	parse := func(v string) (time.Duration, error) {
+		d, err := time.ParseDuration(strings.TrimSpace(v))
+		if err == nil && d < 0 {
+			err = fmt.Errorf("tempo negativo")
+		}
+		return d, err
+	}
+	var d Distribution
+	var err error
+	switch kind {
+	case "const", "exp":
+		d.Kind = kind
+		d.A, err = parse(params)
+	case "uniform":
+		parts := strings.Split(params, ",")
+		if len(parts) != 2 {
+			return d, fmt.Errorf("distribuicao %q: use uniform:<min>,<max>", s)
+		}
+		d.Kind = kind
+		if d.A, err = parse(parts[0]); err == nil {
+			d.B, err = parse(parts[1])
+		}
+		if err == nil && d.B < d.A {
+			err = fmt.Errorf("max menor que min")
+		}
+	default:
+		return d, fmt.Errorf("distribuicao %q: tipo %q desconhecido (const, uniform ou exp)", s, kind)
+	}
+	if err != nil {
+		return d, fmt.Errorf("distribuicao %q: %v", s, err)
+	}
+	return d, nil
+}
+
+func (d Distribution) String() string {
+	switch d.Kind {
+	case "uniform":
+		return "uniform:" + d.A.String() + "," + d.B.String()
+	case "exp":
+		return "exp:" + d.A.String()
+	}
+	return d.A.String()
+}
+
+func (d Distribution) Mean() time.Duration {
+	if d.Kind == "uniform" {
+		return (d.A + d.B) / 2
+	}
+	return d.A
+}
+
+// Scale multiplica os tempos por f (usado pelo skew)
+func (d Distribution) Scale(f float64) Distribution {
+	return Distribution{Kind: d.Kind, A: time.Duration(float64(d.A) * f), B: time.Duration(float64(d.B) * f)}
+}
+
+func (d Distribution) Sample(r *rand.Rand) time.Duration {
+	switch d.Kind {
+	case "uniform":
+		return d.A + time.Duration(r.Int63n(int64(d.B-d.A)+1))
+	case "exp":
+		return time.Duration(r.ExpFloat64() * float64(d.A))
+	}
+	return d.A
+}
+
+// Set e String permitem usar Distribution como flag (flag.Var)
+func (d *Distribution) Set(s string) error {
+	v, err := ParseDistribution(s)
+	if err == nil {
+		*d = v
+	}
+	return err
+}
+
+func (d Distribution) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Distribution) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	return d.Set(s)
+}
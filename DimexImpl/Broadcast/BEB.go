@@ -0,0 +1,66 @@
+/*
+  Best-effort broadcast: envia a mensagem a cada processo pelo PP2PLink.
+  Se quem envia nao falha, todos os corretos entregam (o PP2PLink nao perde nem duplica);
+  se falha no meio do envio, alguns podem entregar e outros nao.
+*/
+
+package Broadcast
+
+import (
+	PP2PLink "SD/PP2PLink"
+)
+
+type BEB_Module struct {
+	Req       chan Broadcast_Req_Message
+	Ind       chan Broadcast_Ind_Message
+	addresses []string
+	id        int
+	dbg       bool
+	Pp2plink  *PP2PLink.PP2PLink
+}
+
+func NewBEB(_addresses []string, _id int, _dbg bool, _link *PP2PLink.PP2PLink) *BEB_Module {
+	beb := &BEB_Module{
+		Req:       make(chan Broadcast_Req_Message),
+		Ind:       make(chan Broadcast_Ind_Message, indBuffer),
+		addresses: _addresses,
+		id:        _id,
+		dbg:       _dbg,
+		Pp2plink:  _link}
+	beb.Start()
+	beb.outDbg("Init BEB!")
+	return beb
+}
+
+func (module *BEB_Module) Start() {
+	go func() {
+		for {
+			select {
+			case req := <-module.Req:
+				module.outDbg("broadcast: " + req.Message)
+				SendToOthers(module.Pp2plink, module.addresses, module.id, req.Message)
+				module.Ind <- Broadcast_Ind_Message{From: module.id, Message: req.Message}
+			case msg := <-module.Pp2plink.Ind:
+				module.Ind <- Broadcast_Ind_Message{From: indexOf(module.addresses, msg.From), Message: msg.Message}
+			}
+		}
+	}()
+}
+
+// SendToOthers envia message a todos os processos menos _id, num unico lote do PP2PLink
+func SendToOthers(link *PP2PLink.PP2PLink, _addresses []string, _id int, message string) {
+	batch := make([]PP2PLink.PP2PLink_Req_Message, 0, len(_addresses))
+	for i, address := range _addresses {
+		if i == _id {
+			continue // nao envia a si mesmo
+		}
+		batch = append(batch, PP2PLink.PP2PLink_Req_Message{
+			To:      address,
+			Message: message})
+	}
+	link.ReqBatch <- batch
+}
+
+func (module *BEB_Module) outDbg(s string) {
+	outDbg(module.dbg, "BEB", s)
+}
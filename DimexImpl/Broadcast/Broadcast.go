@@ -0,0 +1,99 @@
+/*
+  Primitivas de comunicacao em grupo sobre o PP2PLink, tal como definidas em:
+    Introduction to Reliable and Secure Distributed Programming
+    Christian Cachin, Rachid Gerraoui, Luis Rodrigues
+  Cada modulo segue o padrao Req/Ind do DIMEX e do PP2PLink:
+    BEB_Module    best-effort broadcast (sobre PP2PLink)               BEB.go
+    RB_Module     reliable broadcast, versao "eager" (sobre BEB)       RB.go
+    FIFO_Module   FIFO reliable broadcast (sobre RB)                   FIFO.go
+    Causal_Module causal reliable broadcast com relogio vetorial (RB)  Causal.go
+    TotalOrder_Module total order broadcast com Lamport e acks (BEB)   TotalOrder.go
+  Todos usam Broadcast_Req_Message e Broadcast_Ind_Message; From e' o indice do
+  processo que fez o broadcast no vetor de enderecos. O proprio processo tambem
+  recebe o que envia (entrega local, sem passar pela rede).
+  O modulo de baixo e' exclusivo: quem cria um BEB_Module sobre um PP2PLink nao
+  deve ler o Ind desse link. Para so enviar a todos os outros sem um modulo
+  (como o DIMEX faz com reqEntry e msgSnapshot) use SendToOthers.
+*/
+
+package Broadcast
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type Broadcast_Req_Message struct {
+	Message string
+}
+
+type Broadcast_Ind_Message struct {
+	From    int // indice de quem fez o broadcast (-1 se o endereco nao e' conhecido)
+	Message string
+}
+
+const indBuffer = 100 // entregas locais nao bloqueiam quem faz broadcast
+
+func outDbg(dbg bool, module string, s string) {
+	if dbg {
+		fmt.Println(". . . . . . . . . . . . [ " + module + " : " + s + " ]")
+	}
+}
+
+// forward repassa de in para out em ordem, sem bloquear quem envia em in (fila sem limite).
+// Evita que um modulo fique preso enviando para o modulo de baixo enquanto este
+// esta preso entregando para ele.
+func forward(in <-chan Broadcast_Req_Message, out chan<- Broadcast_Req_Message) {
+	var queue []Broadcast_Req_Message
+	for {
+		var send chan<- Broadcast_Req_Message
+		var next Broadcast_Req_Message
+		if len(queue) > 0 {
+			send = out
+			next = queue[0]
+		}
+		select {
+		case m := <-in:
+			queue = append(queue, m)
+		case send <- next:
+			queue = queue[1:]
+		}
+	}
+}
+
+func indexOf(addresses []string, address string) int {
+	for i, a := range addresses {
+		if a == address {
+			return i
+		}
+	}
+	return -1
+}
+
+// cabecalhos das camadas: campos numericos separados por '|' antes da mensagem
+func encodeHeader(fields []int, message string) string {
+	var b strings.Builder
+	for _, f := range fields {
+		b.WriteString(strconv.Itoa(f))
+		b.WriteByte('|')
+	}
+	b.WriteString(message)
+	return b.String()
+}
+
+func decodeHeader(s string, n int) ([]int, string, error) {
+	parts := strings.SplitN(s, "|", n+1)
+	if len(parts) != n+1 {
+		return nil, "", fmt.Errorf("cabecalho invalido: %q", s)
+	}
+	fields := make([]int, n)
+	for i := 0; i < n; i++ {
+		v, err := strconv.Atoi(parts[i])
+		if err != nil {
+			return nil, "", fmt.Errorf("cabecalho invalido: %q", s)
+		}
+		fields[i] = v
+	}
+	return fields, parts[n], nil
+}
@@ -0,0 +1,102 @@
+/*
+  Causal reliable broadcast com espera (Cachin et al., algoritmo 3.15): uma mensagem
+  so e' entregue depois de todas as que o remetente ja tinha entregue (ou enviado)
+  quando fez o broadcast. Cada mensagem leva o relogio vetorial W do remetente:
+  W[i] = mensagens de i que o remetente ja tinha entregue (W[remetente] = seu seq).
+  A mensagem espera em pending ate W <= V, o vetor de entregues local.
+  Formato sobre o RB:
+     <W[0]>|<W[1]>|...|<W[N-1]>|<mensagem>
+*/
+
+package Broadcast
+
+import "strconv"
+
+type causalMsg struct {
+	from    int
+	w       []int
+	payload string
+}
+
+type Causal_Module struct {
+	Req     chan Broadcast_Req_Message
+	Ind     chan Broadcast_Ind_Message
+	id      int
+	dbg     bool
+	lsn     int   // broadcasts feitos por este processo
+	v       []int // por processo: mensagens causais ja entregues
+	pending []causalMsg
+	out     chan Broadcast_Req_Message
+	Rb      *RB_Module
+}
+
+func NewCausal(_addresses []string, _id int, _dbg bool, _rb *RB_Module) *Causal_Module {
+	crb := &Causal_Module{
+		Req: make(chan Broadcast_Req_Message),
+		Ind: make(chan Broadcast_Ind_Message, indBuffer),
+		id:  _id,
+		dbg: _dbg,
+		v:   make([]int, len(_addresses)),
+		out: make(chan Broadcast_Req_Message),
+		Rb:  _rb}
+	go forward(crb.out, crb.Rb.Req)
+	crb.Start()
+	crb.outDbg("Init Causal!")
+	return crb
+}
+
+func (module *Causal_Module) Start() {
+	go func() {
+		for {
+			select {
+			case req := <-module.Req:
+				w := make([]int, len(module.v))
+				copy(w, module.v)
+				w[module.id] = module.lsn
+				module.lsn++
+				message := encodeHeader(w, req.Message)
+				module.out <- Broadcast_Req_Message{Message: message}
+			case msg := <-module.Rb.Ind:
+				module.handleRbDeliver(msg)
+			}
+		}
+	}()
+}
+
+func (module *Causal_Module) handleRbDeliver(msg Broadcast_Ind_Message) {
+	w, payload, err := decodeHeader(msg.Message, len(module.v))
+	if err != nil || msg.From < 0 || msg.From >= len(module.v) {
+		module.outDbg("descartada: " + msg.Message)
+		return
+	}
+	module.pending = append(module.pending, causalMsg{from: msg.From, w: w, payload: payload})
+	// entrega enquanto houver mensagem com todas as dependencias entregues
+	for delivered := true; delivered; {
+		delivered = false
+		for i, m := range module.pending {
+			if !module.ready(m.w) {
+				continue
+			}
+			module.pending = append(module.pending[:i], module.pending[i+1:]...)
+			module.v[m.from]++
+			module.outDbg("entrega de " + strconv.Itoa(m.from) + ": " + m.payload)
+			module.Ind <- Broadcast_Ind_Message{From: m.from, Message: m.payload}
+			delivered = true
+			break
+		}
+	}
+}
+
+// ready indica se W <= V
+func (module *Causal_Module) ready(w []int) bool {
+	for i := range w {
+		if w[i] > module.v[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (module *Causal_Module) outDbg(s string) {
+	outDbg(module.dbg, "Causal", s)
+}
@@ -0,0 +1,64 @@
+package Broadcast
+
+import (
+	"testing"
+	"time"
+)
+
+// p1 difunde m2 depois de entregar m de p0. As duas mensagens, capturadas na rede, chegam
+// ao processo 2 na pior ordem (m2 antes de m): mesmo assim m e' entregue antes de m2
+func TestCausalDeliversAfterDependency(t *testing.T) {
+	addrs := unixAddresses(t, 3)
+	links := newLinks(t, addrs)
+	causals := make([]*Causal_Module, 2)
+	for i := range causals {
+		causals[i] = NewCausal(addrs, i, false, NewRB(addrs, i, false, NewBEB(addrs, i, false, links[i])))
+	}
+
+	causals[0].Req <- Broadcast_Req_Message{Message: "m"}
+	for {
+		msg := receive(t, causals[1].Ind)
+		if msg.From == 0 && msg.Message == "m" {
+			break
+		}
+	}
+	causals[1].Req <- Broadcast_Req_Message{Message: "m2"}
+
+	// o que chega ao processo 2 pelo RB: <origem>|<seq>|<cabecalho causal e mensagem>
+	captured := make(map[int]string) // origem -> mensagem causal
+	for len(captured) < 2 {
+		select {
+		case msg := <-links[2].Ind:
+			fields, payload, err := decodeHeader(msg.Message, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			captured[fields[0]] = payload
+		case <-time.After(10 * time.Second):
+			t.Fatalf("processo 2 recebeu so %d mensagens", len(captured))
+		}
+	}
+
+	rb := &RB_Module{Req: make(chan Broadcast_Req_Message), Ind: make(chan Broadcast_Ind_Message, indBuffer)}
+	causal := NewCausal(addrs, 2, false, rb)
+	rb.Ind <- Broadcast_Ind_Message{From: 1, Message: captured[1]}
+	rb.Ind <- Broadcast_Ind_Message{From: 0, Message: captured[0]}
+	if msg := receive(t, causal.Ind); msg.From != 0 || msg.Message != "m" {
+		t.Fatalf("primeira entrega: %+v, esperado m de 0", msg)
+	}
+	if msg := receive(t, causal.Ind); msg.From != 1 || msg.Message != "m2" {
+		t.Fatalf("segunda entrega: %+v, esperado m2 de 1", msg)
+	}
+}
+
+func receive(t *testing.T, ind <-chan Broadcast_Ind_Message) Broadcast_Ind_Message {
+	t.Helper()
+	select {
+	case msg := <-ind:
+		return msg
+	case <-time.After(10 * time.Second):
+		t.Fatal("nenhuma entrega")
+	}
+	return Broadcast_Ind_Message{}
+}
+
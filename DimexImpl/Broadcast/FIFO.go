@@ -0,0 +1,78 @@
+/*
+  FIFO reliable broadcast (Cachin et al., algoritmo 3.12): mensagens de um mesmo
+  remetente sao entregues na ordem em que ele fez o broadcast. Cada mensagem leva
+  o numero de sequencia do remetente; as adiantadas esperam em pending.
+  Formato sobre o RB:
+     <seq do remetente>|<mensagem>
+*/
+
+package Broadcast
+
+type FIFO_Module struct {
+	Req     chan Broadcast_Req_Message
+	Ind     chan Broadcast_Ind_Message
+	id      int
+	dbg     bool
+	lsn     int              // proximo seq deste processo
+	next    []int            // por remetente: proximo seq a entregar
+	pending []map[int]string // por remetente: seq -> mensagem recebida fora de ordem
+	out     chan Broadcast_Req_Message
+	Rb      *RB_Module
+}
+
+func NewFIFO(_addresses []string, _id int, _dbg bool, _rb *RB_Module) *FIFO_Module {
+	fifo := &FIFO_Module{
+		Req:     make(chan Broadcast_Req_Message),
+		Ind:     make(chan Broadcast_Ind_Message, indBuffer),
+		id:      _id,
+		dbg:     _dbg,
+		next:    make([]int, len(_addresses)),
+		pending: make([]map[int]string, len(_addresses)),
+		out:     make(chan Broadcast_Req_Message),
+		Rb:      _rb}
+	go forward(fifo.out, fifo.Rb.Req)
+	for i := range fifo.pending {
+		fifo.pending[i] = make(map[int]string)
+	}
+	fifo.Start()
+	fifo.outDbg("Init FIFO!")
+	return fifo
+}
+
+func (module *FIFO_Module) Start() {
+	go func() {
+		for {
+			select {
+			case req := <-module.Req:
+				message := encodeHeader([]int{module.lsn}, req.Message)
+				module.lsn++
+				module.out <- Broadcast_Req_Message{Message: message}
+			case msg := <-module.Rb.Ind:
+				module.handleRbDeliver(msg)
+			}
+		}
+	}()
+}
+
+func (module *FIFO_Module) handleRbDeliver(msg Broadcast_Ind_Message) {
+	fields, payload, err := decodeHeader(msg.Message, 1)
+	if err != nil || msg.From < 0 || msg.From >= len(module.next) {
+		module.outDbg("descartada: " + msg.Message)
+		return
+	}
+	from := msg.From
+	module.pending[from][fields[0]] = payload
+	for {
+		payload, ok := module.pending[from][module.next[from]]
+		if !ok {
+			break
+		}
+		delete(module.pending[from], module.next[from])
+		module.next[from]++
+		module.Ind <- Broadcast_Ind_Message{From: from, Message: payload}
+	}
+}
+
+func (module *FIFO_Module) outDbg(s string) {
+	outDbg(module.dbg, "FIFO", s)
+}
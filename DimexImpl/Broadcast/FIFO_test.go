@@ -0,0 +1,61 @@
+package Broadcast
+
+import (
+	PP2PLink "SD/PP2PLink"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// rajadas de broadcasts de todos os processos chegam a todos na ordem de envio de cada um
+func TestFIFOBurst(t *testing.T) {
+	dir := t.TempDir()
+	addrs := make([]string, 3)
+	for i := range addrs {
+		addrs[i] = "unix://" + filepath.Join(dir, "p"+strconv.Itoa(i)+".sock")
+	}
+	fifos := make([]*FIFO_Module, len(addrs))
+	for i := range addrs {
+		link, err := PP2PLink.NewPP2PLink(addrs[i], false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fifos[i] = NewFIFO(addrs, i, false, NewRB(addrs, i, false, NewBEB(addrs, i, false, link)))
+	}
+
+	const n = 100
+	for _, f := range fifos {
+		go func(f *FIFO_Module) {
+			for k := 0; k < n; k++ {
+				f.Req <- Broadcast_Req_Message{Message: strconv.Itoa(k)}
+			}
+		}(f)
+	}
+	errs := make(chan string, len(fifos))
+	for i, f := range fifos {
+		go func(i int, f *FIFO_Module) {
+			next := make([]int, len(addrs))
+			for got := 0; got < n*len(addrs); got++ {
+				select {
+				case msg := <-f.Ind:
+					if msg.Message != strconv.Itoa(next[msg.From]) {
+						errs <- fmt.Sprintf("processo %d: de %d recebido %s, esperado %d", i, msg.From, msg.Message, next[msg.From])
+						return
+					}
+					next[msg.From]++
+				case <-time.After(10 * time.Second):
+					errs <- fmt.Sprintf("processo %d: so %d de %d entregas", i, got, n*len(addrs))
+					return
+				}
+			}
+			errs <- ""
+		}(i, f)
+	}
+	for range fifos {
+		if err := <-errs; err != "" {
+			t.Error(err)
+		}
+	}
+}
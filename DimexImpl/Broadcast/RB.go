@@ -0,0 +1,83 @@
+/*
+  Reliable broadcast "eager" (Cachin et al., algoritmo 3.3): quem entrega uma
+  mensagem pela primeira vez a retransmite por BEB. Se algum processo correto
+  entrega, todos os corretos entregam, mesmo que o remetente falhe no meio do envio.
+  Cada mensagem e' identificada por (origem, seq). Formato sobre o BEB:
+     <origem>|<seq>|<mensagem>
+  Custo: N^2 mensagens por broadcast.
+*/
+
+package Broadcast
+
+type rbID struct {
+	origin int
+	seq    int
+}
+
+type RB_Module struct {
+	Req       chan Broadcast_Req_Message
+	Ind       chan Broadcast_Ind_Message
+	id        int
+	dbg       bool
+	seq       int
+	delivered map[rbID]bool
+	out       chan Broadcast_Req_Message
+	Beb       *BEB_Module
+}
+
+func NewRB(_addresses []string, _id int, _dbg bool, _beb *BEB_Module) *RB_Module {
+	rb := &RB_Module{
+		Req:       make(chan Broadcast_Req_Message),
+		Ind:       make(chan Broadcast_Ind_Message, indBuffer),
+		id:        _id,
+		dbg:       _dbg,
+		delivered: make(map[rbID]bool),
+		out:       make(chan Broadcast_Req_Message),
+		Beb:       _beb}
+	go forward(rb.out, rb.Beb.Req)
+	rb.Start()
+	rb.outDbg("Init RB!")
+	return rb
+}
+
+func (module *RB_Module) Start() {
+	go func() {
+		for {
+			select {
+			case req := <-module.Req:
+				module.seq++
+				module.bebBroadcast(encodeHeader([]int{module.id, module.seq}, req.Message))
+			case msg := <-module.Beb.Ind:
+				module.handleBebDeliver(msg)
+			}
+		}
+	}()
+}
+
+func (module *RB_Module) handleBebDeliver(msg Broadcast_Ind_Message) {
+	fields, payload, err := decodeHeader(msg.Message, 2)
+	if err != nil {
+		module.outDbg("descartada: " + err.Error())
+		return
+	}
+	id := rbID{origin: fields[0], seq: fields[1]}
+	if module.delivered[id] {
+		return
+	}
+	module.delivered[id] = true
+	module.Ind <- Broadcast_Ind_Message{From: id.origin, Message: payload}
+	if id.origin != module.id {
+		// retransmite para garantir entrega se a origem falhou (a propria ja foi por BEB)
+		module.bebBroadcast(msg.Message)
+	}
+}
+
+// bebBroadcast nao espera o BEB (ele pode estar bloqueado entregando para este modulo):
+// forward guarda as mensagens e as repassa na ordem
+func (module *RB_Module) bebBroadcast(message string) {
+	module.out <- Broadcast_Req_Message{Message: message}
+}
+
+func (module *RB_Module) outDbg(s string) {
+	outDbg(module.dbg, "RB", s)
+}
@@ -0,0 +1,33 @@
+package Broadcast
+
+import (
+	PP2PLink "SD/PP2PLink"
+	"testing"
+)
+
+// p0 falha no meio do broadcast: a mensagem so chegou a p1. Como p1 a retransmite ao
+// entregar, todos os processos corretos a entregam, uma vez
+func TestRBSenderCrashMidBroadcast(t *testing.T) {
+	addrs := unixAddresses(t, 4)
+	links := newLinks(t, addrs)
+	rbs := make([]*RB_Module, len(addrs))
+	for i := 1; i < len(addrs); i++ {
+		rbs[i] = NewRB(addrs, i, false, NewBEB(addrs, i, false, links[i]))
+	}
+
+	// o inicio do broadcast de p0 (seq 1), enviado so para p1; depois p0 para
+	links[0].Req <- PP2PLink.PP2PLink_Req_Message{To: addrs[1], Message: encodeHeader([]int{0, 1}, "m")}
+
+	for i := 1; i < len(addrs); i++ {
+		if msg := receive(t, rbs[i].Ind); msg.From != 0 || msg.Message != "m" {
+			t.Fatalf("processo %d entregou %+v, esperado m de 0", i, msg)
+		}
+	}
+	// nenhuma copia a mais: a proxima entrega de cada um e' a mensagem seguinte de p1
+	rbs[1].Req <- Broadcast_Req_Message{Message: "depois"}
+	for i := 1; i < len(addrs); i++ {
+		if msg := receive(t, rbs[i].Ind); msg.From != 1 || msg.Message != "depois" {
+			t.Fatalf("processo %d entregou %+v, esperado depois de 1", i, msg)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+/*
+  Total order (atomic) broadcast com relogio de Lamport e acks, no estilo do DIMEX:
+    - broadcast: lcl++, envia [MSG, ts=lcl, origem, m] a todos (inclusive a si)
+    - ao receber MSG: lcl = max(lcl, ts)+1, guarda m na fila ordenada por (ts, origem)
+      e envia [ACK, ts, origem] a todos
+    - ao receber ACK: lcl = max(lcl, ts)+1 e anota o ack
+    - entrega o inicio da fila quando a mensagem ja chegou e todos os processos a confirmaram
+  Como os canais sao FIFO (PP2PLink) e o ack de p tem relogio maior que ts, qualquer
+  mensagem de p com timestamp menor chega antes do ack de p: quando todos confirmaram,
+  nenhuma mensagem anterior na ordem total ainda pode chegar. Todos os processos
+  entregam as mensagens na mesma ordem (ts, origem).
+  Nao tolera falhas: se um processo para, as mensagens deixam de ser estaveis.
+  Formato sobre o BEB:
+     0|<ts>|<origem>|<mensagem>   (MSG)
+     1|<ts>|<origem>|             (ACK)
+*/
+
+package Broadcast
+
+import "strconv"
+
+const (
+	toMsg = 0
+	toAck = 1
+)
+
+type toKey struct {
+	ts     int
+	origin int
+}
+
+type toEntry struct {
+	key      toKey
+	received bool
+	payload  string
+	acks     []bool
+	nbrAcks  int
+}
+
+type TotalOrder_Module struct {
+	Req     chan Broadcast_Req_Message
+	Ind     chan Broadcast_Ind_Message
+	n       int
+	id      int
+	dbg     bool
+	lcl     int                // relogio logico local
+	entries map[toKey]*toEntry // mensagens (ou acks) ainda nao entregues
+	queue   []*toEntry         // mensagens recebidas, ordenadas por (ts, origem)
+	out     chan Broadcast_Req_Message
+	Beb     *BEB_Module
+}
+
+func NewTotalOrder(_addresses []string, _id int, _dbg bool, _beb *BEB_Module) *TotalOrder_Module {
+	to := &TotalOrder_Module{
+		Req:     make(chan Broadcast_Req_Message),
+		Ind:     make(chan Broadcast_Ind_Message, indBuffer),
+		n:       len(_addresses),
+		id:      _id,
+		dbg:     _dbg,
+		entries: make(map[toKey]*toEntry),
+		out:     make(chan Broadcast_Req_Message),
+		Beb:     _beb}
+	go forward(to.out, to.Beb.Req)
+	to.Start()
+	to.outDbg("Init TotalOrder!")
+	return to
+}
+
+func (module *TotalOrder_Module) Start() {
+	go func() {
+		for {
+			select {
+			case req := <-module.Req:
+				module.lcl++
+				module.out <- Broadcast_Req_Message{Message: encodeHeader([]int{toMsg, module.lcl, module.id}, req.Message)}
+			case msg := <-module.Beb.Ind:
+				module.handleBebDeliver(msg)
+			}
+		}
+	}()
+}
+
+func (module *TotalOrder_Module) handleBebDeliver(msg Broadcast_Ind_Message) {
+	fields, payload, err := decodeHeader(msg.Message, 3)
+	if err != nil || msg.From < 0 || msg.From >= module.n || fields[2] < 0 || fields[2] >= module.n {
+		module.outDbg("descartada: " + msg.Message)
+		return
+	}
+	kind, key := fields[0], toKey{ts: fields[1], origin: fields[2]}
+	if key.ts > module.lcl {
+		module.lcl = key.ts
+	}
+	module.lcl++
+
+	e := module.entry(key)
+	switch kind {
+	case toMsg:
+		if e.received {
+			return
+		}
+		e.received = true
+		e.payload = payload
+		module.insert(e)
+		module.out <- Broadcast_Req_Message{Message: encodeHeader([]int{toAck, key.ts, key.origin}, "")}
+	case toAck:
+		if !e.acks[msg.From] {
+			e.acks[msg.From] = true
+			e.nbrAcks++
+		}
+	}
+	module.deliverStable()
+}
+
+func (module *TotalOrder_Module) entry(key toKey) *toEntry {
+	e, ok := module.entries[key]
+	if !ok {
+		e = &toEntry{key: key, acks: make([]bool, module.n)}
+		module.entries[key] = e
+	}
+	return e
+}
+
+// insert coloca a mensagem na fila mantendo a ordem (ts, origem)
+func (module *TotalOrder_Module) insert(e *toEntry) {
+	i := len(module.queue)
+	for i > 0 && before(e.key.origin, e.key.ts, module.queue[i-1].key.origin, module.queue[i-1].key.ts) {
+		i--
+	}
+	module.queue = append(module.queue, nil)
+	copy(module.queue[i+1:], module.queue[i:])
+	module.queue[i] = e
+}
+
+// deliverStable entrega o inicio da fila enquanto estiver confirmado por todos
+func (module *TotalOrder_Module) deliverStable() {
+	for len(module.queue) > 0 && module.queue[0].nbrAcks == module.n {
+		e := module.queue[0]
+		module.queue = module.queue[1:]
+		delete(module.entries, e.key)
+		module.outDbg("entrega (" + strconv.Itoa(e.key.ts) + "," + strconv.Itoa(e.key.origin) + "): " + e.payload)
+		module.Ind <- Broadcast_Ind_Message{From: e.key.origin, Message: e.payload}
+	}
+}
+
+// before: ordem total por timestamp, desempate pelo id (como no DIMEX)
+func before(oneId, oneTs, othId, othTs int) bool {
+	if oneTs != othTs {
+		return oneTs < othTs
+	}
+	return oneId < othId
+}
+
+func (module *TotalOrder_Module) outDbg(s string) {
+	outDbg(module.dbg, "TotalOrder", s)
+}
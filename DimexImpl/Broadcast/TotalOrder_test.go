@@ -0,0 +1,99 @@
+package Broadcast
+
+import (
+	PP2PLink "SD/PP2PLink"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func unixAddresses(t *testing.T, n int) []string {
+	t.Helper()
+	dir := t.TempDir()
+	addrs := make([]string, n)
+	for i := range addrs {
+		addrs[i] = "unix://" + filepath.Join(dir, "p"+strconv.Itoa(i)+".sock")
+	}
+	return addrs
+}
+
+func newLinks(t *testing.T, addrs []string) []*PP2PLink.PP2PLink {
+	t.Helper()
+	links := make([]*PP2PLink.PP2PLink, len(addrs))
+	for i := range addrs {
+		link, err := PP2PLink.NewPP2PLink(addrs[i], false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		links[i] = link
+	}
+	return links
+}
+
+// todos difundem ao mesmo tempo: todos os processos entregam a mesma sequencia
+func TestTotalOrderSameSequence(t *testing.T) {
+	addrs := unixAddresses(t, 4)
+	tos := make([]*TotalOrder_Module, len(addrs))
+	for i, link := range newLinks(t, addrs) {
+		tos[i] = NewTotalOrder(addrs, i, false, NewBEB(addrs, i, false, link))
+	}
+
+	const n = 30
+	for i, to := range tos {
+		go func(i int, to *TotalOrder_Module) {
+			for k := 0; k < n; k++ {
+				to.Req <- Broadcast_Req_Message{Message: fmt.Sprintf("%d-%d", i, k)}
+			}
+		}(i, to)
+	}
+
+	type delivery struct {
+		seq []string
+		err string
+	}
+	results := make(chan delivery, len(tos))
+	for i, to := range tos {
+		go func(i int, to *TotalOrder_Module) {
+			var seq []string
+			for len(seq) < n*len(addrs) {
+				select {
+				case msg := <-to.Ind:
+					seq = append(seq, msg.Message)
+				case <-time.After(10 * time.Second):
+					results <- delivery{err: fmt.Sprintf("processo %d: so %d de %d entregas", i, len(seq), n*len(addrs))}
+					return
+				}
+			}
+			results <- delivery{seq: seq}
+		}(i, to)
+	}
+
+	var first []string
+	for range tos {
+		d := <-results
+		if d.err != "" {
+			t.Fatal(d.err)
+		}
+		if first == nil {
+			first = d.seq
+			continue
+		}
+		for k := range first {
+			if d.seq[k] != first[k] {
+				t.Fatalf("entrega %d: %s num processo, %s em outro", k, first[k], d.seq[k])
+			}
+		}
+	}
+	// a ordem total respeita a ordem de envio de cada processo
+	next := make([]int, len(addrs))
+	for _, m := range first {
+		var from, k int
+		fmt.Sscanf(m, "%d-%d", &from, &k)
+		if k != next[from] {
+			t.Fatalf("mensagem %s entregue antes de %d-%d", m, from, next[from])
+		}
+		next[from]++
+	}
+}
@@ -0,0 +1,82 @@
+/*
+  Barreira de partida: substitui a espera fixa antes do primeiro pedido.
+  Ao iniciar, cada processo envia [hello, id] a todos e responde [helloAck, id] a cada
+  hello recebido; o processo fica pronto (Ready fechado) quando todos os outros confirmaram
+  o seu hello, ou seja, quando todos escutam e as mensagens deste processo ja chegam a eles.
+  (So receber o hello dos outros nao basta: o hello deste processo pode estar esperando
+  o redial do PP2PLink, e os primeiros pedidos ficariam atras dele.)
+  O PP2PLink retransmite o hello ate o ack, entao um processo que sobe depois tambem
+  recebe o hello dos que subiram antes.
+  Mensagens hello e helloAck nao participam do algoritmo nem dos snapshots.
+*/
+
+package DIMEX
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	helloMsg    = "hello"
+	helloAckMsg = "helloAck"
+)
+
+type barrier struct {
+	mutex     sync.Mutex
+	addresses []string
+	seen      []bool // confirmacao recebida de cada processo
+	missing   int
+	ready     chan struct{}
+}
+
+// newBarrier espera todos os processos, inclusive o proprio
+func newBarrier(_addresses []string) *barrier {
+	return &barrier{
+		addresses: _addresses,
+		seen:      make([]bool, len(_addresses)),
+		missing:   len(_addresses),
+		ready:     make(chan struct{})}
+}
+
+// hello anota que id respondeu; devolve true se com ele todos estao prontos
+func (b *barrier) hello(id int) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if id < 0 || id >= len(b.seen) || b.seen[id] {
+		return false
+	}
+	b.seen[id] = true
+	b.missing--
+	if b.missing == 0 {
+		close(b.ready)
+		return true
+	}
+	return false
+}
+
+// wait espera todos os hellos; o erro lista quem nao respondeu ate timeout (0: sem limite)
+func (b *barrier) wait(timeout time.Duration) error {
+	if timeout <= 0 {
+		<-b.ready
+		return nil
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-b.ready:
+		return nil
+	case <-timer.C:
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	var missing []string
+	for i, ok := range b.seen {
+		if !ok {
+			missing = append(missing, fmt.Sprintf("%d (%s)", i, b.addresses[i]))
+		}
+	}
+	return fmt.Errorf("processos nao responderam apos %v: %s", timeout, strings.Join(missing, ", "))
+}
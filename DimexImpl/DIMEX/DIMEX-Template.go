@@ -20,12 +20,13 @@
 package DIMEX
 
 import (
+	Broadcast "SD/Broadcast"
 	PP2PLink "SD/PP2PLink"
 	"fmt"
-	"os"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
 var (
@@ -52,6 +53,15 @@ const (
 	SNAPSHOT
 )
 
+var requestNames = []string{ENTER: "ENTER", EXIT: "EXIT", SNAPSHOT: "SNAPSHOT"}
+
+func (r dmxReq) String() string {
+	if r < 0 || int(r) >= len(requestNames) {
+		return "dmxReq(" + strconv.Itoa(int(r)) + ")"
+	}
+	return requestNames[r]
+}
+
 type dmxResp struct { // mensagem do módulo DIMEX infrmando que pode acessar - pode ser somente um sinal (vazio)
 	// mensagem para aplicacao indicando que pode prosseguir
 }
@@ -59,6 +69,8 @@ type dmxResp struct { // mensagem do módulo DIMEX infrmando que pode acessar -
 type DIMEX_Module struct {
 	Req       chan dmxReq  // canal para receber pedidos da aplicacao (REQ e EXIT)
 	Ind       chan dmxResp // canal para informar aplicacao que pode acessar
+	Err       chan error   // canal para informar erros (ex: falha ao gravar snapshot) sem derrubar o processo
+	Ready     chan struct{} // fechado quando todos os processos confirmaram o hello (ver Barrier.go)
 	addresses []string     // endereco de todos, na mesma ordem
 	id        int          // identificador do processo - é o indice no array de enderecos acima
 	st        State        // estado deste processo na exclusao mutua distribuida
@@ -76,9 +88,11 @@ type DIMEX_Module struct {
 	processState      string
 	snapshotMsgs  	  []bool
 	messagesInTransit []string
-	snapshotFileName  string
+	snapshotSink      SnapshotSink
 	snapshotID        int
 
+	barrier *barrier
+
 	mutex sync.Mutex
 }
 
@@ -86,13 +100,37 @@ type DIMEX_Module struct {
 // ------- inicializacao
 // ------------------------------------------------------------------------------------
 
-func NewDIMEX(_addresses []string, _id int, _dbg bool) *DIMEX_Module {
+// _sink recebe os snapshots finalizados; se nil, usa arquivo em ../SnapshotAnalysis
+func NewDIMEX(_addresses []string, _id int, _dbg bool, _sink SnapshotSink) (*DIMEX_Module, error) {
+	p2p, err := PP2PLink.NewPP2PLink(_addresses[_id], _dbg)
+	if err != nil {
+		return nil, err
+	}
+	return NewDIMEXWithLink(_addresses, _id, _dbg, _sink, p2p), nil
+}
+
+// NewDIMEXWithLink usa um link ja criado (ex: com trace, ou em memoria para replay)
+func NewDIMEXWithLink(_addresses []string, _id int, _dbg bool, _sink SnapshotSink, p2p *PP2PLink.PP2PLink) *DIMEX_Module {
+	dmx := newDIMEXModule(_addresses, _id, _dbg, _sink, p2p)
+	dmx.Start()
+	dmx.outDbg("Init DIMEX!")
+	return dmx
+}
+
+// newDIMEXModule cria o modulo sem iniciar a rotina de Start
+func newDIMEXModule(_addresses []string, _id int, _dbg bool, _sink SnapshotSink, p2p *PP2PLink.PP2PLink) *DIMEX_Module {
 
-	p2p := PP2PLink.NewPP2PLink(_addresses[_id], _dbg)
+	if _sink == nil {
+		_sink = NewFileSnapshotSink("../SnapshotAnalysis", 0, 0)
+	}
 
+	barrier := newBarrier(_addresses)
+	barrier.hello(_id) // o proprio processo nao envia hello a si mesmo
 	dmx := &DIMEX_Module{
 		Req: make(chan dmxReq, 1),
 		Ind: make(chan dmxResp, 1),
+		Err: make(chan error, 10),
+		Ready: barrier.ready,
 
 		addresses: _addresses,
 		id:        _id,
@@ -109,65 +147,112 @@ func NewDIMEX(_addresses []string, _id int, _dbg bool) *DIMEX_Module {
 		snapshotAnswers:   0,
 		snapshotMsgs:  	   make([]bool, len(_addresses)),
 		messagesInTransit: []string{},
-		snapshotFileName:  fmt.Sprintf("../SnapshotAnalysis/snapshot_proc_%d.txt", _id),
+		snapshotSink:      _sink,
 		snapshotID:        0,
+
+		barrier: barrier,
 	}
 
 	for i := 0; i < len(dmx.waiting); i++ {
 		dmx.waiting[i] = false
 	}
-	dmx.Start()
-	dmx.outDbg("Init DIMEX!")
 	return dmx
 }
 
+// WaitReady bloqueia ate todos os processos confirmarem o hello (timeout 0: sem limite)
+func (module *DIMEX_Module) WaitReady(timeout time.Duration) error {
+	return module.barrier.wait(timeout)
+}
+
+// Close fecha a escuta do link (ver PP2PLink.Close)
+func (module *DIMEX_Module) Close() error {
+	return module.Pp2plink.Close()
+}
+
 // ------------------------------------------------------------------------------------
 // ------- nucleo do funcionamento
 // ------------------------------------------------------------------------------------
 
 func (module *DIMEX_Module) Start() {
 	go func() {
+		module.broadcastToLink(helloMsg+","+fmt.Sprint(module.id), "") // barreira de partida
 		for {
 			select {
 			case dmxR := <-module.Req: // vindo da  aplicação
-				if dmxR == ENTER {
-					module.outDbg("app pede mx")
-					module.handleUponReqEntry()
-
-				} else if dmxR == EXIT {
-					module.outDbg("app libera mx")
-					module.handleUponReqExit()
-				} else if dmxR == SNAPSHOT {
-					module.outDbg("app pede snapshot")
-					module.handleSnapshot(true, module.snapshotID, -1)
-				}
+				module.handleRequest(dmxR)
 
 			case msgOutro := <-module.Pp2plink.Ind: // vindo de outro processo
-				module.outDbg("recebeu msg de outro processo: " + msgOutro.Message)
-				id_msg, _ := strconv.Atoi(strings.Split(msgOutro.Message, ",")[1])
-
-				if module.makingSnapshot && !strings.Contains(msgOutro.Message, "msgSnapshot") && ((!module.snapshotMsgs[id_msg]) || bug_respostas) {
-					module.messagesInTransit = append(module.messagesInTransit, msgOutro.Message)
-				}
-
-				if strings.Contains(msgOutro.Message, "respOk") {
-					module.outDbg("         <<<---- recebi um OK! " + msgOutro.Message)
-					module.handleUponDeliverRespOk(msgOutro)
-				
-				} else if strings.Contains(msgOutro.Message, "reqEntry") {
-					module.outDbg("          <<<---- recebi uma REQ!  " + msgOutro.Message)
-					module.handleUponDeliverReqEntry(msgOutro)
-			
-				} else if strings.Contains(msgOutro.Message, "msgSnapshot") {
-					module.outDbg("          <<<---- recebi pedido snapshot!  " + msgOutro.Message)
-					_snapshotID, _ := strconv.Atoi(strings.Split(msgOutro.Message, ",")[2])
-					module.handleSnapshot(false, _snapshotID, id_msg)
-				}
+				module.handleMessage(msgOutro)
 			}
 		}
 	}()
 }
 
+// handleRequest trata um pedido da aplicacao. O pedido vai para o trace do link
+// (se houver) na ordem em que e' tratado, para o replay (ver Replay.go)
+func (module *DIMEX_Module) handleRequest(dmxR dmxReq) {
+	module.Pp2plink.TraceApp(dmxR.String())
+	if dmxR == ENTER {
+		module.outDbg("app pede mx")
+		module.handleUponReqEntry()
+
+	} else if dmxR == EXIT {
+		module.outDbg("app libera mx")
+		module.handleUponReqExit()
+	} else if dmxR == SNAPSHOT {
+		module.outDbg("app pede snapshot")
+		module.handleSnapshot(true, module.snapshotID, -1)
+	}
+}
+
+// handleMessage trata uma mensagem de outro processo; como em handleRequest, a
+// entrega vai para o trace na ordem em que e' tratada
+func (module *DIMEX_Module) handleMessage(msgOutro PP2PLink.PP2PLink_Ind_Message) {
+	module.Pp2plink.TraceDeliver(msgOutro.From, msgOutro.Message)
+	module.outDbg("recebeu msg de outro processo: " + msgOutro.Message)
+	id_msg, err := module.parseMessage(msgOutro.Message)
+	if err != nil {
+		module.reportErr(err)
+		return
+	}
+
+	// no modo TLS o certificado do remetente tem que ser o do id que a mensagem diz ter
+	if msgOutro.Identity != "" && msgOutro.Identity != PP2PLink.ProcessIdentity(id_msg) {
+		module.reportErr(fmt.Errorf("mensagem %q descartada: certificado de %s", msgOutro.Message, msgOutro.Identity))
+		return
+	}
+
+	// barreira de partida, fora do algoritmo
+	if strings.HasPrefix(msgOutro.Message, helloMsg+",") {
+		module.sendToLink(module.addresses[id_msg], helloAckMsg+","+fmt.Sprint(module.id), "")
+		return
+	}
+	if strings.HasPrefix(msgOutro.Message, helloAckMsg+",") {
+		if module.barrier.hello(id_msg) {
+			module.outDbg("todos os processos prontos")
+		}
+		return
+	}
+
+	if module.makingSnapshot && !strings.Contains(msgOutro.Message, "msgSnapshot") && ((!module.snapshotMsgs[id_msg]) || bug_respostas) {
+		module.messagesInTransit = append(module.messagesInTransit, msgOutro.Message)
+	}
+
+	if strings.Contains(msgOutro.Message, "respOk") {
+		module.outDbg("         <<<---- recebi um OK! " + msgOutro.Message)
+		module.handleUponDeliverRespOk(msgOutro)
+
+	} else if strings.Contains(msgOutro.Message, "reqEntry") {
+		module.outDbg("          <<<---- recebi uma REQ!  " + msgOutro.Message)
+		module.handleUponDeliverReqEntry(msgOutro)
+
+	} else if strings.Contains(msgOutro.Message, "msgSnapshot") {
+		module.outDbg("          <<<---- recebi pedido snapshot!  " + msgOutro.Message)
+		_snapshotID, _ := strconv.Atoi(strings.Split(msgOutro.Message, ",")[2])
+		module.handleSnapshot(false, _snapshotID, id_msg)
+	}
+}
+
 // ------------------------------------------------------------------------------------
 // ------- tratamento de pedidos vindos da aplicacao
 // ------- UPON ENTRY
@@ -188,12 +273,7 @@ func (module *DIMEX_Module) handleUponReqEntry() {
 	module.reqTs = module.lcl
 	module.nbrResps = 0
 
-	for i, value := range module.addresses {
-		if i == module.id {
-			continue // nao pode enviar a si mesmo
-		}
-		module.sendToLink(value, "reqEntry,"+fmt.Sprint(module.id)+","+fmt.Sprint(module.reqTs), "")
-	}
+	module.broadcastToLink("reqEntry,"+fmt.Sprint(module.id)+","+fmt.Sprint(module.reqTs), "")
 	module.st = wantMX
 }
 
@@ -293,12 +373,7 @@ func (module *DIMEX_Module) handleSnapshot(started bool, _snapshotID int, id_ori
 		module.processState = module.processStateToString()
 		module.messagesInTransit = []string{}
 		module.outDbg("Iniciando snapshot")
-		for i, value := range module.addresses {
-			if i == module.id {
-				continue // nao pode enviar a si mesmo
-			}
-			module.sendToLink(value, "msgSnapshot,"+fmt.Sprint(module.id)+","+fmt.Sprint(_snapshotID), "")
-		}
+		module.broadcastToLink("msgSnapshot,"+fmt.Sprint(module.id)+","+fmt.Sprint(_snapshotID), "")
 	} else {
 		if !started {
 			module.snapshotMsgs[id_origem_msg] = true
@@ -306,7 +381,7 @@ func (module *DIMEX_Module) handleSnapshot(started bool, _snapshotID int, id_ori
 		module.snapshotAnswers++
 		if module.snapshotAnswers == len(module.addresses)-1 {
 			//finaliza snapshot
-			module.writeSnapshotToFile(_snapshotID)
+			module.writeSnapshot(_snapshotID)
 			module.snapshotAnswers = 0
 			module.makingSnapshot = false
 			module.snapshotMsgs = make([]bool, len(module.addresses))
@@ -327,6 +402,12 @@ func (module *DIMEX_Module) sendToLink(address string, content string, space str
 		Message: content}
 }
 
+// broadcastToLink envia content a todos os outros processos (best-effort broadcast)
+func (module *DIMEX_Module) broadcastToLink(content string, space string) {
+	module.outDbg(space + " ---->>>>   to: todos     msg: " + content)
+	Broadcast.SendToOthers(module.Pp2plink, module.addresses, module.id, content)
+}
+
 func before(oneId, oneTs, othId, othTs int) bool {
 	if oneTs < othTs {
 		return true
@@ -383,18 +464,43 @@ func (module *DIMEX_Module) SnapshotToString(_snapshotID int) string {
 	return s
 }
 
-func (module *DIMEX_Module) writeSnapshotToFile(_snapshotID int) {
-	file, err := os.OpenFile(module.snapshotFileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+func (module *DIMEX_Module) writeSnapshot(_snapshotID int) {
+	err := module.snapshotSink.WriteSnapshot(SnapshotRecord{
+		ProcID:     module.id,
+		SnapshotID: _snapshotID,
+		Data:       module.SnapshotToString(_snapshotID)})
 	if err != nil {
-		fmt.Println("Error opening file:", err)
-		os.Exit(1)
-		return
+		module.outDbg("erro ao gravar snapshot: " + err.Error())
+		module.reportErr(err)
 	}
-	snapshotData := module.SnapshotToString(_snapshotID)
-	_, err = file.WriteString(snapshotData)
-	if err != nil {
-		fmt.Println("Error writing to file:", err)
-		os.Exit(1)
-		return
+}
+
+// parseMessage confere o formato "tipo,id" ("tipo,id,n" para reqEntry e msgSnapshot)
+// e devolve o id do remetente; mensagem fora do formato e' descartada por quem chama
+func (module *DIMEX_Module) parseMessage(m string) (int, error) {
+	parts := strings.Split(m, ",")
+	if len(parts) < 2 {
+		return -1, fmt.Errorf("mensagem %q descartada: sem id do remetente", m)
+	}
+	id, err := strconv.Atoi(parts[1])
+	if err != nil || id < 0 || id >= len(module.addresses) {
+		return -1, fmt.Errorf("mensagem %q descartada: id do remetente invalido", m)
+	}
+	if strings.Contains(m, "reqEntry") || strings.Contains(m, "msgSnapshot") {
+		if len(parts) < 3 {
+			return -1, fmt.Errorf("mensagem %q descartada: falta o terceiro campo", m)
+		}
+		if _, err := strconv.Atoi(parts[2]); err != nil {
+			return -1, fmt.Errorf("mensagem %q descartada: terceiro campo invalido", m)
+		}
+	}
+	return id, nil
+}
+
+// reportErr repassa o erro para a aplicacao sem bloquear o modulo (descarta se ninguem le)
+func (module *DIMEX_Module) reportErr(err error) {
+	select {
+	case module.Err <- err:
+	default:
 	}
 }
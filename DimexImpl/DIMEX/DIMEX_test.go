@@ -0,0 +1,69 @@
+package DIMEX
+
+import (
+	PP2PLink "SD/PP2PLink"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseMessage(t *testing.T) {
+	module := &DIMEX_Module{addresses: []string{"a", "b", "c"}}
+	cases := []struct {
+		msg string
+		id  int
+		ok  bool
+	}{
+		{"respOk,2", 2, true},
+		{"reqEntry,1,7", 1, true},
+		{"msgSnapshot,0,3", 0, true},
+		{"hello,1", 1, true},
+		{"lixo", -1, false},
+		{"respOk,x", -1, false},
+		{"respOk,3", -1, false},
+		{"respOk,-1", -1, false},
+		{"reqEntry,1", -1, false},
+		{"msgSnapshot,1,x", -1, false},
+	}
+	for _, c := range cases {
+		id, err := module.parseMessage(c.msg)
+		if (err == nil) != c.ok || id != c.id {
+			t.Errorf("%q: id %d, erro %v; esperado id %d, ok %v", c.msg, id, err, c.id, c.ok)
+		}
+	}
+}
+
+// mensagens fora do formato sao informadas em Err e descartadas; o modulo continua respondendo
+func TestMalformedMessagesSkipped(t *testing.T) {
+	dir := t.TempDir()
+	addrs := []string{"unix://" + filepath.Join(dir, "p0.sock"), "unix://" + filepath.Join(dir, "p1.sock")}
+	dmx, err := NewDIMEX(addrs, 0, false, NewFileSnapshotSink(dir, 0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	peer, err := PP2PLink.NewPP2PLink(addrs[1], false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for range peer.Ind {
+		}
+	}()
+
+	bad := []string{"lixo", "respOk,x", "reqEntry,7,1", "reqEntry,1"}
+	for _, m := range bad {
+		peer.Req <- PP2PLink.PP2PLink_Req_Message{To: addrs[0], Message: m}
+	}
+	for range bad {
+		select {
+		case <-dmx.Err:
+		case <-time.After(5 * time.Second):
+			t.Fatal("mensagem invalida nao informada em Err")
+		}
+	}
+
+	peer.Req <- PP2PLink.PP2PLink_Req_Message{To: addrs[0], Message: helloAckMsg + ",1"}
+	if err := dmx.WaitReady(5 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,43 @@
+/*
+  Reproducao de um trace gravado (ver cmd/replay e PP2PLink/Trace.go).
+  O modulo criado por NewDIMEXReplay nao tem a rotina de Start: quem reproduz chama
+  Request e Deliver na ordem dos eventos APP e DLVR do trace, e cada chamada so volta
+  depois que o modulo tratou a entrada por inteiro. Os envios saem pelo PP2PLink
+  (em memoria) como no modulo normal; a entrada na SC sai em Ind.
+*/
+
+package DIMEX
+
+import (
+	PP2PLink "SD/PP2PLink"
+	"fmt"
+)
+
+// NewDIMEXReplay cria o modulo para replay; envia o hello inicial, como Start.
+// Quem le os envios do link tem que estar rodando antes da chamada.
+func NewDIMEXReplay(_addresses []string, _id int, _dbg bool, _sink SnapshotSink, p2p *PP2PLink.PP2PLink) *DIMEX_Module {
+	dmx := newDIMEXModule(_addresses, _id, _dbg, _sink, p2p)
+	dmx.broadcastToLink(helloMsg+","+fmt.Sprint(dmx.id), "")
+	dmx.outDbg("Init DIMEX replay!")
+	return dmx
+}
+
+// ParseRequest converte o pedido gravado em APP ("ENTER", "EXIT" ou "SNAPSHOT")
+func ParseRequest(s string) (dmxReq, error) {
+	for r, name := range requestNames {
+		if name == s {
+			return dmxReq(r), nil
+		}
+	}
+	return 0, fmt.Errorf("pedido da aplicacao desconhecido: %q", s)
+}
+
+// Request trata um pedido da aplicacao (evento APP); so no modulo de NewDIMEXReplay
+func (module *DIMEX_Module) Request(r dmxReq) {
+	module.handleRequest(r)
+}
+
+// Deliver trata uma mensagem recebida (evento DLVR); so no modulo de NewDIMEXReplay
+func (module *DIMEX_Module) Deliver(msg PP2PLink.PP2PLink_Ind_Message) {
+	module.handleMessage(msg)
+}
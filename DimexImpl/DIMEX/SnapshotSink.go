@@ -0,0 +1,171 @@
+/*  Destinos (sinks) para os snapshots gravados pelo DIMEX.
+    O modulo DIMEX nao sabe onde o snapshot vai parar: ao terminar um snapshot ele
+    entrega a linha formatada (ver SnapshotToString) para um SnapshotSink.
+	Implementacoes disponiveis:
+	   FileSnapshotSink   - arquivo snapshot_proc_<id>.txt em diretorio configuravel, com rotacao
+	                        e marcador de execucao (ver RunMarker)
+	   MemorySnapshotSink - guarda os snapshots em memoria (util para testes e replay)
+	   ChanSnapshotSink   - repassa cada snapshot para um canal
+*/
+
+package DIMEX
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// SnapshotRecord e' um snapshot local ja finalizado por um processo
+type SnapshotRecord struct {
+	ProcID     int
+	SnapshotID int
+	Data       string // linha no formato de SnapshotToString (inclui '\n')
+}
+
+// SnapshotSink recebe os snapshots locais finalizados.
+// Um erro retornado e' repassado ao canal Err do modulo, o processo nao e' encerrado.
+type SnapshotSink interface {
+	WriteSnapshot(rec SnapshotRecord) error
+}
+
+// ------------------------------------------------------------------------------------
+// ------- arquivo
+// ------------------------------------------------------------------------------------
+
+type FileSnapshotSink struct {
+	Dir      string // diretorio onde os arquivos sao criados
+	MaxBytes int64  // tamanho maximo do arquivo antes de rotacionar (0 = sem rotacao)
+	MaxFiles int    // numero de arquivos rotacionados mantidos (<name>.1 ... <name>.N)
+
+	mutex  sync.Mutex
+	run    string          // identificador desta execucao (instante de criacao do sink)
+	marked map[string]bool // arquivos que ja tem o marcador desta execucao
+}
+
+// RunMarker inicia cada arquivo escrito por um FileSnapshotSink: "# execucao <id>", com o
+// instante (unix nano) em que o sink foi criado. O sink acrescenta a arquivos existentes, entao
+// um arquivo pode ter varias execucoes; a analise separa as linhas pelo marcador anterior a
+// elas e ordena as execucoes pelo id, qualquer que seja a ordem dos arquivos rotacionados.
+const RunMarker = "# execucao "
+
+func NewFileSnapshotSink(_dir string, _maxBytes int64, _maxFiles int) *FileSnapshotSink {
+	return &FileSnapshotSink{
+		Dir:      _dir,
+		MaxBytes: _maxBytes,
+		MaxFiles: _maxFiles,
+		run:      strconv.FormatInt(time.Now().UnixNano(), 10),
+		marked:   make(map[string]bool),
+	}
+}
+
+// FileName devolve o caminho do arquivo de snapshots do processo _procID
+func (sink *FileSnapshotSink) FileName(_procID int) string {
+	return filepath.Join(sink.Dir, fmt.Sprintf("snapshot_proc_%d.txt", _procID))
+}
+
+func (sink *FileSnapshotSink) WriteSnapshot(rec SnapshotRecord) error {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+
+	if err := os.MkdirAll(sink.Dir, 0755); err != nil {
+		return fmt.Errorf("snapshot sink: criando diretorio %s: %w", sink.Dir, err)
+	}
+	name := sink.FileName(rec.ProcID)
+	rotated, err := sink.rotate(name, int64(len(rec.Data)))
+	if err != nil {
+		return err
+	}
+	data := rec.Data
+	if rotated || !sink.marked[name] {
+		data = RunMarker + sink.run + "\n" + data
+		sink.marked[name] = true
+	}
+
+	file, err := os.OpenFile(name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("snapshot sink: abrindo %s: %w", name, err)
+	}
+	defer file.Close()
+
+	if _, err = file.WriteString(data); err != nil {
+		return fmt.Errorf("snapshot sink: escrevendo snapshot %d em %s: %w", rec.SnapshotID, name, err)
+	}
+	return nil
+}
+
+// rotate renomeia name -> name.1 -> name.2 ... se a proxima escrita passar de MaxBytes;
+// devolve true se name foi rotacionado (a proxima escrita cria um arquivo novo)
+func (sink *FileSnapshotSink) rotate(name string, next int64) (bool, error) {
+	if sink.MaxBytes <= 0 {
+		return false, nil
+	}
+	info, err := os.Stat(name)
+	if err != nil || info.Size()+next <= sink.MaxBytes {
+		return false, nil // arquivo ainda nao existe ou ainda cabe
+	}
+	if sink.MaxFiles <= 0 {
+		return true, os.Remove(name)
+	}
+	os.Remove(fmt.Sprintf("%s.%d", name, sink.MaxFiles))
+	for i := sink.MaxFiles - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", name, i), fmt.Sprintf("%s.%d", name, i+1))
+	}
+	if err := os.Rename(name, name+".1"); err != nil {
+		return false, fmt.Errorf("snapshot sink: rotacionando %s: %w", name, err)
+	}
+	return true, nil
+}
+
+// ------------------------------------------------------------------------------------
+// ------- memoria
+// ------------------------------------------------------------------------------------
+
+type MemorySnapshotSink struct {
+	mutex   sync.Mutex
+	records []SnapshotRecord
+}
+
+func NewMemorySnapshotSink() *MemorySnapshotSink {
+	return &MemorySnapshotSink{}
+}
+
+func (sink *MemorySnapshotSink) WriteSnapshot(rec SnapshotRecord) error {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	sink.records = append(sink.records, rec)
+	return nil
+}
+
+// Records devolve uma copia dos snapshots gravados ate agora
+func (sink *MemorySnapshotSink) Records() []SnapshotRecord {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	return append([]SnapshotRecord(nil), sink.records...)
+}
+
+// ------------------------------------------------------------------------------------
+// ------- canal
+// ------------------------------------------------------------------------------------
+
+type ChanSnapshotSink struct {
+	C chan SnapshotRecord
+}
+
+// NewChanSnapshotSink cria um sink com canal de tamanho _size.
+// Se o canal estiver cheio a escrita falha em vez de bloquear o modulo DIMEX.
+func NewChanSnapshotSink(_size int) *ChanSnapshotSink {
+	return &ChanSnapshotSink{C: make(chan SnapshotRecord, _size)}
+}
+
+func (sink *ChanSnapshotSink) WriteSnapshot(rec SnapshotRecord) error {
+	select {
+	case sink.C <- rec:
+		return nil
+	default:
+		return fmt.Errorf("snapshot sink: canal cheio, snapshot %d do processo %d descartado", rec.SnapshotID, rec.ProcID)
+	}
+}
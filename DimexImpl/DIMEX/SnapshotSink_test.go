@@ -0,0 +1,88 @@
+package DIMEX
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// nomes que a analise (SnapshotAnalysis) aceita, inclusive os rotacionados
+var analysisFileRe = regexp.MustCompile(`^snapshot_proc_(\d+)\.txt(\.\d+)?$`)
+
+func TestFileSnapshotSinkRotation(t *testing.T) {
+	dir := t.TempDir()
+	sink := NewFileSnapshotSink(dir, 30, 2)
+	for i := 0; i < 5; i++ {
+		// cada escrita passa de MaxBytes junto com a anterior: rotaciona a cada snapshot
+		rec := SnapshotRecord{ProcID: 0, SnapshotID: i, Data: fmt.Sprintf("%d noMX 00 %d 0 0 ;;\n", i, i)}
+		if err := sink.WriteSnapshot(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		if !analysisFileRe.MatchString(e.Name()) {
+			t.Errorf("%s fora do formato esperado pela analise", e.Name())
+		}
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+	want := []string{"snapshot_proc_0.txt", "snapshot_proc_0.txt.1", "snapshot_proc_0.txt.2"}
+	if strings.Join(names, " ") != strings.Join(want, " ") {
+		t.Fatalf("arquivos %v, esperados %v (MaxFiles = 2)", names, want)
+	}
+
+	// os snapshots 0 e 1 foram descartados; cada arquivo comeca com o marcador da execucao
+	for i, name := range []string{"snapshot_proc_0.txt.2", "snapshot_proc_0.txt.1", "snapshot_proc_0.txt"} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+		if len(lines) != 2 || lines[0] != RunMarker+sink.run || !strings.HasPrefix(lines[1], fmt.Sprintf("%d ", i+2)) {
+			t.Errorf("%s: %q, esperado marcador e snapshot %d", name, data, i+2)
+		}
+	}
+}
+
+func TestFileSnapshotSinkNoRotation(t *testing.T) {
+	dir := t.TempDir()
+	sink := NewFileSnapshotSink(dir, 0, 0)
+	for i := 0; i < 3; i++ {
+		if err := sink.WriteSnapshot(SnapshotRecord{ProcID: 1, SnapshotID: i, Data: fmt.Sprintf("%d noMX\n", i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := os.ReadFile(sink.FileName(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := RunMarker + sink.run + "\n0 noMX\n1 noMX\n2 noMX\n"
+	if string(data) != want {
+		t.Fatalf("arquivo %q, esperado %q", data, want)
+	}
+}
+
+// canal cheio: a escrita falha em vez de bloquear o modulo
+func TestChanSnapshotSinkFull(t *testing.T) {
+	sink := NewChanSnapshotSink(1)
+	if err := sink.WriteSnapshot(SnapshotRecord{ProcID: 2, SnapshotID: 0}); err != nil {
+		t.Fatal(err)
+	}
+	err := sink.WriteSnapshot(SnapshotRecord{ProcID: 2, SnapshotID: 1})
+	if err == nil || !strings.Contains(err.Error(), "canal cheio, snapshot 1 do processo 2") {
+		t.Fatalf("erro %v, esperado canal cheio", err)
+	}
+	if rec := <-sink.C; rec.SnapshotID != 0 {
+		t.Fatalf("canal com o snapshot %d, esperado 0", rec.SnapshotID)
+	}
+}
@@ -0,0 +1,53 @@
+/*
+  Bully (Garcia-Molina): quem inicia envia election aos maiores ids e espera Timeout.
+  Sem ok, vira lider e anuncia coordinator a todos. Com ok, espera o coordinator
+  de algum maior por mais 2*Timeout; se nao vier, recomeca.
+  Quem recebe election de um menor responde ok e inicia a propria eleicao.
+*/
+
+package Election
+
+import (
+	"strconv"
+	"time"
+)
+
+func (module *LeaderElection_Module) startBully() {
+	module.electing = true
+	module.gotOk = false
+	if module.id == len(module.addresses)-1 {
+		module.becomeLeader() // ninguem maior
+		return
+	}
+	msg := "election," + strconv.Itoa(module.id)
+	for i := module.id + 1; i < len(module.addresses); i++ {
+		module.send(i, msg)
+	}
+	module.deadline = time.Now().Add(Timeout)
+}
+
+func (module *LeaderElection_Module) handleBully(kind string, from int) {
+	switch kind {
+	case "election":
+		if from < module.id {
+			module.send(from, "ok,"+strconv.Itoa(module.id))
+			if !module.electing {
+				module.startBully()
+			}
+		}
+	case "ok":
+		if module.electing && from > module.id && !module.gotOk {
+			module.gotOk = true
+			module.deadline = time.Now().Add(2 * Timeout)
+		}
+	}
+}
+
+func (module *LeaderElection_Module) bullyTimeout() {
+	if !module.gotOk {
+		module.becomeLeader()
+		return
+	}
+	module.outDbg("sem coordinator apos ok")
+	module.startBully()
+}
@@ -0,0 +1,265 @@
+/*
+  Modulo de eleicao de lider, no padrao Req/Ind do DIMEX, sobre um PP2PLink proprio
+  (o modulo le Ind e Events do link: nao compartilhe o link com outro modulo).
+  Algoritmos:
+    Bully - o maior id entre os vivos vence (Garcia-Molina)            Bully.go
+    Ring  - a eleicao percorre o anel recolhendo os vivos; vence o maior  Ring.go
+  Detector de falhas:
+    - o lider envia "alive" a todos a cada Heartbeat; sem alive por Timeout o lider e' suspeito
+    - eventos das conexoes de saida do PP2PLink: ConnDown marca o processo como suspeito,
+      ConnUp (ou qualquer mensagem recebida dele) tira a suspeita
+  Quando o lider e' suspeito (ou a aplicacao pede com START), inicia uma eleicao.
+  Cada troca de lider e' informada no canal Ind.
+  Mensagens (id = quem envia):
+     election,<id>   ok,<id>   alive,<id>   coordinator,<lider>
+     ring,<id>,<iniciador>,<vivos separados por '.'>
+  Um coordinator com lider menor que este processo (que esta vivo) ou um alive de
+  quem nao e' o lider indicam visoes divergentes: inicia nova eleicao. Um coordinator
+  com lider menor que o atual, nao suspeito, e' ignorado.
+*/
+
+package Election
+
+import (
+	PP2PLink "SD/PP2PLink"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Algorithm int
+
+const (
+	Bully Algorithm = iota
+	Ring
+)
+
+type electionReq int
+
+const (
+	START electionReq = iota // inicia uma eleicao
+)
+
+type LeaderElection_Ind_Message struct {
+	Leader int // id do novo lider
+}
+
+const (
+	Heartbeat = 300 * time.Millisecond // intervalo dos "alive" do lider
+	Timeout   = 1 * time.Second        // sem alive do lider ou sem resposta na eleicao
+	tick      = 50 * time.Millisecond
+)
+
+type LeaderElection_Module struct {
+	Req       chan electionReq
+	Ind       chan LeaderElection_Ind_Message
+	addresses []string
+	id        int
+	dbg       bool
+	algorithm Algorithm
+
+	leader      int        // -1 enquanto nao conhecido; escrito so pela rotina do modulo
+	leaderMutex sync.Mutex // protege a escrita de leader e a leitura em Leader() (outras rotinas)
+	electing    bool       // eleicao em andamento iniciada ou repassada por este processo
+	gotOk       bool       // bully: algum processo maior respondeu
+	deadline    time.Time  // fim da espera atual da eleicao
+	lastAlive   time.Time  // ultimo alive do lider
+	suspected   []bool     // por processo: suspeito de falha
+	ringNext    int        // ring: para quem foi repassada a ultima mensagem
+	ringMsg     string     // ring: ultima mensagem repassada (reenviada ao proximo se ringNext falhar)
+
+	Pp2plink *PP2PLink.PP2PLink
+}
+
+func NewLeaderElection(_addresses []string, _id int, _dbg bool, _algorithm Algorithm, _link *PP2PLink.PP2PLink) *LeaderElection_Module {
+	le := &LeaderElection_Module{
+		Req:       make(chan electionReq, 1),
+		Ind:       make(chan LeaderElection_Ind_Message, 10),
+		addresses: _addresses,
+		id:        _id,
+		dbg:       _dbg,
+		algorithm: _algorithm,
+		leader:    -1,
+		suspected: make([]bool, len(_addresses)),
+		ringNext:  -1,
+		Pp2plink:  _link}
+	le.Start()
+	le.outDbg("Init LeaderElection!")
+	return le
+}
+
+func (module *LeaderElection_Module) Start() {
+	go func() {
+		ticker := time.NewTicker(tick)
+		defer ticker.Stop()
+		lastBeat := time.Time{}
+		module.startElection() // processo que entra (ou volta) sempre inicia uma eleicao
+		for {
+			select {
+			case req := <-module.Req:
+				if req == START {
+					module.startElection()
+				}
+			case msg := <-module.Pp2plink.Ind:
+				module.handleMessage(msg)
+			case ev := <-module.Pp2plink.Events:
+				module.handleConnEvent(ev)
+			case now := <-ticker.C:
+				if module.leader == module.id && now.Sub(lastBeat) >= Heartbeat {
+					lastBeat = now
+					module.sendToOthers("alive," + strconv.Itoa(module.id))
+				}
+				module.checkTimeouts(now)
+			}
+		}
+	}()
+}
+
+func (module *LeaderElection_Module) handleMessage(msg PP2PLink.PP2PLink_Ind_Message) {
+	parts := strings.Split(msg.Message, ",")
+	if len(parts) < 2 {
+		module.outDbg("descartada: " + msg.Message)
+		return
+	}
+	from, err := strconv.Atoi(parts[1])
+	if err != nil || from < 0 || from >= len(module.addresses) {
+		module.outDbg("descartada: " + msg.Message)
+		return
+	}
+	module.suspected[from] = false
+	switch parts[0] {
+	case "alive":
+		if from == module.leader {
+			module.lastAlive = time.Now()
+		} else if !module.electing {
+			module.startElection()
+		}
+	case "coordinator":
+		if from < module.id {
+			module.startElection()
+			return
+		}
+		if module.leader > from && !module.suspected[module.leader] && time.Since(module.lastAlive) <= Timeout {
+			return // eleicao concorrente que nao viu o lider atual, ainda vivo
+		}
+		module.electing = false
+		module.ringMsg = ""
+		module.setLeader(from)
+	case "election", "ok":
+		module.handleBully(parts[0], from)
+	case "ring":
+		module.handleRing(parts)
+	}
+}
+
+func (module *LeaderElection_Module) handleConnEvent(ev PP2PLink.PP2PLink_Conn_Event) {
+	p := module.indexOf(ev.Peer)
+	if !ev.Outgoing || p < 0 || p == module.id { // entrada fecha tambem por inatividade do par
+		return
+	}
+	switch ev.Kind {
+	case PP2PLink.ConnDown:
+		module.suspected[p] = true
+		module.outDbg("suspeito: " + strconv.Itoa(p))
+		if p == module.leader {
+			module.startElection()
+		} else if module.algorithm == Ring && p == module.ringNext && module.ringMsg != "" {
+			module.forwardRing(module.ringMsg) // repassa ao proximo vivo do anel
+		}
+	case PP2PLink.ConnUp:
+		module.suspected[p] = false
+	}
+}
+
+func (module *LeaderElection_Module) checkTimeouts(now time.Time) {
+	if module.leader >= 0 && module.leader != module.id && !module.suspected[module.leader] &&
+		now.Sub(module.lastAlive) > Timeout {
+		module.outDbg("lider " + strconv.Itoa(module.leader) + " sem alive")
+		module.suspected[module.leader] = true
+		if !module.electing {
+			module.startElection()
+			return
+		}
+	}
+	if module.electing && now.After(module.deadline) {
+		module.electionTimeout()
+	}
+}
+
+func (module *LeaderElection_Module) startElection() {
+	module.outDbg("inicia eleicao")
+	if module.algorithm == Ring {
+		module.startRing()
+	} else {
+		module.startBully()
+	}
+}
+
+func (module *LeaderElection_Module) electionTimeout() {
+	if module.algorithm == Ring {
+		module.startRing() // a mensagem se perdeu no anel: recomeca
+	} else {
+		module.bullyTimeout()
+	}
+}
+
+// becomeLeader anuncia este processo como lider a todos
+func (module *LeaderElection_Module) becomeLeader() {
+	module.electing = false
+	module.ringMsg = ""
+	module.sendToOthers("coordinator," + strconv.Itoa(module.id))
+	module.setLeader(module.id)
+}
+
+func (module *LeaderElection_Module) setLeader(leader int) {
+	module.lastAlive = time.Now()
+	if leader == module.leader {
+		return
+	}
+	module.leaderMutex.Lock()
+	module.leader = leader
+	module.leaderMutex.Unlock()
+	module.outDbg("novo lider: " + strconv.Itoa(leader))
+	module.Ind <- LeaderElection_Ind_Message{Leader: leader}
+}
+
+// Leader devolve o lider conhecido (-1 se nenhum); use apenas para consulta
+func (module *LeaderElection_Module) Leader() int {
+	module.leaderMutex.Lock()
+	defer module.leaderMutex.Unlock()
+	return module.leader
+}
+
+func (module *LeaderElection_Module) send(to int, content string) {
+	module.Pp2plink.Req <- PP2PLink.PP2PLink_Req_Message{
+		To:      module.addresses[to],
+		Message: content}
+}
+
+func (module *LeaderElection_Module) sendToOthers(content string) {
+	batch := make([]PP2PLink.PP2PLink_Req_Message, 0, len(module.addresses))
+	for i, address := range module.addresses {
+		if i == module.id {
+			continue
+		}
+		batch = append(batch, PP2PLink.PP2PLink_Req_Message{To: address, Message: content})
+	}
+	module.Pp2plink.ReqBatch <- batch
+}
+
+func (module *LeaderElection_Module) indexOf(address string) int {
+	for i, a := range module.addresses {
+		if a == address {
+			return i
+		}
+	}
+	return -1
+}
+
+func (module *LeaderElection_Module) outDbg(s string) {
+	if module.dbg {
+		fmt.Println(". . . . . . . . . . . . [ Eleicao " + strconv.Itoa(module.id) + " : " + s + " ]")
+	}
+}
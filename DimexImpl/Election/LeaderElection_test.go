@@ -0,0 +1,45 @@
+package Election
+
+import (
+	PP2PLink "SD/PP2PLink"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// Leader() e' lido pelo teste enquanto as rotinas dos modulos elegem (rodar com -race)
+func TestLeaderConcurrentRead(t *testing.T) {
+	for _, algorithm := range []Algorithm{Bully, Ring} {
+		dir := t.TempDir()
+		addrs := make([]string, 3)
+		for i := range addrs {
+			addrs[i] = "unix://" + filepath.Join(dir, "p"+strconv.Itoa(i)+".sock")
+		}
+		modules := make([]*LeaderElection_Module, len(addrs))
+		for i := range addrs {
+			link, err := PP2PLink.NewPP2PLink(addrs[i], false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			modules[i] = NewLeaderElection(addrs, i, false, algorithm, link)
+			go func(m *LeaderElection_Module) {
+				for range m.Ind {
+				}
+			}(modules[i])
+		}
+
+		deadline := time.Now().Add(10 * time.Second)
+		for agreed := false; !agreed; time.Sleep(time.Millisecond) {
+			if time.Now().After(deadline) {
+				t.Fatalf("algoritmo %d: processos nao concordaram no lider 2", algorithm)
+			}
+			agreed = true
+			for _, m := range modules {
+				if m.Leader() != len(addrs)-1 {
+					agreed = false
+				}
+			}
+		}
+	}
+}
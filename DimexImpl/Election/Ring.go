@@ -0,0 +1,82 @@
+/*
+  Eleicao em anel: quem inicia envia ring ao proximo processo nao suspeito
+  (id+1, id+2, ... modulo n). Cada processo acrescenta seu id a lista de vivos e repassa.
+  Quando a mensagem volta ao iniciador, o maior id da lista e' o lider, anunciado
+  com coordinator a todos. Se o proximo cai (ConnDown) antes de repassar, a mensagem
+  vai ao seguinte; se ela se perde, o iniciador recomeca apos Timeout*n.
+*/
+
+package Election
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+func (module *LeaderElection_Module) startRing() {
+	module.electing = true
+	module.deadline = time.Now().Add(Timeout * time.Duration(len(module.addresses)))
+	id := strconv.Itoa(module.id)
+	module.forwardRing("ring," + id + "," + id + "," + id)
+}
+
+// forwardRing envia a mensagem ao proximo vivo do anel; sem nenhum, este processo e' o lider
+func (module *LeaderElection_Module) forwardRing(content string) {
+	n := len(module.addresses)
+	for k := 1; k < n; k++ {
+		next := (module.id + k) % n
+		if module.suspected[next] {
+			continue
+		}
+		module.ringNext = next
+		module.ringMsg = content
+		module.send(next, content)
+		return
+	}
+	module.becomeLeader()
+}
+
+func (module *LeaderElection_Module) handleRing(parts []string) {
+	if len(parts) != 4 {
+		module.outDbg("descartada: " + strings.Join(parts, ","))
+		return
+	}
+	initiator, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return
+	}
+	alive := strings.Split(parts[3], ".")
+	for _, s := range alive {
+		if p, err := strconv.Atoi(s); err == nil && p >= 0 && p < len(module.addresses) {
+			module.suspected[p] = false // estava vivo ao repassar
+		}
+	}
+	if initiator == module.id {
+		// deu a volta: o maior da lista e' o lider
+		leader := -1
+		for _, s := range alive {
+			if p, err := strconv.Atoi(s); err == nil && p > leader && p < len(module.addresses) {
+				leader = p
+			}
+		}
+		module.electing = false
+		module.ringMsg = ""
+		if leader == module.id {
+			module.becomeLeader()
+			return
+		}
+		module.sendToOthers("coordinator," + strconv.Itoa(leader))
+		module.setLeader(leader)
+		return
+	}
+	id := strconv.Itoa(module.id)
+	for _, s := range alive {
+		if s == id {
+			return // ja passou por aqui: o iniciador caiu, a eleicao dele nao termina
+		}
+	}
+	module.electing = true
+	module.deadline = time.Now().Add(Timeout * time.Duration(len(module.addresses)))
+	module.forwardRing("ring," + id + "," + parts[2] + "," + parts[3] + "." + id)
+}
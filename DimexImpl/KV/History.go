@@ -0,0 +1,110 @@
+/*
+  Historia das operacoes de clientes do KV, para verificar linearizabilidade.
+  Cada operacao e' gravada com os instantes de chamada e de retorno (relogio da maquina:
+  historias de processos diferentes so sao comparaveis se os relogios estao sincronizados,
+  ex: todos na mesma maquina). Uma operacao sem retorno (Return == 0) pode ter tido efeito
+  ou nao; o verificador a trata como terminando depois de todas as outras.
+  Formato do arquivo: uma operacao JSON por linha.
+*/
+
+package KV
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+type Operation struct {
+	Client string `json:"client"`
+	Op     string `json:"op"` // get, put, add
+	Key    string `json:"key"`
+	Input  string `json:"in,omitempty"`
+	Output string `json:"out,omitempty"`
+	Call   int64  `json:"call"` // unix nano
+	Return int64  `json:"ret"`  // unix nano, 0 se nao retornou
+}
+
+// History grava operacoes de varios clientes concorrentes
+type History struct {
+	mutex sync.Mutex
+	ops   []*Operation
+}
+
+func NewHistory() *History {
+	return &History{}
+}
+
+// Call registra o inicio de uma operacao; complete com Return
+func (h *History) Call(client string, op KV_Op, key string, input string) *Operation {
+	o := &Operation{Client: client, Op: op.String(), Key: key, Input: input, Call: time.Now().UnixNano()}
+	if op == GET {
+		o.Input = ""
+	}
+	h.mutex.Lock()
+	h.ops = append(h.ops, o)
+	h.mutex.Unlock()
+	return o
+}
+
+func (h *History) Return(o *Operation, output string) {
+	now := time.Now().UnixNano()
+	h.mutex.Lock()
+	o.Output = output
+	o.Return = now
+	h.mutex.Unlock()
+}
+
+// Operations devolve uma copia das operacoes gravadas ate agora
+func (h *History) Operations() []Operation {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	ops := make([]Operation, len(h.ops))
+	for i, o := range h.ops {
+		ops[i] = *o
+	}
+	return ops
+}
+
+func (h *History) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	n := int64(0)
+	for _, o := range h.Operations() {
+		if err := enc.Encode(o); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, bw.Flush()
+}
+
+func (h *History) WriteFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := h.WriteTo(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ReadHistory le as operacoes gravadas por WriteTo
+func ReadHistory(r io.Reader) ([]Operation, error) {
+	var ops []Operation
+	dec := json.NewDecoder(r)
+	for {
+		var o Operation
+		if err := dec.Decode(&o); err == io.EOF {
+			return ops, nil
+		} else if err != nil {
+			return ops, err
+		}
+		ops = append(ops, o)
+	}
+}
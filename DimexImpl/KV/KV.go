@@ -0,0 +1,369 @@
+/*
+  Armazenamento chave-valor replicado, exemplo de uso do DIMEX.
+  Cada processo guarda uma replica completa. Leituras sao locais; escritas (PUT e ADD)
+  tomam o lock DIMEX da chave e so terminam quando todas as replicas aplicaram:
+     1. escritor: entra na SC do stripe da chave (DIMEX)
+     2. escritor -> todos: prep (a chave fica pendente na replica)   <- pack de cada um
+     3. escritor -> todos: commit (replica aplica o valor)            <- cack de cada um
+     4. escritor: sai da SC
+  Uma leitura de chave pendente espera o commit: quem viu o valor novo numa replica
+  garante que as demais ja tem a chave pendente, entao nenhuma leitura posterior
+  devolve o valor antigo (as historias sao linearizaveis, ver Linearizability.go).
+  Lock stripes: a chave vai para o stripe hash(chave) % stripes; cada stripe e' um
+  DIMEX_Module com PP2PLink proprio (ver StripeAddress). Escritas em stripes diferentes
+  andam em paralelo. Os enderecos dos stripes nao podem coincidir com os dos processos
+  nem entre si (ex: processos nas portas 5000 e 5001 com 4 stripes): NewKV recusa.
+  Exemplo: escritas esperam resposta de todas as replicas; uma replica que falha
+  trava as escritas (e uma chave fica pendente se o escritor falha entre prep e commit).
+  Mensagens de replicacao (chaves nao podem conter '|'; o valor vai por ultimo):
+     prep|<id>|<op>|<chave>|<valor>   pack|<id>|<op>
+     commit|<id>|<op>|<chave>          cack|<id>|<op>
+*/
+
+package KV
+
+import (
+	"SD/Broadcast"
+	"SD/DIMEX"
+	PP2PLink "SD/PP2PLink"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type KV_Op int
+
+const (
+	GET KV_Op = iota
+	PUT       // grava Value
+	ADD       // soma Value (inteiro) ao valor atual, chave ausente vale 0
+)
+
+func (op KV_Op) String() string {
+	switch op {
+	case GET:
+		return "get"
+	case PUT:
+		return "put"
+	case ADD:
+		return "add"
+	}
+	return "op" + strconv.Itoa(int(op))
+}
+
+type KV_Req_Message struct {
+	Id    int // escolhido pela aplicacao, volta na resposta (varios pedidos podem estar em andamento)
+	Op    KV_Op
+	Key   string
+	Value string
+}
+
+type KV_Ind_Message struct {
+	Id    int
+	Op    KV_Op
+	Key   string
+	Value string // GET: valor lido; PUT e ADD: valor gravado
+	Err   error
+}
+
+type KV_Module struct {
+	Req       chan KV_Req_Message
+	Ind       chan KV_Ind_Message
+	addresses []string
+	id        int
+	dbg       bool
+
+	mutex   sync.Mutex
+	changed *sync.Cond        // sinaliza commits para leituras esperando chave pendente
+	data    map[string]string // replica local
+	pending map[string]string // chave -> valor da escrita preparada e ainda nao confirmada
+
+	stripes   []*DIMEX.DIMEX_Module
+	stripeMtx []sync.Mutex // DIMEX atende um pedido por vez: escritas locais no mesmo stripe esperam aqui
+
+	opMutex sync.Mutex
+	nextOp  int
+	acks    map[string]chan int // op -> canal que recebe os pack/cack
+
+	Pp2plink *PP2PLink.PP2PLink // replicacao: prep, commit e acks
+}
+
+func NewKV(_addresses []string, _id int, _stripes int, _dbg bool) (*KV_Module, error) {
+	if _stripes < 1 {
+		return nil, fmt.Errorf("numero de stripes invalido: %d", _stripes)
+	}
+	stripeAddrs, err := stripeAddresses(_addresses, _stripes)
+	if err != nil {
+		return nil, err
+	}
+	link, err := PP2PLink.NewPP2PLink(_addresses[_id], _dbg)
+	if err != nil {
+		return nil, err
+	}
+	kv := &KV_Module{
+		Req:       make(chan KV_Req_Message),
+		Ind:       make(chan KV_Ind_Message, 100),
+		addresses: _addresses,
+		id:        _id,
+		dbg:       _dbg,
+		data:      make(map[string]string),
+		pending:   make(map[string]string),
+		stripeMtx: make([]sync.Mutex, _stripes),
+		acks:      make(map[string]chan int),
+		Pp2plink:  link}
+	kv.changed = sync.NewCond(&kv.mutex)
+
+	sink := DIMEX.NewMemorySnapshotSink() // o KV nao pede snapshots
+	for s, addrs := range stripeAddrs {
+		dmx, err := DIMEX.NewDIMEX(addrs, _id, _dbg, sink)
+		if err != nil {
+			// libera os enderecos ja ocupados, para quem chama poder tentar de novo
+			for _, started := range kv.stripes {
+				started.Close()
+			}
+			link.Close()
+			return nil, fmt.Errorf("stripe %d: %w", s, err)
+		}
+		kv.stripes = append(kv.stripes, dmx)
+	}
+	kv.Start()
+	kv.outDbg("Init KV!")
+	return kv, nil
+}
+
+// StripeAddress e' o endereco do DIMEX do stripe s de um processo:
+// porta + 1 + s (host:porta, tcp://...) ou caminho.s<s> (unix://...)
+func StripeAddress(addr string, s int) (string, error) {
+	prefix, rest := "", addr
+	if i := strings.Index(addr, "://"); i >= 0 {
+		prefix, rest = addr[:i+3], addr[i+3:]
+	}
+	if prefix == "unix://" {
+		return addr + ".s" + strconv.Itoa(s), nil
+	}
+	host, port, err := net.SplitHostPort(rest)
+	if err != nil {
+		return "", err
+	}
+	p, err := strconv.Atoi(port)
+	if err != nil {
+		return "", fmt.Errorf("endereco %q: porta invalida", addr)
+	}
+	return prefix + net.JoinHostPort(host, strconv.Itoa(p+1+s)), nil
+}
+
+// stripeAddresses devolve, por stripe, os enderecos de todos os processos, e recusa
+// enderecos repetidos entre processos e stripes
+func stripeAddresses(addresses []string, stripes int) ([][]string, error) {
+	owner := make(map[string]string)
+	for i, a := range addresses {
+		if other, ok := owner[a]; ok {
+			return nil, fmt.Errorf("endereco %s repetido: %s e processo %d", a, other, i)
+		}
+		owner[a] = "processo " + strconv.Itoa(i)
+	}
+	result := make([][]string, stripes)
+	for s := range result {
+		result[s] = make([]string, len(addresses))
+		for i, a := range addresses {
+			addr, err := StripeAddress(a, s)
+			if err != nil {
+				return nil, err
+			}
+			name := fmt.Sprintf("stripe %d do processo %d", s, i)
+			if other, ok := owner[addr]; ok {
+				return nil, fmt.Errorf("%s usaria %s, que e' de %s: afaste as portas dos processos", name, addr, other)
+			}
+			owner[addr] = name
+			result[s][i] = addr
+		}
+	}
+	return result, nil
+}
+
+func (module *KV_Module) Start() {
+	go func() {
+		for req := range module.Req {
+			if req.Op == GET {
+				go module.get(req)
+			} else {
+				go module.write(req)
+			}
+		}
+	}()
+	go func() {
+		for msg := range module.Pp2plink.Ind {
+			module.handleMessage(msg.Message)
+		}
+	}()
+}
+
+// ------------------------------------------------------------------------------------
+// ------- operacoes da aplicacao
+// ------------------------------------------------------------------------------------
+
+func (module *KV_Module) get(req KV_Req_Message) {
+	module.mutex.Lock()
+	for {
+		if _, ok := module.pending[req.Key]; !ok {
+			break
+		}
+		module.changed.Wait()
+	}
+	value := module.data[req.Key]
+	module.mutex.Unlock()
+	module.Ind <- KV_Ind_Message{Id: req.Id, Op: req.Op, Key: req.Key, Value: value}
+}
+
+func (module *KV_Module) write(req KV_Req_Message) {
+	ind := KV_Ind_Message{Id: req.Id, Op: req.Op, Key: req.Key}
+	if strings.Contains(req.Key, "|") {
+		ind.Err = errors.New("chave nao pode conter '|': " + req.Key)
+		module.Ind <- ind
+		return
+	}
+	var delta int
+	if req.Op == ADD {
+		d, err := strconv.Atoi(req.Value)
+		if err != nil {
+			ind.Err = fmt.Errorf("ADD precisa de inteiro: %w", err)
+			module.Ind <- ind
+			return
+		}
+		delta = d
+	}
+
+	s := module.stripeOf(req.Key)
+	module.stripeMtx[s].Lock()
+	defer module.stripeMtx[s].Unlock()
+	dmx := module.stripes[s]
+	dmx.Req <- DIMEX.ENTER
+	<-dmx.Ind
+
+	// com o lock da chave, a replica local tem a ultima escrita confirmada
+	module.mutex.Lock()
+	value := req.Value
+	if req.Op == ADD {
+		old := module.data[req.Key]
+		current, err := strconv.Atoi(old)
+		if err != nil && old != "" {
+			module.mutex.Unlock()
+			dmx.Req <- DIMEX.EXIT
+			ind.Err = fmt.Errorf("ADD em valor nao inteiro %q", old)
+			module.Ind <- ind
+			return
+		}
+		value = strconv.Itoa(current + delta)
+	}
+	op, acks := module.newOp()
+	module.pending[req.Key] = value
+	module.mutex.Unlock()
+
+	id := strconv.Itoa(module.id)
+	module.outDbg("escrita " + op + ": " + req.Key + " = " + value)
+	Broadcast.SendToOthers(module.Pp2plink, module.addresses, module.id, "prep|"+id+"|"+op+"|"+req.Key+"|"+value)
+	module.waitAcks(acks)
+	Broadcast.SendToOthers(module.Pp2plink, module.addresses, module.id, "commit|"+id+"|"+op+"|"+req.Key)
+	module.commit(req.Key, value)
+	module.waitAcks(acks)
+	module.doneOp(op)
+
+	dmx.Req <- DIMEX.EXIT
+	ind.Value = value
+	module.Ind <- ind
+}
+
+func (module *KV_Module) stripeOf(key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(len(module.stripes)))
+}
+
+func (module *KV_Module) newOp() (string, chan int) {
+	module.opMutex.Lock()
+	defer module.opMutex.Unlock()
+	module.nextOp++
+	op := strconv.Itoa(module.id) + "." + strconv.Itoa(module.nextOp)
+	acks := make(chan int, 2*len(module.addresses))
+	module.acks[op] = acks
+	return op, acks
+}
+
+func (module *KV_Module) doneOp(op string) {
+	module.opMutex.Lock()
+	defer module.opMutex.Unlock()
+	delete(module.acks, op)
+}
+
+// waitAcks espera um ack de cada um dos outros processos
+func (module *KV_Module) waitAcks(acks chan int) {
+	for i := 0; i < len(module.addresses)-1; i++ {
+		<-acks
+	}
+}
+
+// ------------------------------------------------------------------------------------
+// ------- replica
+// ------------------------------------------------------------------------------------
+
+func (module *KV_Module) handleMessage(m string) {
+	parts := strings.SplitN(m, "|", 5)
+	if len(parts) < 3 {
+		module.outDbg("descartada: " + m)
+		return
+	}
+	from, err := strconv.Atoi(parts[1])
+	if err != nil || from < 0 || from >= len(module.addresses) {
+		module.outDbg("descartada: " + m)
+		return
+	}
+	id := strconv.Itoa(module.id)
+	op := parts[2]
+	switch {
+	case parts[0] == "prep" && len(parts) == 5:
+		module.mutex.Lock()
+		module.pending[parts[3]] = parts[4]
+		module.mutex.Unlock()
+		module.send(from, "pack|"+id+"|"+op)
+	case parts[0] == "commit" && len(parts) == 4:
+		module.mutex.Lock()
+		value := module.pending[parts[3]]
+		module.mutex.Unlock()
+		module.commit(parts[3], value)
+		module.send(from, "cack|"+id+"|"+op)
+	case parts[0] == "pack" || parts[0] == "cack":
+		module.opMutex.Lock()
+		acks, ok := module.acks[op]
+		module.opMutex.Unlock()
+		if ok {
+			acks <- from
+		}
+	default:
+		module.outDbg("descartada: " + m)
+	}
+}
+
+// commit aplica o valor e libera leituras que esperavam a chave
+func (module *KV_Module) commit(key string, value string) {
+	module.mutex.Lock()
+	module.data[key] = value
+	delete(module.pending, key)
+	module.mutex.Unlock()
+	module.changed.Broadcast()
+}
+
+func (module *KV_Module) send(to int, content string) {
+	module.Pp2plink.Req <- PP2PLink.PP2PLink_Req_Message{
+		To:      module.addresses[to],
+		Message: content}
+}
+
+func (module *KV_Module) outDbg(s string) {
+	if module.dbg {
+		fmt.Println(". . . . . . . . . . . . [ KV " + strconv.Itoa(module.id) + " : " + s + " ]")
+	}
+}
@@ -0,0 +1,57 @@
+package KV
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStripeAddresses(t *testing.T) {
+	tests := []struct {
+		name      string
+		addresses []string
+		stripes   int
+		conflict  string // trecho do erro; vazio se valido
+	}{
+		{"portas afastadas", []string{"127.0.0.1:5000", "127.0.0.1:6001", "127.0.0.1:7002"}, 4, ""},
+		{"portas vizinhas", []string{"127.0.0.1:5000", "127.0.0.1:5001"}, 4, "stripe 0 do processo 0"},
+		{"stripe na porta de outro processo", []string{"127.0.0.1:5000", "127.0.0.1:5003"}, 4, "stripe 2 do processo 0"},
+		{"hosts diferentes", []string{"10.0.0.1:5000", "10.0.0.2:5000"}, 4, ""},
+		{"unix", []string{"unix:///tmp/p0.sock", "unix:///tmp/p1.sock"}, 2, ""},
+		{"endereco repetido", []string{"127.0.0.1:5000", "127.0.0.1:5000"}, 1, "repetido"},
+	}
+	for _, tt := range tests {
+		addrs, err := stripeAddresses(tt.addresses, tt.stripes)
+		if tt.conflict == "" {
+			if err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+			} else if len(addrs) != tt.stripes || len(addrs[0]) != len(tt.addresses) {
+				t.Errorf("%s: enderecos %v", tt.name, addrs)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.conflict) {
+			t.Errorf("%s: erro %v, esperado conflito com %q", tt.name, err, tt.conflict)
+		}
+	}
+}
+
+// se um stripe nao sobe, NewKV fecha o link e os stripes que ja tinham subido
+// (fechar a escuta de um socket Unix apaga o arquivo)
+func TestNewKVReleasesOnStripeFailure(t *testing.T) {
+	dir := t.TempDir()
+	addrs := []string{"unix://" + filepath.Join(dir, "p0.sock"), "unix://" + filepath.Join(dir, "p1.sock")}
+	// arquivo comum no endereco do stripe 1 do processo 0: o bind falha
+	if err := os.WriteFile(filepath.Join(dir, "p0.sock.s1"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewKV(addrs, 0, 2, false); err == nil || !strings.Contains(err.Error(), "stripe 1") {
+		t.Fatalf("erro %v, esperado falha no stripe 1", err)
+	}
+	for _, path := range []string{"p0.sock", "p0.sock.s0"} {
+		if _, err := os.Stat(filepath.Join(dir, path)); !os.IsNotExist(err) {
+			t.Errorf("%s continua escutando (%v)", path, err)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+/*
+  Verificador de linearizabilidade de historias do KV (algoritmo de Wing & Gong com a
+  cache de estados de Lowe). Linearizabilidade e' local: cada chave e' verificada
+  separadamente, como um registrador que comeca vazio ("").
+    get      devolve o valor atual
+    put v    grava v
+    add d    grava atual + d (vazio vale 0) e devolve o valor gravado
+  Uma historia e' linearizavel se existe uma ordem total das operacoes, consistente com
+  a ordem de tempo real (quem retornou antes de outra ser chamada vem antes), em que
+  cada operacao devolve o que o registrador sequencial devolveria.
+  Operacoes sem retorno podem ser colocadas depois de todas; a saida delas e' ignorada.
+*/
+
+package KV
+
+import (
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+)
+
+type KeyResult struct {
+	Key          string
+	Ops          int
+	Linearizable bool
+	Order        []Operation // uma linearizacao (se linearizavel)
+}
+
+// CheckLinearizable verifica cada chave; devolve os resultados ordenados por chave
+func CheckLinearizable(ops []Operation) []KeyResult {
+	byKey := make(map[string][]Operation)
+	for _, o := range ops {
+		byKey[o.Key] = append(byKey[o.Key], o)
+	}
+	keys := make([]string, 0, len(byKey))
+	for k := range byKey {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	results := make([]KeyResult, 0, len(keys))
+	for _, k := range keys {
+		order, ok := checkKey(byKey[k])
+		results = append(results, KeyResult{Key: k, Ops: len(byKey[k]), Linearizable: ok, Order: order})
+	}
+	return results
+}
+
+// step aplica a operacao ao estado; ok se a saida gravada e' a esperada
+func step(state string, o Operation) (string, bool) {
+	switch o.Op {
+	case "get":
+		return state, o.Return == 0 || o.Output == state
+	case "put":
+		return o.Input, true
+	case "add":
+		current, err := strconv.Atoi(state)
+		if err != nil && state != "" {
+			return state, false
+		}
+		delta, err := strconv.Atoi(o.Input)
+		if err != nil {
+			return state, false
+		}
+		next := strconv.Itoa(current + delta)
+		return next, o.Return == 0 || o.Output == next
+	}
+	return state, false
+}
+
+type event struct {
+	op         int
+	call       bool
+	time       int64
+	match      *event // chamada -> retorno
+	prev, next *event
+}
+
+func checkKey(ops []Operation) ([]Operation, bool) {
+	events := make([]*event, 0, 2*len(ops))
+	for i, o := range ops {
+		ret := o.Return
+		if ret == 0 {
+			ret = math.MaxInt64
+		}
+		c := &event{op: i, call: true, time: o.Call}
+		r := &event{op: i, time: ret}
+		c.match = r
+		events = append(events, c, r)
+	}
+	// chamada antes de retorno no mesmo instante: operacoes tocando-se sao concorrentes
+	sort.SliceStable(events, func(i, j int) bool {
+		if events[i].time != events[j].time {
+			return events[i].time < events[j].time
+		}
+		return events[i].call && !events[j].call
+	})
+	head := &event{}
+	last := head
+	for _, e := range events {
+		e.prev = last
+		last.next = e
+		last = e
+	}
+
+	type frame struct {
+		e     *event
+		state string
+	}
+	var stack []frame
+	linearized := newBitset(len(ops))
+	cache := newStateCache()
+	state := ""
+
+	e := head.next
+	for head.next != nil {
+		if e.call {
+			next, ok := step(state, ops[e.op])
+			if ok {
+				linearized.set(e.op)
+				if cache.add(linearized, next) {
+					stack = append(stack, frame{e, state})
+					state = next
+					lift(e)
+					e = head.next
+					continue
+				}
+				linearized.clear(e.op)
+			}
+			e = e.next
+		} else {
+			// um retorno antes de linearizar sua chamada: desfaz a ultima escolha
+			if len(stack) == 0 {
+				return nil, false
+			}
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			state = top.state
+			linearized.clear(top.e.op)
+			unlift(top.e)
+			e = top.e.next
+		}
+	}
+	order := make([]Operation, len(stack))
+	for i, f := range stack {
+		order[i] = ops[f.e.op]
+	}
+	return order, true
+}
+
+// lift retira a chamada e o retorno da lista; unlift os recoloca
+func lift(c *event) {
+	c.prev.next = c.next
+	if c.next != nil {
+		c.next.prev = c.prev
+	}
+	r := c.match
+	r.prev.next = r.next
+	if r.next != nil {
+		r.next.prev = r.prev
+	}
+}
+
+func unlift(c *event) {
+	r := c.match
+	r.prev.next = r
+	if r.next != nil {
+		r.next.prev = r
+	}
+	c.prev.next = c
+	if c.next != nil {
+		c.next.prev = c
+	}
+}
+
+// bitset das operacoes ja linearizadas, com hash atualizado a cada set/clear
+type bitset struct {
+	words []uint64
+	hash  uint64
+	salt  []uint64 // valor aleatorio por operacao: hash = xor dos salts presentes
+}
+
+func newBitset(n int) *bitset {
+	r := rand.New(rand.NewSource(1))
+	salt := make([]uint64, n)
+	for i := range salt {
+		salt[i] = r.Uint64()
+	}
+	return &bitset{words: make([]uint64, (n+63)/64), salt: salt}
+}
+
+func (b *bitset) set(i int) {
+	b.words[i/64] |= 1 << (uint(i) % 64)
+	b.hash ^= b.salt[i]
+}
+
+func (b *bitset) clear(i int) {
+	b.words[i/64] &^= 1 << (uint(i) % 64)
+	b.hash ^= b.salt[i]
+}
+
+func (b *bitset) equal(words []uint64) bool {
+	for i, w := range b.words {
+		if w != words[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// stateCache guarda as configuracoes (linearizadas, estado) ja exploradas
+type stateCache struct {
+	entries map[uint64][]cacheEntry
+}
+
+type cacheEntry struct {
+	words []uint64
+	state string
+}
+
+func newStateCache() *stateCache {
+	return &stateCache{entries: make(map[uint64][]cacheEntry)}
+}
+
+// add devolve false se a configuracao ja foi explorada
+func (c *stateCache) add(b *bitset, state string) bool {
+	h := fnv.New64a()
+	h.Write([]byte(state))
+	key := b.hash ^ h.Sum64()
+	for _, e := range c.entries[key] {
+		if e.state == state && b.equal(e.words) {
+			return false
+		}
+	}
+	c.entries[key] = append(c.entries[key], cacheEntry{append([]uint64(nil), b.words...), state})
+	return true
+}
@@ -0,0 +1,138 @@
+package KV
+
+import "testing"
+
+func op(kind, in, out string, call, ret int64) Operation {
+	return Operation{Op: kind, Key: "k", Input: in, Output: out, Call: call, Return: ret}
+}
+
+func TestCheckKey(t *testing.T) {
+	tests := []struct {
+		name string
+		ops  []Operation
+		want bool
+	}{
+		{"sequencial", []Operation{op("put", "1", "", 0, 10), op("get", "", "1", 20, 30)}, true},
+		{"leitura velha", []Operation{op("put", "1", "", 0, 10), op("get", "", "", 20, 30)}, false},
+		{"leitura concorrente ve o antigo", []Operation{op("put", "1", "", 0, 10), op("get", "", "", 5, 15)}, true},
+		{"leitura concorrente ve o novo", []Operation{op("put", "1", "", 0, 10), op("get", "", "1", 5, 15)}, true},
+		{"novo e depois antigo", []Operation{
+			op("put", "1", "", 0, 100), op("get", "", "1", 10, 20), op("get", "", "", 30, 40)}, false},
+		{"valor nunca escrito", []Operation{op("put", "1", "", 0, 10), op("get", "", "2", 5, 15)}, false},
+		{"adds concorrentes", []Operation{
+			op("add", "2", "2", 0, 10), op("add", "3", "5", 5, 15), op("get", "", "5", 20, 30)}, true},
+		{"adds que se perdem", []Operation{op("add", "2", "2", 0, 10), op("add", "3", "3", 5, 15)}, false},
+		{"add sobre valor nao inteiro", []Operation{op("put", "x", "", 0, 10), op("add", "1", "1", 20, 30)}, false},
+		{"escrita sem retorno vista depois", []Operation{
+			op("put", "1", "", 0, 0), op("get", "", "", 10, 20), op("get", "", "1", 30, 40)}, true},
+		{"escrita sem retorno desfeita", []Operation{
+			op("put", "1", "", 0, 0), op("get", "", "1", 10, 20), op("get", "", "", 30, 40)}, false},
+		{"operacoes tocando-se sao concorrentes", []Operation{op("put", "1", "", 0, 10), op("get", "", "", 10, 20)}, true},
+	}
+	for _, tt := range tests {
+		order, ok := checkKey(tt.ops)
+		if ok != tt.want {
+			t.Errorf("%s: linearizavel = %v, esperado %v", tt.name, ok, tt.want)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		// a ordem devolvida tem todas as operacoes e e' aceita pelo registrador sequencial
+		if len(order) != len(tt.ops) {
+			t.Errorf("%s: ordem com %d de %d operacoes", tt.name, len(order), len(tt.ops))
+		}
+		state := ""
+		for _, o := range order {
+			next, valid := step(state, o)
+			if !valid {
+				t.Errorf("%s: ordem devolvida invalida em %+v", tt.name, o)
+			}
+			state = next
+		}
+	}
+}
+
+func TestCheckLinearizableByKey(t *testing.T) {
+	ops := []Operation{
+		{Op: "put", Key: "b", Input: "1", Call: 0, Return: 10},
+		{Op: "get", Key: "b", Output: "", Call: 20, Return: 30}, // leitura velha em b
+		{Op: "put", Key: "a", Input: "1", Call: 0, Return: 10},
+		{Op: "get", Key: "a", Output: "1", Call: 20, Return: 30},
+	}
+	results := CheckLinearizable(ops)
+	if len(results) != 2 || results[0].Key != "a" || results[1].Key != "b" {
+		t.Fatalf("resultados: %+v", results)
+	}
+	if !results[0].Linearizable || results[1].Linearizable {
+		t.Errorf("a linearizavel = %v, b linearizavel = %v", results[0].Linearizable, results[1].Linearizable)
+	}
+}
+
+func TestLiftUnlift(t *testing.T) {
+	head := &event{}
+	var events []*event
+	for i := 0; i < 2; i++ {
+		c, r := &event{op: i, call: true}, &event{op: i}
+		c.match = r
+		events = append(events, c, r)
+	}
+	// c0 c1 r0 r1
+	order := []*event{events[0], events[2], events[1], events[3]}
+	last := head
+	for _, e := range order {
+		e.prev, last.next = last, e
+		last = e
+	}
+	list := func() []*event {
+		var l []*event
+		for e := head.next; e != nil; e = e.next {
+			l = append(l, e)
+			if e.next != nil && e.next.prev != e {
+				t.Fatal("prev inconsistente")
+			}
+		}
+		return l
+	}
+
+	lift(events[0])
+	if l := list(); len(l) != 2 || l[0] != events[2] || l[1] != events[3] {
+		t.Fatalf("depois de lift: %v", l)
+	}
+	unlift(events[0])
+	l := list()
+	if len(l) != len(order) {
+		t.Fatalf("depois de unlift: %d eventos", len(l))
+	}
+	for i := range order {
+		if l[i] != order[i] {
+			t.Fatalf("depois de unlift: evento %d fora do lugar", i)
+		}
+	}
+}
+
+func TestStateCache(t *testing.T) {
+	b := newBitset(70) // mais de uma palavra
+	cache := newStateCache()
+	b.set(0)
+	b.set(65)
+	if !cache.add(b, "1") {
+		t.Fatal("configuracao nova recusada")
+	}
+	if cache.add(b, "1") {
+		t.Fatal("configuracao repetida aceita")
+	}
+	if !cache.add(b, "2") {
+		t.Fatal("mesmas operacoes com outro estado recusadas")
+	}
+	b.clear(65)
+	b.set(1)
+	if !cache.add(b, "1") {
+		t.Fatal("outras operacoes com o mesmo estado recusadas")
+	}
+	b.clear(1)
+	b.set(65)
+	if cache.add(b, "1") {
+		t.Fatal("hash nao voltou ao valor anterior depois de clear")
+	}
+}
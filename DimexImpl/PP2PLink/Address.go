@@ -0,0 +1,102 @@
+/*
+  Enderecos com esquema e ganchos para abrir conexoes.
+     tcp://host:porta    TCP, IPv4 ou IPv6
+     tcp4://host:porta   so IPv4
+     tcp6://[::1]:porta  so IPv6
+     unix:///tmp/p0.sock socket Unix (clusters locais densos)
+     host:porta          sem esquema: como antes, escuta em tcp4 e conecta em tcp
+  O endereco completo (com esquema) continua sendo o identificador do processo
+  (destino em Req, From em Ind, chave da cache). As opcoes Listen e Dial permitem
+  trocar como escutar e conectar (ex: conexoes instrumentadas em testes).
+*/
+
+package PP2PLink
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// ListenFunc escuta num endereco ja separado do esquema (ex: "tcp4", "127.0.0.1:5000")
+type ListenFunc func(network string, address string) (net.Listener, error)
+
+// DialFunc conecta num endereco ja separado do esquema; o prazo vem no contexto
+type DialFunc func(ctx context.Context, network string, address string) (net.Conn, error)
+
+// ParseAddress separa esquema e endereco; listen indica a rede usada sem esquema
+func ParseAddress(addr string, listen bool) (network string, address string, err error) {
+	i := strings.Index(addr, "://")
+	if i < 0 {
+		if listen {
+			return "tcp4", addr, nil
+		}
+		return "tcp", addr, nil
+	}
+	scheme, rest := addr[:i], addr[i+3:]
+	switch scheme {
+	case "tcp", "tcp4", "tcp6", "unix":
+	default:
+		return "", "", fmt.Errorf("endereco %q: esquema %q nao suportado", addr, scheme)
+	}
+	if rest == "" {
+		return "", "", fmt.Errorf("endereco %q vazio", addr)
+	}
+	return scheme, rest, nil
+}
+
+func defaultListen(network string, address string) (net.Listener, error) {
+	if network == "unix" {
+		// socket deixado por uma execucao anterior impede o bind
+		if info, err := os.Stat(address); err == nil && info.Mode()&os.ModeSocket != 0 {
+			os.Remove(address)
+		}
+	}
+	return net.Listen(network, address)
+}
+
+// listen escuta no endereco do processo, com TLS se configurado
+func (module *PP2PLink) listen(addr string) (net.Listener, error) {
+	network, address, err := ParseAddress(addr, true)
+	if err != nil {
+		return nil, err
+	}
+	listen, err := module.listenFunc(network, address)
+	if err != nil {
+		return nil, err
+	}
+	if module.tls != nil {
+		listen = tls.NewListener(listen, module.tls)
+	}
+	return listen, nil
+}
+
+// dialAddr conecta no destino, com TLS se configurado
+func (module *PP2PLink) dialAddr(addr string) (net.Conn, error) {
+	network, address, err := ParseAddress(addr, false)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), module.dialTimeout)
+	defer cancel()
+	conn, err := module.dialFunc(ctx, network, address)
+	if err != nil || module.tls == nil {
+		return conn, err
+	}
+	cfg := module.tls.Clone()
+	if cfg.ServerName == "" {
+		cfg.ServerName = "localhost" // socket Unix: certificados de cluster local incluem localhost
+		if host, _, err := net.SplitHostPort(address); err == nil {
+			cfg.ServerName = host
+		}
+	}
+	tlsConn := tls.Client(conn, cfg)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
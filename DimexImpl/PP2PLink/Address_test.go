@@ -0,0 +1,56 @@
+package PP2PLink
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseAddress(t *testing.T) {
+	cases := []struct {
+		addr    string
+		listen  bool
+		network string
+		address string
+		err     string // trecho do erro; vazio se valido
+	}{
+		{"127.0.0.1:5000", true, "tcp4", "127.0.0.1:5000", ""},
+		{"127.0.0.1:5000", false, "tcp", "127.0.0.1:5000", ""},
+		{"tcp://127.0.0.1:5000", true, "tcp", "127.0.0.1:5000", ""},
+		{"tcp4://127.0.0.1:5000", false, "tcp4", "127.0.0.1:5000", ""},
+		{"tcp6://[::1]:5000", true, "tcp6", "[::1]:5000", ""},
+		{"tcp6://[::1]:5000", false, "tcp6", "[::1]:5000", ""},
+		{"unix:///tmp/p0.sock", true, "unix", "/tmp/p0.sock", ""},
+		{"unix://p0.sock", false, "unix", "p0.sock", ""},
+		{"udp://127.0.0.1:5000", true, "", "", `esquema "udp" nao suportado`},
+		{"http://127.0.0.1:5000", false, "", "", `esquema "http" nao suportado`},
+		{"://127.0.0.1:5000", false, "", "", `esquema "" nao suportado`},
+		{"unix://", true, "", "", "vazio"},
+		{"tcp://", false, "", "", "vazio"},
+	}
+	for _, c := range cases {
+		network, address, err := ParseAddress(c.addr, c.listen)
+		if c.err != "" {
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Errorf("%q: erro %v, esperado %q", c.addr, err, c.err)
+			}
+			continue
+		}
+		if err != nil || network != c.network || address != c.address {
+			t.Errorf("%q (listen=%v): %q %q %v, esperado %q %q", c.addr, c.listen, network, address, err, c.network, c.address)
+		}
+	}
+}
+
+// NewPP2PLink recusa esquema desconhecido em vez de escutar em outro lugar
+func TestListenRejectsBadScheme(t *testing.T) {
+	if _, err := NewPP2PLink("udp://127.0.0.1:0", false); err == nil || !strings.Contains(err.Error(), "nao suportado") {
+		t.Fatalf("erro %v, esperado esquema nao suportado", err)
+	}
+	addr := "unix://" + filepath.Join(t.TempDir(), "p0.sock")
+	link, err := NewPP2PLink(addr, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	link.Close()
+}
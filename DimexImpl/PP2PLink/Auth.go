@@ -0,0 +1,117 @@
+/*
+  Autenticacao de quadros com HMAC-SHA256 e chave compartilhada do cluster, alternativa
+  leve ao modo TLS: sem cifrar, impede que quem alcanca a porta injete ou repita mensagens.
+  Com AuthKey, cada quadro (que ja leva remetente, epoca e seq - ver Reliable.go) vai como
+     M|<hmac em hex>|<instante do envio, unixnano>|<quadro>
+  com o HMAC calculado sobre "<instante>|<quadro>". Retransmissoes sao assinadas de novo.
+  O receptor descarta:
+    - quadros sem HMAC ou com HMAC errado (Forged)
+    - quadros com instante fora da janela ReplayWindow, ou ja recebidos dentro dela (Replayed).
+      Cada envio e retransmissao tem instante proprio, entao um quadro assinado identico
+      so chega duas vezes se alguem o repetiu; o receptor guarda os HMACs da janela.
+  Quadros de dados de uma epoca do remetente mais antiga que a conhecida sao contados
+  a parte (Stale, ver Reliable.go): vem de uma encarnacao anterior, nao de um ataque.
+  A janela pressupoe relogios razoavelmente sincronizados entre os processos.
+*/
+
+package PP2PLink
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	frameAuth = "M"
+
+	defaultReplayWindow = 30 * time.Second
+)
+
+var (
+	errForged   = errors.New("quadro sem autenticacao valida")
+	errReplayed = errors.New("quadro fora da janela de tempo ou repetido")
+)
+
+// LoadAuthKey le a chave do cluster de um arquivo (espacos nas pontas sao ignorados)
+func LoadAuthKey(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key := []byte(strings.TrimSpace(string(data)))
+	if len(key) == 0 {
+		return nil, errors.New(path + ": chave vazia")
+	}
+	return key, nil
+}
+
+func (module *PP2PLink) mac(signed string) string {
+	h := hmac.New(sha256.New, module.authKey)
+	h.Write([]byte(signed))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// seal assina o quadro (sem chave devolve o quadro como esta)
+func (module *PP2PLink) seal(frame string) string {
+	if module.authKey == nil {
+		return frame
+	}
+	signed := strconv.FormatInt(time.Now().UnixNano(), 10) + "|" + frame
+	return frameAuth + "|" + module.mac(signed) + "|" + signed
+}
+
+// open confere a assinatura e a janela de tempo, conta os descartes e devolve o quadro interno
+func (module *PP2PLink) open(s string) (string, error) {
+	if module.authKey == nil {
+		return s, nil
+	}
+	parts := strings.SplitN(s, "|", 4)
+	if len(parts) != 4 || parts[0] != frameAuth {
+		count(&module.metrics.forged)
+		return "", errForged
+	}
+	signed := parts[2] + "|" + parts[3]
+	if !hmac.Equal([]byte(parts[1]), []byte(module.mac(signed))) {
+		count(&module.metrics.forged)
+		return "", errForged
+	}
+	sent, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		count(&module.metrics.forged)
+		return "", errForged
+	}
+	age := time.Since(time.Unix(0, sent))
+	if age > module.replayWindow || age < -module.replayWindow || module.seenBefore(parts[1], sent) {
+		count(&module.metrics.replayed)
+		return "", errReplayed
+	}
+	return parts[3], nil
+}
+
+// seenBefore anota o HMAC de um quadro autenticado e diz se ele ja tinha chegado.
+// HMACs mais antigos que a janela saem da lista: quadros tao velhos ja sao recusados pela idade
+func (module *PP2PLink) seenBefore(mac string, sent int64) bool {
+	module.seenMutex.Lock()
+	defer module.seenMutex.Unlock()
+	now := time.Now()
+	if now.Sub(module.lastPrune) > module.replayWindow {
+		oldest := now.Add(-module.replayWindow).UnixNano()
+		for m, at := range module.seen {
+			if at < oldest {
+				delete(module.seen, m)
+			}
+		}
+		module.lastPrune = now
+	}
+	if _, ok := module.seen[mac]; ok {
+		return true
+	}
+	module.seen[mac] = sent
+	return false
+}
@@ -0,0 +1,61 @@
+package PP2PLink
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func waitMetrics(t *testing.T, link *PP2PLink, done func(PP2PLink_Metrics) bool) PP2PLink_Metrics {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		m := link.Metrics()
+		if done(m) {
+			return m
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("metricas: %+v", m)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// um quadro autenticado repetido dentro da janela conta como Replayed (nao Duplicates);
+// um quadro de epoca anterior do remetente conta como Stale
+func TestAuthReplayAndStaleEpoch(t *testing.T) {
+	dir := t.TempDir()
+	key := []byte("chave do cluster")
+	addrA, addrS := "unix://"+filepath.Join(dir, "a.sock"), "unix://"+filepath.Join(dir, "s.sock")
+	a, err := NewPP2PLinkWithOptions(addrA, false, PP2PLink_Options{AuthKey: key})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender := newLink(addrS, false, PP2PLink_Options{AuthKey: key}) // so para assinar os quadros
+
+	conn, err := net.Dial("unix", filepath.Join(dir, "a.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	first := sender.seal(encodeData(5, addrS, 1, "m1"))
+	frames := []string{
+		first,
+		first, // repetido por quem capturou o quadro
+		sender.seal(encodeData(5, addrS, 1, "m1")), // retransmissao legitima: assinada de novo
+		sender.seal(encodeData(3, addrS, 1, "velha")),
+		sender.seal(encodeData(5, addrS, 2, "m2")),
+	}
+	for _, frame := range frames {
+		if err := writeFrame(conn, frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+	receive(t, a, "m1")
+	receive(t, a, "m2")
+	m := waitMetrics(t, a, func(m PP2PLink_Metrics) bool { return m.Delivered == 2 })
+	if m.Replayed != 1 || m.Duplicates != 1 || m.Stale != 1 || m.Forged != 0 {
+		t.Errorf("metricas: %+v", m)
+	}
+}
@@ -0,0 +1,244 @@
+/*
+  Gerencia o ciclo de vida das conexoes TCP do PP2PLink.
+    - redial com backoff exponencial (RedialMin, dobrando ate RedialMax, com variacao
+      aleatoria de 20%): enquanto o destino esta em backoff, quadros sao descartados
+      da fila e voltam pela retransmissao (Reliable.go)
+    - conexoes de saida sem uso por IdleTimeout sao fechadas (reabertas no proximo envio)
+    - conexao meio-aberta: TCP keepalive, prazo de escrita (WriteTimeout) e, se ha
+      mensagens sem ack ha mais de HalfOpenTimeout e nada (ack, dados ou heartbeat) veio
+      do destino nesse tempo, a conexao de saida e' descartada e reaberta. Um receptor
+      lento, que demora a entregar e por isso a confirmar, manda heartbeats (Reliable.go);
+      conexoes de entrada sem nada recebido por 2*IdleTimeout sao fechadas
+  Mudancas de estado sao publicadas no canal Events (sem bloquear: se ninguem le e o
+  canal enche, eventos sao descartados). Conexoes de saida publicam ConnUp ao abrir,
+  ConnDown ao falhar (escrita, dial ou meio-aberta) e ConnIdle ao fechar por inatividade.
+  Conexoes de entrada publicam ConnUp no primeiro quadro e ConnDown ao fechar.
+*/
+
+package PP2PLink
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"math/rand"
+	"net"
+	"time"
+)
+
+type PP2PLink_Conn_Kind int
+
+const (
+	ConnUp PP2PLink_Conn_Kind = iota
+	ConnDown
+	ConnIdle
+)
+
+func (k PP2PLink_Conn_Kind) String() string {
+	switch k {
+	case ConnUp:
+		return "up"
+	case ConnDown:
+		return "down"
+	case ConnIdle:
+		return "idle"
+	}
+	return "?"
+}
+
+type PP2PLink_Conn_Event struct {
+	Time     time.Time
+	Peer     string // endereco de escuta do outro processo
+	Kind     PP2PLink_Conn_Kind
+	Outgoing bool  // conexao aberta por este processo (envio) ou pelo outro (recepcao)
+	Err      error // causa de ConnDown, se conhecida
+}
+
+const (
+	defaultRedialMin    = 50 * time.Millisecond
+	defaultRedialMax    = 5 * time.Second
+	defaultIdleTimeout  = time.Minute
+	defaultWriteTimeout = 5 * time.Second
+	defaultKeepAlive    = 15 * time.Second
+	eventsBuffer        = 100
+	errBuffer           = 10
+	acceptRetryMin      = 5 * time.Millisecond
+	acceptRetryMax      = time.Second
+)
+
+// estado do destino, do ponto de vista das conexoes de saida
+const (
+	peerUnknown = iota
+	peerUp
+	peerDown
+)
+
+var (
+	errBackoff  = errors.New("destino em backoff")
+	errHalfOpen = errors.New("conexao meio-aberta: mensagens sem ack")
+)
+
+func durationOr(v time.Duration, def time.Duration) time.Duration {
+	if v <= 0 {
+		return def
+	}
+	return v
+}
+
+func (module *PP2PLink) publish(ev PP2PLink_Conn_Event) {
+	ev.Time = time.Now()
+	module.outDbg("conexao " + ev.Kind.String() + " : " + ev.Peer)
+	select {
+	case module.Events <- ev:
+	default:
+	}
+}
+
+// setState publica o evento se o estado do destino mudou
+func (module *PP2PLink) setState(d *destination, state int, kind PP2PLink_Conn_Kind, err error) {
+	if d.state == state {
+		return
+	}
+	d.state = state
+	module.publish(PP2PLink_Conn_Event{Peer: d.to, Kind: kind, Outgoing: true, Err: err})
+}
+
+// transmit coloca um quadro no buffer de escrita do destino, abrindo a conexao se preciso.
+// Falhas de dial colocam o destino em backoff; falhas de escrita fecham a conexao
+// (reaberta no proximo quadro). Quadros nao escritos ficam para a retransmissao.
+func (module *PP2PLink) transmit(d *destination, frame string) error {
+	if d.conn == nil {
+		if err := module.dial(d); err != nil {
+			return err
+		}
+	}
+	// buffer cheio escreve na conexao: prazo de escrita vale tambem aqui
+	d.conn.SetWriteDeadline(time.Now().Add(module.writeTimeout))
+	if err := writeFrame(d.w, module.seal(frame)); err != nil {
+		module.writeFailed(d, err)
+		return err
+	}
+	d.pending = true
+	return nil
+}
+
+// flush escreve na conexao os quadros acumulados no buffer
+func (module *PP2PLink) flush(d *destination) error {
+	if d.conn == nil || !d.pending {
+		return nil
+	}
+	d.pending = false
+	d.conn.SetWriteDeadline(time.Now().Add(module.writeTimeout))
+	if err := d.w.Flush(); err != nil {
+		module.writeFailed(d, err)
+		return err
+	}
+	return nil
+}
+
+func (module *PP2PLink) writeFailed(d *destination, err error) {
+	module.outDbg("erro : " + err.Error() + ". Conexao fechada.")
+	module.closeConn(d)
+	module.setState(d, peerDown, ConnDown, err)
+}
+
+func (module *PP2PLink) dial(d *destination) error {
+	if time.Now().Before(d.nextDial) {
+		return errBackoff
+	}
+	conn, err := module.dialAddr(d.to)
+	if err != nil {
+		module.outDbg("erro : " + err.Error())
+		d.backoff = 2 * d.backoff
+		if d.backoff < module.redialMin {
+			d.backoff = module.redialMin
+		}
+		if d.backoff > module.redialMax {
+			d.backoff = module.redialMax
+		}
+		jitter := time.Duration((rand.Float64()*0.4 - 0.2) * float64(d.backoff))
+		d.nextDial = time.Now().Add(d.backoff + jitter)
+		module.setState(d, peerDown, ConnDown, err)
+		return err
+	}
+	module.outDbg("ok   : conexao iniciada com outro processo")
+	d.conn = conn
+	d.w = bufio.NewWriterSize(conn, module.writeBufferSize)
+	d.backoff = 0
+	module.cacheMutex.Lock()
+	module.Cache[d.to] = conn
+	module.cacheMutex.Unlock()
+	module.setState(d, peerUp, ConnUp, nil)
+	return nil
+}
+
+func (module *PP2PLink) closeConn(d *destination) {
+	if d.conn == nil {
+		return
+	}
+	d.conn.Close()
+	d.conn = nil
+	d.w = nil
+	d.pending = false
+	module.cacheMutex.Lock()
+	delete(module.Cache, d.to)
+	module.cacheMutex.Unlock()
+}
+
+// evictIdle fecha a conexao de saida sem uso (chamado pela rotina escritora)
+func (module *PP2PLink) evictIdle(d *destination) {
+	if d.conn == nil {
+		return
+	}
+	module.outDbg("conexao sem uso com " + d.to + " fechada")
+	module.closeConn(d)
+	module.setState(d, peerUnknown, ConnIdle, nil)
+}
+
+// resetHalfOpen descarta a conexao de saida suspeita (chamado pela rotina escritora)
+func (module *PP2PLink) resetHalfOpen(d *destination) {
+	if d.conn == nil {
+		return
+	}
+	module.closeConn(d)
+	module.setState(d, peerDown, ConnDown, errHalfOpen)
+}
+
+// staleDestinations devolve os destinos com mensagem sem ack ha mais de HalfOpenTimeout
+// dos quais nada voltou nesse tempo: destino lento mas vivo nao e' meio-aberto
+func (module *PP2PLink) staleDestinations() []string {
+	module.relMutex.Lock()
+	defer module.relMutex.Unlock()
+	var stale []string
+	for to, st := range module.out {
+		if len(st.unacked) > 0 && time.Since(st.unacked[0].firstSent) > module.halfOpenTimeout &&
+			time.Since(st.heard) > module.halfOpenTimeout {
+			stale = append(stale, to)
+			st.unacked[0].firstSent = time.Now() // nova chance para a conexao reaberta
+		}
+	}
+	return stale
+}
+
+func (module *PP2PLink) checkHalfOpen() {
+	for _, to := range module.staleDestinations() {
+		d := module.destinationFor(to)
+		select {
+		case d.reset <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// prepareIncoming liga keepalive e prazo de leitura numa conexao aceita
+func (module *PP2PLink) prepareIncoming(conn net.Conn) {
+	raw := conn
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		raw = tlsConn.NetConn()
+	}
+	if tcp, ok := raw.(*net.TCPConn); ok {
+		tcp.SetKeepAlive(true)
+		tcp.SetKeepAlivePeriod(module.keepAlive)
+	}
+	conn.SetReadDeadline(time.Now().Add(2 * module.idleTimeout))
+}
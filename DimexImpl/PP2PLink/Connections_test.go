@@ -0,0 +1,85 @@
+package PP2PLink
+
+import (
+	"errors"
+	"io"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// halfOpenEvent espera um ConnDown por conexao meio-aberta para to, ate timeout
+func halfOpenEvent(link *PP2PLink, to string, timeout time.Duration) bool {
+	deadline := time.After(timeout)
+	for {
+		select {
+		case ev := <-link.Events:
+			if ev.Peer == to && ev.Kind == ConnDown && errors.Is(ev.Err, errHalfOpen) {
+				return true
+			}
+		case <-deadline:
+			return false
+		}
+	}
+}
+
+// o modulo de cima do receptor demora a ler Ind: as mensagens ficam sem ack bem mais que
+// HalfOpenTimeout, mas os heartbeats mostram que o receptor esta vivo e a conexao fica
+func TestSlowReceiverNotHalfOpen(t *testing.T) {
+	dir := t.TempDir()
+	addrA, addrB := "unix://"+filepath.Join(dir, "a.sock"), "unix://"+filepath.Join(dir, "b.sock")
+	opts := PP2PLink_Options{RetransmitTimeout: 20 * time.Millisecond, HalfOpenTimeout: 100 * time.Millisecond}
+	a, err := NewPP2PLinkWithOptions(addrA, false, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewPP2PLinkWithOptions(addrB, false, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgs := []string{"m1", "m2", "m3"}
+	for _, m := range msgs {
+		a.Req <- PP2PLink_Req_Message{To: addrB, Message: m}
+	}
+	if halfOpenEvent(a, addrB, 600*time.Millisecond) {
+		t.Fatal("receptor lento tratado como conexao meio-aberta")
+	}
+	if a.unackedTo(addrB) == 0 {
+		t.Fatal("mensagens confirmadas sem o receptor ler Ind: o teste nao exercita a espera")
+	}
+	for _, m := range msgs {
+		receive(t, b, m)
+	}
+}
+
+// o destino aceita a conexao e le os quadros, mas nunca responde: meio-aberta
+func TestSilentReceiverHalfOpen(t *testing.T) {
+	dir := t.TempDir()
+	addrA, addrB := "unix://"+filepath.Join(dir, "a.sock"), "unix://"+filepath.Join(dir, "b.sock")
+	silent, err := net.Listen("unix", filepath.Join(dir, "b.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer silent.Close()
+	go func() {
+		for {
+			conn, err := silent.Accept()
+			if err != nil {
+				return
+			}
+			go io.Copy(io.Discard, conn)
+		}
+	}()
+
+	a, err := NewPP2PLinkWithOptions(addrA, false, PP2PLink_Options{
+		RetransmitTimeout: 20 * time.Millisecond, HalfOpenTimeout: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.Req <- PP2PLink_Req_Message{To: addrB, Message: "m1"}
+	if !halfOpenEvent(a, addrB, 2*time.Second) {
+		t.Fatal("destino sem resposta nao foi tratado como conexao meio-aberta")
+	}
+}
@@ -0,0 +1,315 @@
+/*
+  Injecao de falhas no PP2PLink, para testar DIMEX e snapshot em condicoes
+  realistas num cluster local.
+  As falhas sao aplicadas aos quadros, abaixo da sequencia e da retransmissao (Reliable.go):
+     Req --> sequencia/retransmissao --> [atraso, perda, duplicacao, reordenacao, particao] --> fila do destino
+     Ind <-- ordenacao/acks <-- [queda do processo] <-- quadros recebidos
+  Assim o link continua perfeito para o modulo de cima: um quadro perdido (ou retido
+  por particao) e' retransmitido ate passar, e quadros atrasados, reordenados ou
+  duplicados sao entregues em ordem e uma so vez. As falhas aparecem como latencia,
+  retransmissoes e perda de vazao, nao como perda de mensagens.
+  As falhas sao aplicadas no envio, por destino (dados e acks). Para falhas em todos os
+  canais, todos os processos devem usar a camada.
+  Configuracao por arquivo JSON (LoadFaultConfig) ou em tempo de execucao (FaultInjector).
+  Exemplo:
+     {
+       "seed": 42,
+       "default": {"latency": {"dist": "uniform", "min": "1ms", "max": "20ms"}, "drop": 0.01},
+       "links": {"127.0.0.1:7002": {"duplicate": 0.05, "reorder": 0.1, "reorderDelay": "100ms"}},
+       "partitions": {"split": [["127.0.0.1:5000"], ["127.0.0.1:6001", "127.0.0.1:7002"]]},
+       "active": ["split"]
+     }
+*/
+
+package PP2PLink
+
+import (
+	"SD/Units"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+)
+
+// Latency descreve a distribuicao do atraso de cada mensagem
+type Latency struct {
+	Dist   string         `json:"dist"`   // "", "fixed", "uniform", "normal" ou "exponential"
+	Min    Units.Duration `json:"min"`    // fixed: atraso; uniform: minimo; demais: piso
+	Max    Units.Duration `json:"max"`    // uniform: maximo
+	Mean   Units.Duration `json:"mean"`   // normal e exponential
+	StdDev Units.Duration `json:"stddev"` // normal
+}
+
+// PP2PLink_Fault_Link e' a configuracao de falhas de um enlace (destino)
+type PP2PLink_Fault_Link struct {
+	Latency      Latency        `json:"latency"`
+	Drop         float64        `json:"drop"`         // probabilidade de perder a mensagem
+	Duplicate    float64        `json:"duplicate"`    // probabilidade de entregar duas vezes
+	Reorder      float64        `json:"reorder"`      // probabilidade de atrasar a mensagem alem das seguintes
+	ReorderDelay Units.Duration `json:"reorderDelay"` // atraso extra das reordenadas (padrao 50ms)
+}
+
+type PP2PLink_Fault_Config struct {
+	Seed       int64                          `json:"seed"` // 0 = semente pelo relogio
+	Default    PP2PLink_Fault_Link            `json:"default"`
+	Links      map[string]PP2PLink_Fault_Link `json:"links"`      // destino -> configuracao
+	Partitions map[string][][]string          `json:"partitions"` // nome -> grupos de enderecos
+	Active     []string                       `json:"active"`     // particoes ativas no inicio
+}
+
+func LoadFaultConfig(path string) (PP2PLink_Fault_Config, error) {
+	var cfg PP2PLink_Fault_Config
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, err
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("config de falhas %s: %w", path, err)
+	}
+	for _, name := range cfg.Active {
+		if _, ok := cfg.Partitions[name]; !ok {
+			return cfg, fmt.Errorf("config de falhas %s: particao ativa %q nao definida", path, name)
+		}
+	}
+	return cfg, nil
+}
+
+// PP2PLink_Fault_Stats conta as falhas injetadas (em quadros, inclusive acks e retransmissoes)
+type PP2PLink_Fault_Stats struct {
+	Sent, Dropped, Duplicated, Reordered, Partitioned, CrashDropped int
+}
+
+type FaultInjector struct {
+	mutex   sync.Mutex
+	local   string
+	cfg     PP2PLink_Fault_Config
+	active  map[string]bool
+	crashed bool
+	rnd     *rand.Rand
+	stats   PP2PLink_Fault_Stats
+	dbg     bool
+}
+
+// NewFaultyPP2PLink instala a injecao de falhas em inner e o devolve: o modulo de cima
+// continua usando Req, Ind, Events, Err e Metrics do proprio link
+func NewFaultyPP2PLink(inner *PP2PLink, _cfg PP2PLink_Fault_Config) (*PP2PLink, *FaultInjector) {
+	seed := _cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	fi := &FaultInjector{
+		local:  inner.address,
+		cfg:    _cfg,
+		active: make(map[string]bool),
+		rnd:    rand.New(rand.NewSource(seed)),
+		dbg:    inner.dbg,
+	}
+	if fi.cfg.Links == nil {
+		fi.cfg.Links = make(map[string]PP2PLink_Fault_Link)
+	}
+	if fi.cfg.Partitions == nil {
+		fi.cfg.Partitions = make(map[string][][]string)
+	}
+	for _, name := range _cfg.Active {
+		fi.active[name] = true
+	}
+	inner.faults.Store(fi)
+	return inner, fi
+}
+
+// faultInjector devolve a injecao de falhas instalada no link, ou nil
+func (module *PP2PLink) faultInjector() *FaultInjector {
+	fi, _ := module.faults.Load().(*FaultInjector)
+	return fi
+}
+
+// decide sorteia o destino de um quadro: nenhuma copia (perda), uma ou duas, cada uma com seu atraso
+func (fi *FaultInjector) decide(to string, frame string) []time.Duration {
+	fi.mutex.Lock()
+	defer fi.mutex.Unlock()
+
+	fi.stats.Sent++
+	if fi.crashed {
+		fi.stats.CrashDropped++
+		return nil
+	}
+	if fi.partitioned(to) {
+		fi.stats.Partitioned++
+		fi.outDbg("particao: descarta " + frame + " para " + to)
+		return nil
+	}
+	link := fi.linkConfig(to)
+	if fi.rnd.Float64() < link.Drop {
+		fi.stats.Dropped++
+		fi.outDbg("perda: descarta " + frame + " para " + to)
+		return nil
+	}
+	copies := 1
+	if fi.rnd.Float64() < link.Duplicate {
+		fi.stats.Duplicated++
+		copies = 2
+	}
+	var delays []time.Duration
+	for i := 0; i < copies; i++ {
+		delay := fi.latency(link.Latency)
+		if fi.rnd.Float64() < link.Reorder {
+			fi.stats.Reordered++
+			extra := time.Duration(link.ReorderDelay)
+			if extra == 0 {
+				extra = 50 * time.Millisecond
+			}
+			delay += extra
+		}
+		delays = append(delays, delay)
+	}
+	return delays
+}
+
+// dropIncoming indica se o processo esta "caido" e o quadro recebido deve ser ignorado
+func (fi *FaultInjector) dropIncoming() bool {
+	fi.mutex.Lock()
+	defer fi.mutex.Unlock()
+	if fi.crashed {
+		fi.stats.CrashDropped++
+	}
+	return fi.crashed
+}
+
+// latency sorteia o atraso de uma mensagem (chamado com mutex)
+func (fi *FaultInjector) latency(l Latency) time.Duration {
+	var d time.Duration
+	switch l.Dist {
+	case "", "fixed":
+		d = time.Duration(l.Min)
+	case "uniform":
+		span := int64(l.Max - l.Min)
+		d = time.Duration(l.Min)
+		if span > 0 {
+			d += time.Duration(fi.rnd.Int63n(span))
+		}
+	case "normal":
+		d = time.Duration(fi.rnd.NormFloat64()*float64(l.StdDev) + float64(l.Mean))
+	case "exponential":
+		d = time.Duration(fi.rnd.ExpFloat64() * float64(l.Mean))
+	}
+	if d < time.Duration(l.Min) {
+		d = time.Duration(l.Min)
+	}
+	return d
+}
+
+func (fi *FaultInjector) linkConfig(to string) PP2PLink_Fault_Link {
+	if link, ok := fi.cfg.Links[to]; ok {
+		return link
+	}
+	return fi.cfg.Default
+}
+
+// partitioned indica se local e to estao em grupos diferentes de alguma particao ativa
+func (fi *FaultInjector) partitioned(to string) bool {
+	for name := range fi.active {
+		groupOf := func(addr string) int {
+			for i, group := range fi.cfg.Partitions[name] {
+				for _, a := range group {
+					if a == addr {
+						return i
+					}
+				}
+			}
+			return -1
+		}
+		gl, gt := groupOf(fi.local), groupOf(to)
+		if gl >= 0 && gt >= 0 && gl != gt {
+			return true
+		}
+	}
+	return false
+}
+
+func (fi *FaultInjector) outDbg(s string) {
+	if fi.dbg {
+		fmt.Println(". . . . . . . . . . . . . . . . . [ PP2PLink falhas : " + s + " ]")
+	}
+}
+
+// ------------------------------------------------------------------------------------
+// ------- controle em tempo de execucao
+// ------------------------------------------------------------------------------------
+
+// SetDefault troca a configuracao dos enlaces sem configuracao propria
+func (fi *FaultInjector) SetDefault(link PP2PLink_Fault_Link) {
+	fi.mutex.Lock()
+	defer fi.mutex.Unlock()
+	fi.cfg.Default = link
+}
+
+// SetLink troca a configuracao do enlace para o destino to
+func (fi *FaultInjector) SetLink(to string, link PP2PLink_Fault_Link) {
+	fi.mutex.Lock()
+	defer fi.mutex.Unlock()
+	fi.cfg.Links[to] = link
+}
+
+// ClearLink volta o enlace para a configuracao padrao
+func (fi *FaultInjector) ClearLink(to string) {
+	fi.mutex.Lock()
+	defer fi.mutex.Unlock()
+	delete(fi.cfg.Links, to)
+}
+
+// Partition define (ou redefine) e ativa a particao name com os grupos dados
+func (fi *FaultInjector) Partition(name string, groups ...[]string) {
+	fi.mutex.Lock()
+	defer fi.mutex.Unlock()
+	fi.cfg.Partitions[name] = groups
+	fi.active[name] = true
+}
+
+// Heal desativa a particao name (a definicao fica guardada)
+func (fi *FaultInjector) Heal(name string) {
+	fi.mutex.Lock()
+	defer fi.mutex.Unlock()
+	delete(fi.active, name)
+}
+
+// Activate reativa uma particao definida no arquivo ou em Partition
+func (fi *FaultInjector) Activate(name string) error {
+	fi.mutex.Lock()
+	defer fi.mutex.Unlock()
+	if _, ok := fi.cfg.Partitions[name]; !ok {
+		return fmt.Errorf("particao %q nao definida", name)
+	}
+	fi.active[name] = true
+	return nil
+}
+
+// HealAll desativa todas as particoes
+func (fi *FaultInjector) HealAll() {
+	fi.mutex.Lock()
+	defer fi.mutex.Unlock()
+	fi.active = make(map[string]bool)
+}
+
+// Crash faz o processo parar de enviar e de receber quadros (simula queda).
+// As mensagens sem ack continuam no buffer de retransmissao, dos dois lados:
+// depois de Recover sao entregues, como apos uma pausa longa do processo
+func (fi *FaultInjector) Crash() {
+	fi.mutex.Lock()
+	defer fi.mutex.Unlock()
+	fi.crashed = true
+}
+
+// Recover desfaz Crash
+func (fi *FaultInjector) Recover() {
+	fi.mutex.Lock()
+	defer fi.mutex.Unlock()
+	fi.crashed = false
+}
+
+func (fi *FaultInjector) Stats() PP2PLink_Fault_Stats {
+	fi.mutex.Lock()
+	defer fi.mutex.Unlock()
+	return fi.stats
+}
@@ -0,0 +1,98 @@
+package PP2PLink
+
+import (
+	"SD/Units"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// com perda, atraso, duplicacao e reordenacao nos quadros, o modulo de cima ainda recebe
+// todas as mensagens, uma vez e em ordem (as falhas ficam abaixo da retransmissao)
+func TestFaultsKeepPerfectLink(t *testing.T) {
+	dir := t.TempDir()
+	addrA, addrB := "unix://"+filepath.Join(dir, "a.sock"), "unix://"+filepath.Join(dir, "b.sock")
+	opts := PP2PLink_Options{RetransmitTimeout: 20 * time.Millisecond}
+	a, err := NewPP2PLinkWithOptions(addrA, false, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewPP2PLinkWithOptions(addrB, false, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	front, fi := NewFaultyPP2PLink(a, PP2PLink_Fault_Config{
+		Seed: 7,
+		Default: PP2PLink_Fault_Link{
+			Latency:      Latency{Dist: "uniform", Min: Units.Duration(0), Max: Units.Duration(10 * time.Millisecond)},
+			Drop:         0.3,
+			Duplicate:    0.1,
+			Reorder:      0.1,
+			ReorderDelay: Units.Duration(30 * time.Millisecond),
+		}})
+
+	const n = 200
+	go func() {
+		for i := 1; i <= n; i++ {
+			front.Req <- PP2PLink_Req_Message{To: addrB, Message: strconv.Itoa(i)}
+		}
+	}()
+	timeout := time.After(20 * time.Second)
+	for i := 1; i <= n; i++ {
+		select {
+		case msg := <-b.Ind:
+			if msg.Message != strconv.Itoa(i) || msg.From != addrA {
+				t.Fatalf("recebido %q de %s, esperado %d de %s", msg.Message, msg.From, i, addrA)
+			}
+		case <-timeout:
+			t.Fatalf("so %d de %d mensagens entregues; falhas %+v", i-1, n, fi.Stats())
+		}
+	}
+	select {
+	case msg := <-b.Ind:
+		t.Fatalf("mensagem a mais: %q", msg.Message)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	stats := fi.Stats()
+	if stats.Dropped == 0 || stats.Duplicated == 0 || stats.Reordered == 0 {
+		t.Errorf("falhas nao foram injetadas: %+v", stats)
+	}
+	// o link devolvido e' o proprio link: Metrics e Events continuam valendo
+	if m := front.Metrics(); m.Sent != n || m.Retransmitted == 0 {
+		t.Errorf("metricas do link com falhas: %+v", m)
+	}
+}
+
+// particao retem os quadros; depois de Heal as mensagens chegam por retransmissao
+func TestFaultsPartitionHeals(t *testing.T) {
+	dir := t.TempDir()
+	addrA, addrB := "unix://"+filepath.Join(dir, "a.sock"), "unix://"+filepath.Join(dir, "b.sock")
+	opts := PP2PLink_Options{RetransmitTimeout: 20 * time.Millisecond}
+	a, err := NewPP2PLinkWithOptions(addrA, false, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewPP2PLinkWithOptions(addrB, false, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	front, fi := NewFaultyPP2PLink(a, PP2PLink_Fault_Config{})
+	fi.Partition("split", []string{addrA}, []string{addrB})
+	front.Req <- PP2PLink_Req_Message{To: addrB, Message: "m"}
+	select {
+	case msg := <-b.Ind:
+		t.Fatalf("entregue durante a particao: %q", msg.Message)
+	case <-time.After(100 * time.Millisecond):
+	}
+	fi.Heal("split")
+	select {
+	case msg := <-b.Ind:
+		if msg.Message != "m" {
+			t.Fatalf("recebido %q", msg.Message)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("mensagem perdida apos Heal; falhas %+v", fi.Stats())
+	}
+}
@@ -0,0 +1,55 @@
+/*
+  Enquadramento das mensagens na conexao: 4 bytes com o tamanho (big endian)
+  seguidos do quadro, escritos como bytes crus. Substitui o tamanho em 4 digitos
+  decimais escrito com fmt.Fprintf, que interpretava '%' da mensagem como verbo
+  de formatacao e limitava as mensagens a 9999 bytes.
+  O quadro pode conter quaisquer bytes; o leitor recusa tamanhos acima de MaxFrameSize
+  para que um par com defeito (ou malicioso) nao force alocacoes enormes.
+*/
+
+package PP2PLink
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+const (
+	frameHeaderSize     = 4
+	defaultMaxFrameSize = 16 << 20
+)
+
+// writeFrame escreve o tamanho seguido do quadro
+func writeFrame(w io.Writer, frame string) error {
+	if uint64(len(frame)) > 1<<32-1 {
+		return fmt.Errorf("quadro de %d bytes excede o enquadramento", len(frame))
+	}
+	var header [frameHeaderSize]byte
+	binary.BigEndian.PutUint32(header[:], uint32(len(frame)))
+	if _, err := w.Write(header[:]); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, frame)
+	return err
+}
+
+// readFrame le um quadro completo; io.EOF so se a conexao fechou entre quadros
+func readFrame(r io.Reader, maxSize int) ([]byte, error) {
+	var header [frameHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	size := binary.BigEndian.Uint32(header[:])
+	if uint64(size) > uint64(maxSize) {
+		return nil, fmt.Errorf("quadro de %d bytes excede o maximo de %d", size, maxSize)
+	}
+	frame := make([]byte, size) //           // buffer do tamanho exato
+	if _, err := io.ReadFull(r, frame); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return frame, nil
+}
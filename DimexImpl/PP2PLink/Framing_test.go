@@ -0,0 +1,89 @@
+package PP2PLink
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"testing"
+)
+
+// quadros escritos em sequencia numa conexao sao lidos de volta iguais, inclusive com '%' e bytes nao UTF-8
+func FuzzFrameRoundTrip(f *testing.F) {
+	f.Add([]byte("reqEntry,1,7"), []byte("respOk,2"))
+	f.Add([]byte("%d%s%%!"), []byte{})
+	f.Add([]byte{0, 0, 0, 4, 0xff, 0xfe}, []byte("|||"))
+
+	f.Fuzz(func(t *testing.T, a []byte, b []byte) {
+		var buf bytes.Buffer
+		w := bufio.NewWriter(&buf)
+		for _, frame := range [][]byte{a, b} {
+			if err := writeFrame(w, string(frame)); err != nil {
+				t.Fatalf("writeFrame: %v", err)
+			}
+		}
+		if err := w.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		if buf.Len() != 2*frameHeaderSize+len(a)+len(b) {
+			t.Fatalf("tamanho escrito %d, esperado %d", buf.Len(), 2*frameHeaderSize+len(a)+len(b))
+		}
+		r := bufio.NewReader(&buf)
+		for _, want := range [][]byte{a, b} {
+			got, err := readFrame(r, defaultMaxFrameSize)
+			if err != nil {
+				t.Fatalf("readFrame: %v", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Fatalf("lido %q, esperado %q", got, want)
+			}
+		}
+		if _, err := readFrame(r, defaultMaxFrameSize); err != io.EOF {
+			t.Fatalf("fim da conexao entre quadros deve dar io.EOF, deu %v", err)
+		}
+	})
+}
+
+// bytes quaisquer na conexao nunca causam panico nem quadro maior que o maximo
+func FuzzReadFrame(f *testing.F) {
+	f.Add([]byte{0, 0, 0, 3, 'a', 'b', 'c'})
+	f.Add([]byte{0xff, 0xff, 0xff, 0xff})
+	f.Add([]byte("0012reqEntry,1"))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		const max = 1024
+		r := bytes.NewReader(data)
+		for {
+			frame, err := readFrame(r, max)
+			if err != nil {
+				return
+			}
+			if len(frame) > max {
+				t.Fatalf("quadro de %d bytes acima do maximo %d", len(frame), max)
+			}
+		}
+	})
+}
+
+// o cabecalho de entrega confiavel sobrevive a payloads com separadores e bytes quaisquer
+func FuzzDecodeFrame(f *testing.F) {
+	f.Add(int64(1700000000000000000), "127.0.0.1:5000", uint64(1), "reqEntry,0,3")
+	f.Add(int64(-1), "unix:///tmp/p0.sock", uint64(0), "a|b|c\x00%s")
+
+	f.Fuzz(func(t *testing.T, epoch int64, from string, seq uint64, payload string) {
+		if bytes.ContainsRune([]byte(from), '|') {
+			t.Skip() // enderecos nao contem '|'
+		}
+		got, err := decodeFrame(encodeData(epoch, from, seq, payload))
+		if err != nil {
+			t.Fatalf("decodeFrame: %v", err)
+		}
+		if got.kind != frameData || got.epoch != epoch || got.from != from || got.seq != seq || got.payload != payload {
+			t.Fatalf("decodificado %+v", got)
+		}
+		ack, err := decodeFrame(encodeAck(epoch, from, seq))
+		if err != nil || ack.kind != frameAck || ack.seq != seq {
+			t.Fatalf("ack decodificado %+v, %v", ack, err)
+		}
+		decodeFrame(payload) // lixo nao pode causar panico
+	})
+}
@@ -0,0 +1,42 @@
+/*
+  Contadores do PP2PLink, atualizados atomicamente e lidos com Metrics().
+*/
+
+package PP2PLink
+
+import "sync/atomic"
+
+type PP2PLink_Metrics struct {
+	Sent          uint64 // mensagens aceitas em Req
+	Delivered     uint64 // mensagens entregues em Ind
+	Retransmitted uint64 // quadros de dados colocados de novo na fila
+	Duplicates    uint64 // quadros de dados ja entregues, descartados
+	QueueDrops    uint64 // quadros descartados por fila cheia
+	Malformed     uint64 // quadros que nao seguem o formato
+	Forged        uint64 // quadros sem HMAC valido (ver Auth.go) ou de remetente diferente do certificado (ver TLS.go)
+	Replayed      uint64 // quadros autenticados fora da janela de tempo ou repetidos (ver Auth.go)
+	Stale         uint64 // quadros de dados de uma encarnacao anterior do remetente (ver Reliable.go)
+}
+
+type metrics struct {
+	sent, delivered, retransmitted, duplicates, queueDrops, malformed, forged, replayed, stale uint64
+}
+
+func (module *PP2PLink) Metrics() PP2PLink_Metrics {
+	m := &module.metrics
+	return PP2PLink_Metrics{
+		Sent:          atomic.LoadUint64(&m.sent),
+		Delivered:     atomic.LoadUint64(&m.delivered),
+		Retransmitted: atomic.LoadUint64(&m.retransmitted),
+		Duplicates:    atomic.LoadUint64(&m.duplicates),
+		QueueDrops:    atomic.LoadUint64(&m.queueDrops),
+		Malformed:     atomic.LoadUint64(&m.malformed),
+		Forged:        atomic.LoadUint64(&m.forged),
+		Replayed:      atomic.LoadUint64(&m.replayed),
+		Stale:         atomic.LoadUint64(&m.stale),
+	}
+}
+
+func count(counter *uint64) {
+	atomic.AddUint64(counter, 1)
+}
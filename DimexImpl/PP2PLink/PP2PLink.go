@@ -12,45 +12,205 @@
   e recebe com io.ReadFull o tamanho informado - Dotti
   * Semestre 2022/1 - melhorias eliminando retorno de erro aos canais superiores.
   se conexao fecha nao retorna nada.   melhorias em comentarios.   adicionado modo debug. - Dotti
+  * Opcoes (PP2PLink_Options): trace de todas mensagens enviadas e entregues (ver Trace.go).
+  * Entrega confiavel: numeros de sequencia, acks, retransmissao e descarte de duplicadas,
+  sem perda nem duplicacao e em ordem FIFO mesmo se conexoes caem (ver Reliable.go).
+  From nas mensagens entregues passa a ser o endereco de escuta do remetente.
+  * Uma fila e uma rotina escritora por destino, com politica de overflow e timeout de dial:
+  um destino inacessivel nao trava o envio para os demais (ver Queues.go).
+  * Redial com backoff, descarte de conexoes ociosas ou meio-abertas e eventos de
+  conexao no canal Events (ver Connections.go).
+  * Modo TLS opcional com autenticacao mutua; Identity informa o certificado do remetente (ver TLS.go).
+  * HMAC opcional dos quadros com chave do cluster (ver Auth.go) e contadores em Metrics() (ver Metrics.go).
+  * Enderecos com esquema (tcp://, tcp6://, unix://) e ganchos Listen/Dial (ver Address.go).
+  * NewPP2PLink devolve erro se nao consegue escutar no endereco; falhas no accept
+  (repetido com backoff) e em conexoes aceitas sao informadas no canal Err.
+  * Escritas agrupadas por destino (bufio, FlushLatency) e envio em lote por ReqBatch.
+  Req e ReqBatch nao tem buffer: a ordem entre os dois e' a ordem em que o modulo de cima envia.
+  * Tamanho binario e bytes crus na conexao, mensagens binarias em Data (ver Framing.go).
 */
 
 package PP2PLink
 
 import (
+	"bufio"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"io"
 	"net"
-	"strconv"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type PP2PLink_Req_Message struct {
 	To      string
 	Message string
+	Data    []byte // se nao nil, enviado no lugar de Message (qualquer sequencia de bytes)
 }
 
 type PP2PLink_Ind_Message struct {
-	From    string
-	Message string
+	From     string
+	Message  string
+	Data     []byte // os mesmos bytes de Message
+	Identity string // CommonName do certificado do remetente (so no modo TLS)
+}
+
+type PP2PLink_Options struct {
+	Trace             io.Writer         // se nao nil, grava cada envio e entrega (formato em Trace.go)
+	RetransmitTimeout time.Duration     // espera por ack antes de retransmitir (0 = 200ms)
+	QueueSize         int               // posicoes na fila de cada destino (0 = 100)
+	Overflow          PP2PLink_Overflow // o que fazer com fila cheia (ver Queues.go; OverflowBlock trava Req inteiro)
+	DialTimeout       time.Duration     // espera maxima para abrir conexao (0 = 1s)
+	RedialMin         time.Duration     // primeira espera apos falha de dial (0 = 50ms)
+	RedialMax         time.Duration     // maior espera entre tentativas de dial (0 = 5s)
+	IdleTimeout       time.Duration     // fecha conexao de saida sem uso (0 = 1min)
+	WriteTimeout      time.Duration     // prazo de cada escrita (0 = 5s)
+	KeepAlive         time.Duration     // periodo do TCP keepalive (0 = 15s)
+	HalfOpenTimeout   time.Duration     // mensagem sem ack por este tempo reabre a conexao (0 = 10 * RetransmitTimeout)
+	TLS               *tls.Config       // se nao nil, conexoes TLS com autenticacao mutua (ver TLS.go)
+	Peers             []string          // modo TLS: endereco do processo i, dono do certificado ProcessIdentity(i)
+	AuthKey           []byte            // se nao nil, quadros autenticados com HMAC (ver Auth.go)
+	ReplayWindow      time.Duration     // idade maxima de um quadro autenticado (0 = 30s)
+	Listen            ListenFunc        // se nao nil, substitui net.Listen (ver Address.go)
+	Dial              DialFunc          // se nao nil, substitui net.Dialer.DialContext
+	FlushLatency      time.Duration     // espera maxima para juntar quadros numa escrita (0 = escreve quando a fila esvazia)
+	WriteBufferSize   int               // buffer de escrita por destino (0 = 32KB)
+	MaxFrameSize      int               // maior quadro aceito na recepcao (0 = 16MB)
 }
 
 type PP2PLink struct {
-	Ind   chan PP2PLink_Ind_Message
-	Req   chan PP2PLink_Req_Message
-	Run   bool
-	dbg   bool
-	Cache map[string]net.Conn // cache de conexoes - reaproveita conexao com destino ao inves de abrir outra
+	Ind      chan PP2PLink_Ind_Message
+	Req      chan PP2PLink_Req_Message
+	ReqBatch chan []PP2PLink_Req_Message // varias mensagens de uma vez (ex: broadcast)
+	Events   chan PP2PLink_Conn_Event    // conexoes abertas e fechadas (ver Connections.go)
+	Err      chan error                  // falhas ao aceitar ou tratar conexoes (descartadas se ninguem le)
+	Run      bool
+	dbg      bool
+	Cache    map[string]net.Conn // cache de conexoes - reaproveita conexao com destino ao inves de abrir outra
+	address  string              // endereco local (usado no trace)
+	listener net.Listener        // escuta aberta por Start (fechada por Close)
+
+	cacheMutex  sync.Mutex // protege Cache (escrito pelas rotinas escritoras)
+	destMutex   sync.Mutex // protege dests
+	dests       map[string]*destination
+	queueSize   int
+	overflow    PP2PLink_Overflow
+	dialTimeout time.Duration
+
+	redialMin       time.Duration
+	redialMax       time.Duration
+	idleTimeout     time.Duration
+	writeTimeout    time.Duration
+	keepAlive       time.Duration
+	halfOpenTimeout time.Duration
+
+	tls          *tls.Config
+	peerAddrs    map[string]string // identidade do certificado -> endereco do processo (nil: sem conferencia)
+	authKey      []byte
+	replayWindow time.Duration
+	seenMutex    sync.Mutex       // protege seen e lastPrune
+	seen         map[string]int64 // HMACs recebidos na janela -> instante do envio (ver Auth.go)
+	lastPrune    time.Time
+
+	listenFunc ListenFunc
+	dialFunc   DialFunc
+
+	flushLatency    time.Duration
+	writeBufferSize int
+	maxFrameSize    int
+
+	metrics metrics
+	faults  atomic.Value // *FaultInjector, se instalado por NewFaultyPP2PLink
+
+	trace      io.Writer
+	traceMutex sync.Mutex
+
+	epoch    int64                // identifica esta encarnacao do processo nos quadros
+	rto      time.Duration        // espera por ack antes de retransmitir
+	relMutex sync.Mutex           // protege out e in
+	out      map[string]*outState // por destino: sequencia e mensagens sem ack
+	in       map[string]*inState  // por remetente: proxima sequencia esperada
+}
+
+func NewPP2PLink(_address string, _dbg bool) (*PP2PLink, error) {
+	return NewPP2PLinkWithOptions(_address, _dbg, PP2PLink_Options{})
 }
 
-func NewPP2PLink(_address string, _dbg bool) *PP2PLink {
-	p2p := &PP2PLink{
-		Req:   make(chan PP2PLink_Req_Message, 1),
-		Ind:   make(chan PP2PLink_Ind_Message, 1),
-		Run:   true,
-		dbg:   _dbg,
-		Cache: make(map[string]net.Conn)}
+func NewPP2PLinkWithOptions(_address string, _dbg bool, _opts PP2PLink_Options) (*PP2PLink, error) {
+	p2p := newLink(_address, _dbg, _opts)
 	p2p.outDbg(" Init PP2PLink!")
-	p2p.Start(_address)
-	return p2p
+	if err := p2p.Start(_address); err != nil {
+		return nil, err
+	}
+	return p2p, nil
+}
+
+func newLink(_address string, _dbg bool, _opts PP2PLink_Options) *PP2PLink {
+	rto := durationOr(_opts.RetransmitTimeout, defaultRetransmitTimeout)
+	queueSize := _opts.QueueSize
+	if queueSize <= 0 {
+		queueSize = defaultQueueSize
+	}
+	maxFrameSize := _opts.MaxFrameSize
+	if maxFrameSize <= 0 {
+		maxFrameSize = defaultMaxFrameSize
+	}
+	writeBufferSize := _opts.WriteBufferSize
+	if writeBufferSize <= 0 {
+		writeBufferSize = defaultWriteBufferSize
+	}
+	listenFunc := _opts.Listen
+	if listenFunc == nil {
+		listenFunc = defaultListen
+	}
+	dialFunc := _opts.Dial
+	if dialFunc == nil {
+		dialer := &net.Dialer{KeepAlive: durationOr(_opts.KeepAlive, defaultKeepAlive)}
+		dialFunc = dialer.DialContext
+	}
+	return &PP2PLink{
+		Req:      make(chan PP2PLink_Req_Message),
+		ReqBatch: make(chan []PP2PLink_Req_Message),
+		Ind:      make(chan PP2PLink_Ind_Message, 1),
+		Events:   make(chan PP2PLink_Conn_Event, eventsBuffer),
+		Err:      make(chan error, errBuffer),
+		Run:      true,
+		dbg:      _dbg,
+		Cache:    make(map[string]net.Conn),
+		address:  _address,
+		trace:    _opts.Trace,
+		epoch:    time.Now().UnixNano(),
+		rto:      rto,
+		out:      make(map[string]*outState),
+		in:       make(map[string]*inState),
+
+		dests:       make(map[string]*destination),
+		queueSize:   queueSize,
+		overflow:    _opts.Overflow,
+		dialTimeout: durationOr(_opts.DialTimeout, defaultDialTimeout),
+
+		redialMin:       durationOr(_opts.RedialMin, defaultRedialMin),
+		redialMax:       durationOr(_opts.RedialMax, defaultRedialMax),
+		idleTimeout:     durationOr(_opts.IdleTimeout, defaultIdleTimeout),
+		writeTimeout:    durationOr(_opts.WriteTimeout, defaultWriteTimeout),
+		keepAlive:       durationOr(_opts.KeepAlive, defaultKeepAlive),
+		halfOpenTimeout: durationOr(_opts.HalfOpenTimeout, 10*rto),
+
+		tls:          _opts.TLS,
+		peerAddrs:    peerAddresses(_opts.Peers),
+		authKey:      _opts.AuthKey,
+		replayWindow: durationOr(_opts.ReplayWindow, defaultReplayWindow),
+		seen:         make(map[string]int64),
+
+		listenFunc: listenFunc,
+		dialFunc:   dialFunc,
+
+		flushLatency:    _opts.FlushLatency,
+		writeBufferSize: writeBufferSize,
+		maxFrameSize:    maxFrameSize}
 }
 
 func (module *PP2PLink) outDbg(s string) {
@@ -59,96 +219,158 @@ func (module *PP2PLink) outDbg(s string) {
 	}
 }
 
-func (module *PP2PLink) Start(address string) {
+// reportErr repassa o erro sem bloquear o link (descarta se ninguem le)
+func (module *PP2PLink) reportErr(err error) {
+	module.outDbg("erro : " + err.Error())
+	select {
+	case module.Err <- err:
+	default:
+	}
+}
+
+// Start escuta no endereco (devolve erro se nao consegue) e lanca as rotinas do link
+func (module *PP2PLink) Start(address string) error {
+	listen, err := module.listen(address)
+	if err != nil {
+		return fmt.Errorf("PP2PLink: escutando em %s: %w", address, err)
+	}
+	module.listener = listen
 
 	// PROCESSO PARA RECEBIMENTO DE MENSAGENS
 	go func() {
-		listen, _ := net.Listen("tcp4", address)
+		delay := time.Duration(0)
 		for {
 			// aceita repetidamente tentativas novas de conexao
 			conn, err := listen.Accept()
+			if err != nil {
+				if errors.Is(err, net.ErrClosed) {
+					module.reportErr(fmt.Errorf("PP2PLink: escuta em %s encerrada: %w", address, err))
+					return
+				}
+				// erro passageiro (ex: limite de arquivos abertos): tenta de novo com backoff
+				delay = 2 * delay
+				if delay < acceptRetryMin {
+					delay = acceptRetryMin
+				}
+				if delay > acceptRetryMax {
+					delay = acceptRetryMax
+				}
+				module.reportErr(fmt.Errorf("PP2PLink: accept em %s: %w (nova tentativa em %v)", address, err, delay))
+				time.Sleep(delay)
+				continue
+			}
+			delay = 0
 			module.outDbg("ok   : conexao aceita com outro processo.")
 			// para cada conexao lanca rotina de tratamento
 			go func() {
+				peer := "" //                         // endereco de escuta do outro processo, do primeiro quadro
+				defer func() {
+					conn.Close()
+					if peer != "" {
+						module.publish(PP2PLink_Conn_Event{Peer: peer, Kind: ConnDown})
+					}
+				}()
+				module.prepareIncoming(conn)
+				identity, err := peerIdentity(conn) // sem TLS fica vazia
+				if err != nil {
+					module.reportErr(fmt.Errorf("PP2PLink: handshake TLS com %s: %w", conn.RemoteAddr(), err))
+					return
+				}
+				reader := bufio.NewReader(conn)
 				// repetidamente recebe mensagens na conexao TCP (sem fechar)
 				// e passa para modulo de cima
 				for { //                              // enquanto conexao aberta
-					if err != nil {
-						fmt.Println(".", err)
+					bufMsg, err := readFrame(reader, module.maxFrameSize) // le tamanho e quadro
+					if err == io.EOF || errors.Is(err, os.ErrDeadlineExceeded) {
+						module.outDbg("erro : " + err.Error() + " conexao fechada pelo outro processo (ou ociosa).")
 						break
 					}
-					bufTam := make([]byte, 4) //       // le tamanho da mensagem
-					_, err := io.ReadFull(conn, bufTam)
 					if err != nil {
-						module.outDbg("erro : " + err.Error() + " conexao fechada pelo outro processo.")
+						module.reportErr(fmt.Errorf("PP2PLink: recebendo de %s: %w", conn.RemoteAddr(), err))
 						break
 					}
-					tam, err := strconv.Atoi(string(bufTam))
-					bufMsg := make([]byte, tam)        // declara buffer do tamanho exato
-					_, err = io.ReadFull(conn, bufMsg) // le do tamanho do buffer ou da erro
+					// ATE AQUI:  procedimentos para receber msg
+					raw, err := module.open(string(bufMsg)) // confere HMAC, se configurado (e conta Forged/Replayed)
 					if err != nil {
-						fmt.Println("@", err)
-						break
+						module.outDbg("erro : " + err.Error())
+						continue
+					}
+					f, err := decodeFrame(raw)
+					if err != nil {
+						count(&module.metrics.malformed)
+						module.outDbg("erro : " + err.Error())
+						continue
+					}
+					if !module.fromMatches(identity, f.from) {
+						count(&module.metrics.forged)
+						module.reportErr(fmt.Errorf("PP2PLink: quadro de %s descartado: conexao com certificado de %s", f.from, identity))
+						continue
+					}
+					conn.SetReadDeadline(time.Now().Add(2 * module.idleTimeout))
+					if fi := module.faultInjector(); fi != nil && fi.dropIncoming() {
+						continue
+					}
+					if peer == "" {
+						peer = f.from
+						module.publish(PP2PLink_Conn_Event{Peer: peer, Kind: ConnUp})
+					}
+					module.onHeard(f.from)
+					switch f.kind {
+					case frameAck:
+						module.onAck(f)
+					case frameHeartbeat: // o remetente esta vivo, so lento para confirmar
+					default:
+						module.onData(f, identity) // entrega ao modulo superior, em ordem e sem duplicatas
 					}
-					msg := PP2PLink_Ind_Message{
-						From:    conn.RemoteAddr().String(),
-						Message: string(bufMsg)}
-					// ATE AQUI:  procedimentos para receber msg
-					module.Ind <- msg //               // repassa mensagem para modulo superior
 				}
 			}()
 		}
 	}()
 
 	// PROCESSO PARA ENVIO DE MENSAGENS
+	// distribui as mensagens nas filas por destino (ver Queues.go)
 	go func() {
 		for {
-			message := <-module.Req
-			module.Send(message)
+			select {
+			case message := <-module.Req:
+				module.Send(message)
+			case batch := <-module.ReqBatch:
+				for _, message := range batch {
+					module.Send(message)
+				}
+			}
 		}
 	}()
-}
 
-func (module *PP2PLink) Send(message PP2PLink_Req_Message) {
-	var conn net.Conn
-	var ok bool
-	var err error
-
-	// ja existe uma conexao aberta para aquele destinatario?
-	if conn, ok = module.Cache[message.To]; ok {
-	} else { // se nao existe, abre e guarda na cache
-		conn, err = net.Dial("tcp", message.To)
-		module.outDbg("ok   : conexao iniciada com outro processo")
-		if err != nil {
-			fmt.Println(err)
-			return
-		}
-		module.Cache[message.To] = conn
-	}
-	// calcula tamanho da mensagem e monta string de 4 caracteres numericos com o tamanho.
-	// completa com 0s aa esquerda para fechar tamanho se necessario.
-	str := strconv.Itoa(len(message.Message))
-	for len(str) < 4 {
-		str = "0" + str
-	}
-	if !(len(str) == 4) {
-		module.outDbg("ERROR AT PPLINK MESSAGE SIZE CALCULATION - INVALID MESSAGES MAY BE IN TRANSIT")
-	}
-	_, err = fmt.Fprintf(conn, str)             // escreve 4 caracteres com tamanho
-	_, err = fmt.Fprintf(conn, message.Message) // escreve a mensagem com o tamanho calculado
-	if err != nil {
-		module.outDbg("erro : " + err.Error() + ". Conexao fechada. 1 tentativa de reabrir:")
-		conn, err = net.Dial("tcp", message.To)
-		if err != nil {
-			//fmt.Println(err)
-			module.outDbg("       " + err.Error())
-			return
-		} else {
-			module.outDbg("ok   : conexao iniciada com outro processo.")
+	// PROCESSO PARA RETRANSMISSAO (heartbeats e deteccao de conexao meio-aberta)
+	go func() {
+		ticker := time.NewTicker(module.rto / 2)
+		defer ticker.Stop()
+		for range ticker.C {
+			module.retransmit()
+			module.heartbeat()
+			module.checkHalfOpen()
 		}
-		module.Cache[message.To] = conn
-		_, err = fmt.Fprintf(conn, str)             // escreve 4 caracteres com tamanho
-		_, err = fmt.Fprintf(conn, message.Message) // escreve a mensagem com o tamanho calculado
+	}()
+	return nil
+}
+
+// Close para de aceitar conexoes e libera o endereco de escuta (ex: quem criou o link
+// desiste depois de uma falha). As rotinas de envio continuam: o link nao e' reaproveitado
+func (module *PP2PLink) Close() error {
+	if module.listener == nil {
+		return nil // link em memoria
 	}
-	return
+	return module.listener.Close()
+}
+
+// Send numera a mensagem, guarda para retransmissao ate o ack e coloca na fila do destino.
+// Se a transmissao falha (ou a fila descarta), a retransmissao periodica tenta de novo.
+// Pode ser chamado por varias rotinas, em paralelo com Req: com OverflowBlock so quem
+// chama espera pela fila cheia.
+func (module *PP2PLink) Send(message PP2PLink_Req_Message) {
+	m := module.enqueue(message)
+	count(&module.metrics.sent)
+	module.traceEvent(traceSend, message.To, message.payload())
+	module.push(module.destinationFor(message.To), m.frame, module.overflow)
 }
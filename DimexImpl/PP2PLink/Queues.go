@@ -0,0 +1,162 @@
+/*
+  Filas de envio por destino.
+  Cada destino tem uma fila (canal com QueueSize posicoes) e uma rotina escritora
+  propria, dona da conexao com aquele destino. Um destino inacessivel (dial lento,
+  conexao travada) so atrasa a sua propria fila.
+  Se a fila de um destino enche, a politica Overflow decide:
+    - OverflowDropNewest (padrao): descarta o quadro que chega
+    - OverflowDropOldest: descarta o quadro mais antigo da fila
+    - OverflowBlock: espera espaco na fila (contrapressao sobre quem envia)
+  A espera de OverflowBlock acontece em quem chama Send. Para mensagens de Req e ReqBatch
+  quem chama e' a rotina unica que le esses canais: enquanto a fila de um destino estiver
+  cheia nenhuma outra mensagem segue, nem para os outros destinos (bloqueio na cabeca da
+  fila). Para bloquear so quem envia, chamar Send diretamente, na rotina de quem envia.
+  Descartar um quadro de dados nao perde a mensagem: ela continua no buffer de
+  retransmissao (Reliable.go) e e' enviada de novo quando vencer o timeout.
+  Acks descartados sao cobertos pelo proximo ack cumulativo.
+  A rotina escritora junta os quadros da fila num buffer (bufio) e so escreve na
+  conexao (flush) quando a fila esvazia, ou FlushLatency depois do primeiro quadro
+  pendente, ou quando o buffer enche: varias mensagens pequenas viram uma escrita.
+*/
+
+package PP2PLink
+
+import (
+	"bufio"
+	"net"
+	"time"
+)
+
+type PP2PLink_Overflow int
+
+const (
+	OverflowDropNewest PP2PLink_Overflow = iota
+	OverflowDropOldest
+	OverflowBlock
+)
+
+const (
+	defaultQueueSize       = 100
+	defaultDialTimeout     = time.Second
+	defaultWriteBufferSize = 32 * 1024
+)
+
+type destination struct {
+	to    string
+	queue chan string
+	reset chan struct{} // pedido de descarte da conexao (meio-aberta)
+
+	// usados apenas pela rotina escritora
+	conn     net.Conn
+	w        *bufio.Writer // buffer de escrita sobre conn
+	pending  bool          // ha quadros no buffer ainda nao escritos na conexao
+	state    int           // peerUnknown, peerUp ou peerDown (ver Connections.go)
+	backoff  time.Duration // espera atual entre tentativas de dial
+	nextDial time.Time     // antes disso nao tenta dial
+}
+
+// destinationFor devolve a fila do destino, criando fila e rotina escritora na primeira vez
+func (module *PP2PLink) destinationFor(to string) *destination {
+	module.destMutex.Lock()
+	defer module.destMutex.Unlock()
+	d, ok := module.dests[to]
+	if !ok {
+		d = &destination{to: to, queue: make(chan string, module.queueSize), reset: make(chan struct{}, 1)}
+		module.dests[to] = d
+		go module.writer(d)
+	}
+	return d
+}
+
+// push coloca um quadro na fila do destino, passando antes pela injecao de falhas (Faults.go).
+// Um quadro atrasado entra na fila depois, por um timer; a ordem entre quadros atrasados
+// nao e' mantida, o que a recepcao (Reliable.go) corrige
+func (module *PP2PLink) push(d *destination, frame string, policy PP2PLink_Overflow) bool {
+	fi := module.faultInjector()
+	if fi == nil {
+		return module.pushNow(d, frame, policy)
+	}
+	for _, delay := range fi.decide(d.to, frame) {
+		if delay <= 0 {
+			module.pushNow(d, frame, policy)
+		} else {
+			time.AfterFunc(delay, func() { module.pushNow(d, frame, OverflowDropNewest) })
+		}
+	}
+	return true
+}
+
+// pushNow coloca um quadro na fila do destino, aplicando a politica de overflow
+func (module *PP2PLink) pushNow(d *destination, frame string, policy PP2PLink_Overflow) bool {
+	select {
+	case d.queue <- frame:
+		return true
+	default:
+	}
+	switch policy {
+	case OverflowBlock:
+		d.queue <- frame
+		return true
+	case OverflowDropOldest:
+		for {
+			select {
+			case d.queue <- frame:
+				return true
+			default:
+			}
+			select {
+			case <-d.queue:
+				count(&module.metrics.queueDrops)
+				module.outDbg("fila cheia para " + d.to + ": descartado quadro mais antigo")
+			default:
+			}
+		}
+	default:
+		count(&module.metrics.queueDrops)
+		module.outDbg("fila cheia para " + d.to + ": descartado quadro novo")
+		return false
+	}
+}
+
+// writer e' a rotina escritora de um destino, dona da conexao (ver Connections.go)
+func (module *PP2PLink) writer(d *destination) {
+	idle := time.NewTimer(module.idleTimeout)
+	defer idle.Stop()
+	flush := time.NewTimer(time.Hour)
+	flush.Stop()
+	flushArmed := false
+	for {
+		select {
+		case frame := <-d.queue:
+			module.transmit(d, frame)
+			// junta no buffer o que ja esta na fila
+			for more := true; more; {
+				select {
+				case frame := <-d.queue:
+					module.transmit(d, frame)
+				default:
+					more = false
+				}
+			}
+			if module.flushLatency <= 0 {
+				module.flush(d)
+			} else if d.pending && !flushArmed {
+				flush.Reset(module.flushLatency)
+				flushArmed = true
+			}
+			if !idle.Stop() {
+				<-idle.C
+			}
+			idle.Reset(module.idleTimeout)
+		case <-flush.C:
+			flushArmed = false
+			module.flush(d)
+		case <-d.reset:
+			module.resetHalfOpen(d)
+		case <-idle.C:
+			module.flush(d)
+			module.evictIdle(d)
+			idle.Reset(module.idleTimeout)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package PP2PLink
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// com OverflowBlock a fila cheia de um destino trava a rotina que le Req, inclusive para
+// os outros destinos; Send chamado diretamente bloqueia so quem chama
+func TestOverflowBlockHeadOfLine(t *testing.T) {
+	dir := t.TempDir()
+	addrA, addrB := "unix://"+filepath.Join(dir, "a.sock"), "unix://"+filepath.Join(dir, "b.sock")
+	stuck := "unix://" + filepath.Join(dir, "stuck.sock")
+	release := make(chan struct{})
+	dialer := &net.Dialer{}
+	a, err := NewPP2PLinkWithOptions(addrA, false, PP2PLink_Options{
+		QueueSize:   1,
+		Overflow:    OverflowBlock,
+		DialTimeout: time.Minute,
+		Dial: func(ctx context.Context, network string, address string) (net.Conn, error) {
+			if "unix://"+address == stuck {
+				<-release // dial que nao termina: a fila de stuck nao anda
+				return nil, context.DeadlineExceeded
+			}
+			return dialer.DialContext(ctx, network, address)
+		}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewPP2PLinkWithOptions(addrB, false, PP2PLink_Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 1 com a rotina escritora (no dial), 1 na fila, 1 esperando espaco
+	for _, m := range []string{"s1", "s2", "s3"} {
+		a.Req <- PP2PLink_Req_Message{To: stuck, Message: m}
+	}
+	viaReq := make(chan struct{})
+	go func() {
+		a.Req <- PP2PLink_Req_Message{To: addrB, Message: "req"}
+		close(viaReq)
+	}()
+	select {
+	case <-viaReq:
+		t.Fatal("Req aceitou mensagem com a rotina de envio bloqueada")
+	case msg := <-b.Ind:
+		t.Fatalf("%q entregue com a rotina de envio bloqueada", msg.Message)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	go a.Send(PP2PLink_Req_Message{To: addrB, Message: "direto"})
+	receive(t, b, "direto")
+
+	close(release)
+	receive(t, b, "req")
+}
+
+// lotes maiores que a fila do destino, entre mensagens de Req: tudo chega, na ordem de envio
+// (o que a fila descarta volta pela retransmissao)
+func TestReqBatchCompleteInOrder(t *testing.T) {
+	dir := t.TempDir()
+	addrA, addrB := "unix://"+filepath.Join(dir, "a.sock"), "unix://"+filepath.Join(dir, "b.sock")
+	a, err := NewPP2PLinkWithOptions(addrA, false, PP2PLink_Options{QueueSize: 16, RetransmitTimeout: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewPP2PLinkWithOptions(addrB, false, PP2PLink_Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var want []string
+	for round := 0; round < 3; round++ {
+		single := "req" + strconv.Itoa(round)
+		a.Req <- PP2PLink_Req_Message{To: addrB, Message: single}
+		want = append(want, single)
+		var batch []PP2PLink_Req_Message
+		for i := 0; i < 100; i++ {
+			m := "lote" + strconv.Itoa(round) + "-" + strconv.Itoa(i)
+			batch = append(batch, PP2PLink_Req_Message{To: addrB, Message: m})
+			want = append(want, m)
+		}
+		a.ReqBatch <- batch
+	}
+	for _, m := range want {
+		receive(t, b, m)
+	}
+	if got := a.Metrics().Sent; got != uint64(len(want)) {
+		t.Fatalf("%d mensagens enviadas, esperadas %d", got, len(want))
+	}
+}
+
+// sem FlushLatency o buffer de escrita e' esvaziado assim que a fila esvazia: a mensagem
+// chega sem depender da retransmissao nem do fechamento por inatividade (ambos em 1h aqui)
+func TestFlushWhenQueueDrains(t *testing.T) {
+	dir := t.TempDir()
+	addrA, addrB := "unix://"+filepath.Join(dir, "a.sock"), "unix://"+filepath.Join(dir, "b.sock")
+	slow := PP2PLink_Options{RetransmitTimeout: time.Hour, IdleTimeout: time.Hour}
+	a, err := NewPP2PLinkWithOptions(addrA, false, slow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewPP2PLinkWithOptions(addrB, false, slow)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a.Req <- PP2PLink_Req_Message{To: addrB, Message: "sozinha"}
+	receive(t, b, "sozinha")
+	a.ReqBatch <- []PP2PLink_Req_Message{{To: addrB, Message: "l1"}, {To: addrB, Message: "l2"}}
+	receive(t, b, "l1")
+	receive(t, b, "l2")
+	if r := a.Metrics().Retransmitted; r != 0 {
+		t.Fatalf("%d retransmissoes: a entrega nao veio do flush", r)
+	}
+}
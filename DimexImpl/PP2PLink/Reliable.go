@@ -0,0 +1,297 @@
+/*
+  Entrega confiavel sobre as conexoes TCP do PP2PLink, para manter as garantias
+  de perfect link (sem perda, sem duplicacao, FIFO) mesmo quando conexoes caem.
+    - cada destino tem numeros de sequencia proprios (1, 2, 3, ...)
+    - mensagens enviadas ficam no buffer de retransmissao ate serem confirmadas (ack)
+    - acks sao cumulativos: "recebi tudo ate seq"
+    - o receptor descarta duplicadas, guarda as adiantadas e entrega em ordem
+    - a epoca (instante de criacao do link) identifica a encarnacao do remetente:
+      se o processo reinicia, o receptor recomeca a sequencia daquele remetente
+    - se o receptor reinicia, ele confirma seq 0 (espera o 1, que ja foi confirmado
+      pela encarnacao anterior): o remetente passa a uma nova epoca para aquele destino
+      e renumera a partir de 1 as mensagens ainda sem ack
+    - enquanto a entrega de um remetente esta parada (modulo de cima lento), o receptor
+      envia heartbeats a ele: sem ack, mas vivo (ver staleDestinations em Connections.go)
+  Formato dos quadros (dentro do enquadramento de tamanho do PP2PLink):
+     D|<epoca do remetente>|<endereco do remetente>|<seq>|<mensagem>
+     A|<epoca confirmada>|<endereco de quem confirma>|<seq>
+     H|<epoca do remetente>|<endereco do remetente>|0
+*/
+
+package PP2PLink
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	frameData      = "D"
+	frameAck       = "A"
+	frameHeartbeat = "H"
+
+	defaultRetransmitTimeout = 200 * time.Millisecond
+)
+
+type frame struct {
+	kind    string
+	epoch   int64
+	from    string
+	seq     uint64
+	payload string
+}
+
+func encodeData(epoch int64, from string, seq uint64, payload string) string {
+	return frameData + "|" + strconv.FormatInt(epoch, 10) + "|" + from + "|" + strconv.FormatUint(seq, 10) + "|" + payload
+}
+
+func encodeAck(epoch int64, from string, seq uint64) string {
+	return frameAck + "|" + strconv.FormatInt(epoch, 10) + "|" + from + "|" + strconv.FormatUint(seq, 10)
+}
+
+func encodeHeartbeat(epoch int64, from string) string {
+	return frameHeartbeat + "|" + strconv.FormatInt(epoch, 10) + "|" + from + "|0"
+}
+
+func decodeFrame(s string) (frame, error) {
+	parts := strings.SplitN(s, "|", 5)
+	valid := (parts[0] == frameData && len(parts) == 5) ||
+		((parts[0] == frameAck || parts[0] == frameHeartbeat) && len(parts) == 4)
+	if !valid {
+		return frame{}, fmt.Errorf("quadro invalido: %q", s)
+	}
+	epoch, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return frame{}, fmt.Errorf("quadro com epoca invalida: %q", s)
+	}
+	seq, err := strconv.ParseUint(parts[3], 10, 64)
+	if err != nil {
+		return frame{}, fmt.Errorf("quadro com seq invalido: %q", s)
+	}
+	f := frame{kind: parts[0], epoch: epoch, from: parts[2], seq: seq}
+	if f.kind == frameData {
+		f.payload = parts[4]
+	}
+	return f, nil
+}
+
+// ------------------------------------------------------------------------------------
+// ------- lado do envio
+// ------------------------------------------------------------------------------------
+
+type outMsg struct {
+	seq       uint64
+	frame     string
+	sentAt    time.Time // ultima (re)transmissao
+	firstSent time.Time // para detectar conexao meio-aberta (Connections.go)
+}
+
+type outState struct {
+	epoch   int64 // epoca usada com este destino; muda se o destino reinicia
+	nextSeq uint64
+	acked   uint64    // maior ack cumulativo recebido
+	unacked []*outMsg // em ordem de seq
+	heard   time.Time // ultimo quadro (dados, ack ou heartbeat) recebido do destino
+}
+
+// payload e' o conteudo enviado: Data, se houver, senao Message
+func (message PP2PLink_Req_Message) payload() string {
+	if message.Data != nil {
+		return string(message.Data)
+	}
+	return message.Message
+}
+
+// enqueue da numero de sequencia e guarda a mensagem para retransmissao
+func (module *PP2PLink) enqueue(message PP2PLink_Req_Message) *outMsg {
+	module.relMutex.Lock()
+	defer module.relMutex.Unlock()
+	st, ok := module.out[message.To]
+	if !ok {
+		st = &outState{epoch: module.epoch}
+		module.out[message.To] = st
+	}
+	st.nextSeq++
+	now := time.Now()
+	m := &outMsg{
+		seq:       st.nextSeq,
+		frame:     encodeData(st.epoch, module.address, st.nextSeq, message.payload()),
+		sentAt:    now,
+		firstSent: now}
+	st.unacked = append(st.unacked, m)
+	return m
+}
+
+// dueRetransmissions devolve, por destino, os quadros sem ack ha mais de rto
+func (module *PP2PLink) dueRetransmissions() map[string][]string {
+	module.relMutex.Lock()
+	defer module.relMutex.Unlock()
+	now := time.Now()
+	due := make(map[string][]string)
+	for to, st := range module.out {
+		for _, m := range st.unacked {
+			if now.Sub(m.sentAt) >= module.rto {
+				m.sentAt = now
+				due[to] = append(due[to], m.frame)
+			}
+		}
+	}
+	return due
+}
+
+// retransmit coloca nas filas os quadros vencidos; fila cheia fica para o proximo ciclo
+func (module *PP2PLink) retransmit() {
+	for to, frames := range module.dueRetransmissions() {
+		module.outDbg("retransmitindo " + strconv.Itoa(len(frames)) + " mensagens para " + to)
+		d := module.destinationFor(to)
+		for _, f := range frames {
+			if !module.push(d, f, OverflowDropNewest) {
+				break
+			}
+			count(&module.metrics.retransmitted)
+		}
+	}
+}
+
+// onAck descarta do buffer de retransmissao tudo ate seq
+func (module *PP2PLink) onAck(f frame) {
+	module.relMutex.Lock()
+	defer module.relMutex.Unlock()
+	st, ok := module.out[f.from]
+	if !ok || f.epoch != st.epoch {
+		return // ack de mensagens de uma encarnacao anterior deste processo (ou epoca anterior do destino)
+	}
+	if f.seq == 0 && st.acked > 0 {
+		module.restartStream(f.from, st)
+		return
+	}
+	if f.seq > st.acked {
+		st.acked = f.seq
+	}
+	i := 0
+	for i < len(st.unacked) && st.unacked[i].seq <= f.seq {
+		i++
+	}
+	st.unacked = st.unacked[i:]
+}
+
+// onHeard anota que o destino respondeu (qualquer quadro vindo dele)
+func (module *PP2PLink) onHeard(from string) {
+	module.relMutex.Lock()
+	defer module.relMutex.Unlock()
+	if st, ok := module.out[from]; ok {
+		st.heard = time.Now()
+	}
+}
+
+// restartStream renumera as mensagens sem ack para um destino que reiniciou.
+// A nova epoca (maior que a anterior) faz o receptor descartar o que guardou fora de ordem
+func (module *PP2PLink) restartStream(to string, st *outState) {
+	module.outDbg("destino reiniciou, renumerando " + strconv.Itoa(len(st.unacked)) + " mensagens para " + to)
+	epoch := time.Now().UnixNano()
+	if epoch <= st.epoch {
+		epoch = st.epoch + 1
+	}
+	st.epoch = epoch
+	st.nextSeq = 0
+	st.acked = 0
+	now := time.Now()
+	for _, m := range st.unacked {
+		old, _ := decodeFrame(m.frame)
+		st.nextSeq++
+		m.seq = st.nextSeq
+		m.frame = encodeData(st.epoch, module.address, m.seq, old.payload)
+		m.sentAt = time.Time{} // retransmite no proximo ciclo
+		m.firstSent = now
+	}
+}
+
+// ------------------------------------------------------------------------------------
+// ------- lado da recepcao
+// ------------------------------------------------------------------------------------
+
+type inState struct {
+	mutex      sync.Mutex
+	epoch      int64
+	expected   uint64            // proximo seq a entregar
+	buffer     map[uint64]string // recebidas fora de ordem
+	delivering int32             // 1 enquanto espera o modulo de cima ler Ind (atomico: lido sem mutex)
+}
+
+func (module *PP2PLink) inStateFor(from string) *inState {
+	module.relMutex.Lock()
+	defer module.relMutex.Unlock()
+	st, ok := module.in[from]
+	if !ok {
+		st = &inState{expected: 1, buffer: make(map[uint64]string)}
+		module.in[from] = st
+	}
+	return st
+}
+
+// onData entrega em ordem, sem duplicatas, e confirma o que ja foi entregue
+func (module *PP2PLink) onData(f frame, identity string) {
+	st := module.inStateFor(f.from)
+	st.mutex.Lock() // mantido durante a entrega: conexoes diferentes do mesmo remetente nao embaralham a ordem
+
+	if f.epoch < st.epoch {
+		st.mutex.Unlock()
+		count(&module.metrics.stale)
+		return // quadro de uma encarnacao anterior do remetente
+	}
+	if f.epoch > st.epoch {
+		st.epoch = f.epoch
+		st.expected = 1
+		st.buffer = make(map[uint64]string)
+	}
+
+	if f.seq < st.expected {
+		count(&module.metrics.duplicates)
+		module.outDbg("duplicada descartada: seq " + strconv.FormatUint(f.seq, 10) + " de " + f.from)
+	} else {
+		st.buffer[f.seq] = f.payload
+		for {
+			payload, ok := st.buffer[st.expected]
+			if !ok {
+				break
+			}
+			delete(st.buffer, st.expected)
+			st.expected++
+			msg := PP2PLink_Ind_Message{
+				From:     f.from,
+				Message:  payload,
+				Data:     []byte(payload),
+				Identity: identity}
+			module.traceEvent(traceRecv, msg.From, msg.Message)
+			count(&module.metrics.delivered)
+			atomic.StoreInt32(&st.delivering, 1)
+			module.Ind <- msg //               // repassa mensagem para modulo superior
+			atomic.StoreInt32(&st.delivering, 0)
+		}
+	}
+	ack := encodeAck(st.epoch, module.address, st.expected-1)
+	st.mutex.Unlock()
+
+	// ack vai pela fila do remetente; se a fila estiver cheia o proximo ack cumulativo cobre
+	module.push(module.destinationFor(f.from), ack, OverflowDropNewest)
+}
+
+// heartbeat avisa os remetentes cuja entrega esta parada esperando o modulo de cima:
+// as mensagens deles ficam sem ack, mas a conexao nao esta meio-aberta
+func (module *PP2PLink) heartbeat() {
+	module.relMutex.Lock()
+	var waiting []string
+	for from, st := range module.in {
+		if atomic.LoadInt32(&st.delivering) == 1 {
+			waiting = append(waiting, from)
+		}
+	}
+	module.relMutex.Unlock()
+	for _, from := range waiting {
+		module.push(module.destinationFor(from), encodeHeartbeat(module.epoch, module.address), OverflowDropNewest)
+	}
+}
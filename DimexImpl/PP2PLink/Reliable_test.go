@@ -0,0 +1,112 @@
+package PP2PLink
+
+import (
+	"net"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// killableListener guarda as conexoes aceitas para simular a queda do processo
+type killableListener struct {
+	net.Listener
+	mutex sync.Mutex
+	conns []net.Conn
+}
+
+func (l *killableListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err == nil {
+		l.mutex.Lock()
+		l.conns = append(l.conns, conn)
+		l.mutex.Unlock()
+	}
+	return conn, err
+}
+
+func (l *killableListener) kill() {
+	l.Listener.Close()
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	for _, conn := range l.conns {
+		conn.Close()
+	}
+}
+
+func (module *PP2PLink) unackedTo(to string) int {
+	module.relMutex.Lock()
+	defer module.relMutex.Unlock()
+	if st, ok := module.out[to]; ok {
+		return len(st.unacked)
+	}
+	return 0
+}
+
+func receive(t *testing.T, link *PP2PLink, want string) {
+	t.Helper()
+	select {
+	case msg := <-link.Ind:
+		if msg.Message != want {
+			t.Fatalf("recebido %q, esperado %q", msg.Message, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("%q nao foi entregue", want)
+	}
+}
+
+// o receptor reinicia no meio da sequencia: a nova encarnacao espera o seq 1, que ja foi
+// confirmado pela anterior. O remetente renumera o que estava sem ack e tudo e' entregue
+func TestReceiverRestartMidStream(t *testing.T) {
+	dir := t.TempDir()
+	addrA, addrB := "unix://"+filepath.Join(dir, "a.sock"), "unix://"+filepath.Join(dir, "b.sock")
+	opts := PP2PLink_Options{RetransmitTimeout: 20 * time.Millisecond, RedialMax: 50 * time.Millisecond}
+	a, err := NewPP2PLinkWithOptions(addrA, false, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var first *killableListener
+	firstOpts := opts
+	firstOpts.Listen = func(network string, address string) (net.Listener, error) {
+		l, err := defaultListen(network, address)
+		if err != nil {
+			return nil, err
+		}
+		first = &killableListener{Listener: l}
+		return first, nil
+	}
+	b1, err := NewPP2PLinkWithOptions(addrB, false, firstOpts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a.Req <- PP2PLink_Req_Message{To: addrB, Message: "m1"}
+	a.Req <- PP2PLink_Req_Message{To: addrB, Message: "m2"}
+	receive(t, b1, "m1")
+	receive(t, b1, "m2")
+	for deadline := time.Now().Add(5 * time.Second); a.unackedTo(addrB) > 0; time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("m1 e m2 nao foram confirmadas")
+		}
+	}
+
+	first.kill()
+	for _, m := range []string{"m3", "m4", "m5"} {
+		a.Req <- PP2PLink_Req_Message{To: addrB, Message: m}
+	}
+	time.Sleep(50 * time.Millisecond) // retransmissoes para o processo caido
+
+	b2, err := NewPP2PLinkWithOptions(addrB, false, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range []string{"m3", "m4", "m5"} {
+		receive(t, b2, m)
+	}
+	select {
+	case msg := <-b2.Ind:
+		t.Fatalf("mensagem a mais: %q", msg.Message)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
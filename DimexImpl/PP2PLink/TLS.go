@@ -0,0 +1,200 @@
+/*
+  Modo TLS do PP2PLink, com autenticacao mutua.
+  Todos os processos usam certificados assinados pela CA do cluster; o certificado
+  do processo i tem CommonName "proc-<i>" (ProcessIdentity). Em conexoes de entrada
+  o CommonName do certificado do outro processo vai em Identity de cada mensagem
+  entregue, e o modulo de cima confere com o id que a mensagem diz ter (DIMEX faz isso).
+  Com a opcao Peers o proprio link confere o remetente dos quadros (dados e acks):
+  numa conexao com o certificado ProcessIdentity(i) so valem quadros de Peers[i].
+  Os outros sao descartados e contados em Forged.
+  Arquivos esperados num diretorio de certificados (gerados por GenerateCluster ou
+  por "go run ./cmd/dimexca"):
+     ca.pem, proc-<i>.pem, proc-<i>-key.pem
+*/
+
+package PP2PLink
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// ProcessIdentity e' o CommonName do certificado do processo id
+func ProcessIdentity(id int) string {
+	return "proc-" + strconv.Itoa(id)
+}
+
+// LoadTLSConfig monta a configuracao TLS de um processo: apresenta o proprio
+// certificado e exige certificado assinado pela CA nos dois sentidos
+func LoadTLSConfig(caFile string, certFile string, keyFile string) (*tls.Config, error) {
+	caPEM, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("%s: nenhum certificado de CA", caFile)
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      pool,
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+// LoadProcessTLSConfig le os arquivos do processo id no diretorio de certificados
+func LoadProcessTLSConfig(dir string, id int) (*tls.Config, error) {
+	name := ProcessIdentity(id)
+	return LoadTLSConfig(filepath.Join(dir, "ca.pem"),
+		filepath.Join(dir, name+".pem"), filepath.Join(dir, name+"-key.pem"))
+}
+
+// peerAddresses associa o certificado de cada processo ao seu endereco
+func peerAddresses(peers []string) map[string]string {
+	if len(peers) == 0 {
+		return nil
+	}
+	addrs := make(map[string]string, len(peers))
+	for i, addr := range peers {
+		addrs[ProcessIdentity(i)] = addr
+	}
+	return addrs
+}
+
+// fromMatches confere o remetente do quadro com o certificado da conexao em que chegou
+func (module *PP2PLink) fromMatches(identity string, from string) bool {
+	if identity == "" || module.peerAddrs == nil {
+		return true // sem TLS ou sem Peers nao ha o que conferir
+	}
+	addr, ok := module.peerAddrs[identity]
+	return ok && addr == from
+}
+
+// peerIdentity faz o handshake de uma conexao aceita e devolve o CommonName do outro processo
+func peerIdentity(conn net.Conn) (string, error) {
+	tlsConn, ok := conn.(*tls.Conn)
+	if !ok {
+		return "", nil // sem TLS nao ha identidade
+	}
+	if err := tlsConn.Handshake(); err != nil {
+		return "", err
+	}
+	certs := tlsConn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return "", errors.New("outro processo nao apresentou certificado")
+	}
+	return certs[0].Subject.CommonName, nil
+}
+
+// ------------------------------------------------------------------------------------
+// ------- geracao de CA local e certificados (testes e clusters locais)
+// ------------------------------------------------------------------------------------
+
+// GenerateCluster cria em dir uma CA e certificados para os processos 0..n-1,
+// validos para os hosts dados (IPs ou nomes)
+func GenerateCluster(dir string, n int, hosts []string, validity time.Duration) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          serialNumber(),
+		Subject:               pkix.Name{CommonName: "DIMEX cluster CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(validity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		return err
+	}
+	caCert, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		return err
+	}
+	if err := writePEM(filepath.Join(dir, "ca.pem"), "CERTIFICATE", caDER, 0644); err != nil {
+		return err
+	}
+	if err := writeKey(filepath.Join(dir, "ca-key.pem"), caKey); err != nil {
+		return err
+	}
+	for id := 0; id < n; id++ {
+		if err := generateProcessCert(dir, id, hosts, validity, caCert, caKey); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func generateProcessCert(dir string, id int, hosts []string, validity time.Duration, caCert *x509.Certificate, caKey *ecdsa.PrivateKey) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	name := ProcessIdentity(id)
+	template := &x509.Certificate{
+		SerialNumber: serialNumber(),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(validity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		// o mesmo certificado serve para aceitar (servidor) e abrir (cliente) conexoes
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, h)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
+	if err != nil {
+		return err
+	}
+	if err := writePEM(filepath.Join(dir, name+".pem"), "CERTIFICATE", der, 0644); err != nil {
+		return err
+	}
+	return writeKey(filepath.Join(dir, name+"-key.pem"), key)
+}
+
+func serialNumber() *big.Int {
+	n, _ := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 62))
+	return n
+}
+
+func writeKey(path string, key *ecdsa.PrivateKey) error {
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+	return writePEM(path, "EC PRIVATE KEY", der, 0600)
+}
+
+func writePEM(path string, kind string, der []byte, perm os.FileMode) error {
+	return os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: kind, Bytes: der}), perm)
+}
@@ -0,0 +1,70 @@
+package PP2PLink
+
+import (
+	"crypto/tls"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// um processo com certificado valido (proc-2) nao consegue confirmar mensagens
+// enviadas a outro processo (proc-1) dizendo ser ele
+func TestTLSRejectsForgedSender(t *testing.T) {
+	dir := t.TempDir()
+	if err := GenerateCluster(dir, 3, []string{"localhost"}, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	addrs := []string{
+		"unix://" + filepath.Join(dir, "p0.sock"),
+		"unix://" + filepath.Join(dir, "p1.sock"), // nao escuta: a mensagem fica sem ack
+		"unix://" + filepath.Join(dir, "p2.sock"),
+	}
+	cfg0, err := LoadProcessTLSConfig(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := NewPP2PLinkWithOptions(addrs[0], false, PP2PLink_Options{TLS: cfg0, Peers: addrs})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.Req <- PP2PLink_Req_Message{To: addrs[1], Message: "m"}
+	for deadline := time.Now().Add(5 * time.Second); a.unackedTo(addrs[1]) != 1; time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("mensagem nao entrou no buffer de retransmissao")
+		}
+	}
+
+	cfg2, err := LoadProcessTLSConfig(dir, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg2.ServerName = "localhost"
+	raw, err := net.Dial("unix", filepath.Join(dir, "p0.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := tls.Client(raw, cfg2)
+	defer conn.Close()
+	// ack forjado em nome de proc-1, seguido de uma mensagem legitima de proc-2
+	for _, frame := range []string{encodeAck(a.epoch, addrs[1], 1), encodeData(1, addrs[2], 1, "ola")} {
+		if err := writeFrame(conn, frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	select {
+	case msg := <-a.Ind:
+		if msg.Message != "ola" || msg.From != addrs[2] || msg.Identity != ProcessIdentity(2) {
+			t.Fatalf("entregue %+v", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("mensagem legitima de proc-2 nao foi entregue")
+	}
+	if n := a.unackedTo(addrs[1]); n != 1 {
+		t.Errorf("ack forjado aceito: %d mensagens sem ack para proc-1", n)
+	}
+	if m := a.Metrics(); m.Forged != 1 {
+		t.Errorf("Forged = %d, esperado 1", m.Forged)
+	}
+}
@@ -0,0 +1,118 @@
+/*
+  Trace das mensagens que passam pelo PP2PLink, e caminho em memoria (sem rede)
+  usado para reproduzir (replay) um trace gravado.
+  Formato do trace, uma linha por evento, campos separados por tab:
+     <unixnano>  <SEND|RECV|APP|DLVR>  <endereco local>  <endereco do par>  <payload com strconv.Quote>
+  Em RECV e DLVR o endereco do par e' o endereco de escuta do remetente.
+  APP e DLVR sao gravados pelo modulo de cima (TraceApp, TraceDeliver): as entradas do modulo
+  (pedidos da aplicacao e mensagens recebidas) na ordem em que foram tratadas. Em APP o
+  endereco do par e' "app" e o payload e' o pedido.
+*/
+
+package PP2PLink
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	traceSend    = "SEND"
+	traceRecv    = "RECV"
+	traceApp     = "APP"
+	traceDeliver = "DLVR"
+)
+
+type PP2PLink_Trace_Event struct {
+	Time    int64 // unix nano no relogio local
+	Dir     string
+	Local   string
+	Peer    string
+	Message string
+}
+
+func (module *PP2PLink) traceEvent(dir string, peer string, message string) {
+	if module.trace == nil {
+		return
+	}
+	module.traceMutex.Lock()
+	defer module.traceMutex.Unlock()
+	_, err := fmt.Fprintf(module.trace, "%d\t%s\t%s\t%s\t%s\n",
+		time.Now().UnixNano(), dir, module.address, peer, strconv.Quote(message))
+	if err != nil {
+		module.outDbg("erro : gravando trace: " + err.Error())
+	}
+}
+
+// maior linha de trace: um quadro de defaultMaxFrameSize entre aspas (strconv.Quote
+// usa ate 4 bytes por byte do payload) mais os outros campos
+const maxTraceLine = 4*defaultMaxFrameSize + 4096
+
+// ReadTrace le um trace gravado por um PP2PLink
+func ReadTrace(r io.Reader) ([]PP2PLink_Trace_Event, error) {
+	var events []PP2PLink_Trace_Event
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxTraceLine)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		parts := strings.SplitN(line, "\t", 5)
+		if len(parts) != 5 || !knownTraceDir(parts[1]) {
+			return nil, fmt.Errorf("trace linha %d: formato invalido: %s", lineNumber, line)
+		}
+		ts, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("trace linha %d: timestamp invalido: %v", lineNumber, err)
+		}
+		msg, err := strconv.Unquote(parts[4])
+		if err != nil {
+			return nil, fmt.Errorf("trace linha %d: payload invalido: %v", lineNumber, err)
+		}
+		events = append(events, PP2PLink_Trace_Event{
+			Time:    ts,
+			Dir:     parts[1],
+			Local:   parts[2],
+			Peer:    parts[3],
+			Message: msg})
+	}
+	return events, scanner.Err()
+}
+
+func knownTraceDir(dir string) bool {
+	return dir == traceSend || dir == traceRecv || dir == traceApp || dir == traceDeliver
+}
+
+func (ev PP2PLink_Trace_Event) IsSend() bool    { return ev.Dir == traceSend }
+func (ev PP2PLink_Trace_Event) IsRecv() bool    { return ev.Dir == traceRecv }
+func (ev PP2PLink_Trace_Event) IsApp() bool     { return ev.Dir == traceApp }
+func (ev PP2PLink_Trace_Event) IsDeliver() bool { return ev.Dir == traceDeliver }
+
+// NewMemPP2PLink cria um link sem rede: nada le Req nem escreve em Ind.
+// Quem cria o link faz o papel da rede - le o que o modulo de cima envia por Req
+// e entrega mensagens por Ind (ex: replay de trace).
+func NewMemPP2PLink(_address string, _dbg bool) *PP2PLink {
+	p2p := newLink(_address, _dbg, PP2PLink_Options{})
+	p2p.outDbg(" Init PP2PLink em memoria!")
+	return p2p
+}
+
+// TraceApp grava um pedido da aplicacao ao modulo de cima (ex: ENTER do DIMEX),
+// no momento em que o modulo o trata
+func (module *PP2PLink) TraceApp(request string) {
+	module.traceEvent(traceApp, "app", request)
+}
+
+// TraceDeliver grava o momento em que o modulo de cima trata uma mensagem recebida.
+// RECV e' gravado na chegada ao link; entre a chegada e o tratamento o modulo pode
+// tratar outras entradas, entao o replay usa APP e DLVR, nao RECV
+func (module *PP2PLink) TraceDeliver(from string, message string) {
+	module.traceEvent(traceDeliver, from, message)
+}
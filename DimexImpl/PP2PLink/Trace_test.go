@@ -0,0 +1,21 @@
+package PP2PLink
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// uma linha com payload maior que o buffer padrao do bufio.Scanner (64KB) ainda e' lida
+func TestReadTraceLargePayload(t *testing.T) {
+	payload := strings.Repeat("x", 1<<20)
+	line := fmt.Sprintf("1\t%s\t127.0.0.1:5000\t127.0.0.1:6000\t%s\n", traceSend, strconv.Quote(payload))
+	events, err := ReadTrace(strings.NewReader(line))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Message != payload {
+		t.Fatalf("%d eventos lidos", len(events))
+	}
+}
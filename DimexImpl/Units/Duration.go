@@ -0,0 +1,32 @@
+/*
+  Tipos usados nos arquivos de configuracao e de resultado (JSON) da aplicacao,
+  do benchmark e do PP2PLink.
+*/
+
+package Units
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Duration aceita "10ms", "1s" etc. no JSON
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duracao deve ser string como \"10ms\": %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
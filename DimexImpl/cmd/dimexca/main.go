@@ -0,0 +1,34 @@
+// Gera a CA local do cluster e os certificados dos processos para o modo TLS do PP2PLink.
+// Uso:
+//   go run ./cmd/dimexca -dir certs -n 3 -hosts 127.0.0.1,::1,localhost
+// e depois, para cada processo:
+//   DIMEX_TLS_DIR=certs go run useDIMEX-f.go 0 127.0.0.1:5000 127.0.0.1:6001 127.0.0.1:7002
+
+package main
+
+import (
+	PP2PLink "SD/PP2PLink"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+func main() {
+	dir := flag.String("dir", "certs", "diretorio de saida")
+	n := flag.Int("n", 3, "numero de processos")
+	hosts := flag.String("hosts", "127.0.0.1,::1,localhost", "IPs e nomes dos processos, separados por virgula")
+	validity := flag.Duration("validity", 365*24*time.Hour, "validade dos certificados")
+	flag.Parse()
+
+	if *n <= 0 {
+		fmt.Println("uso: go run ./cmd/dimexca -dir <diretorio> -n <processos> -hosts <ip,nome,...>")
+		os.Exit(2)
+	}
+	if err := PP2PLink.GenerateCluster(*dir, *n, strings.Split(*hosts, ","), *validity); err != nil {
+		fmt.Println("Error generating certificates:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("CA e certificados de %d processos gravados em %s\n", *n, *dir)
+}
@@ -0,0 +1,113 @@
+// Processo do armazenamento chave-valor replicado (ver KV/KV.go) com clientes de teste.
+// Cada cliente faz operacoes aleatorias (get, put, add) sobre poucas chaves durante
+// -duration; a historia e' gravada em <dir>/history_proc_<id>.json para o cmd/kvcheck.
+// Depois dos clientes, o processo continua respondendo aos outros por -linger.
+// Uso (um por processo, mesma lista de enderecos):
+//   go run ./cmd/kv -id 0 127.0.0.1:5000 127.0.0.1:6001 127.0.0.1:7002
+//   go run ./cmd/kvcheck history_proc_*.json
+
+package main
+
+import (
+	KV "SD/KV"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+func main() {
+	id := flag.Int("id", 0, "id do processo (indice na lista de enderecos)")
+	stripes := flag.Int("stripes", 4, "numero de locks DIMEX (stripes); usa as portas seguintes a do processo, que nao podem ser de outro processo")
+	clients := flag.Int("clients", 4, "clientes concorrentes neste processo")
+	keys := flag.Int("keys", 4, "numero de chaves usadas pelos clientes")
+	duration := flag.Duration("duration", 10*time.Second, "tempo gerando operacoes")
+	linger := flag.Duration("linger", 5*time.Second, "tempo respondendo aos outros processos depois dos clientes")
+	dir := flag.String("dir", ".", "diretorio da historia gravada")
+	dbg := flag.Bool("dbg", false, "liga debug")
+	flag.Parse()
+	addresses := flag.Args()
+
+	if len(addresses) == 0 || *id < 0 || *id >= len(addresses) {
+		fmt.Println("uso: go run ./cmd/kv -id <id> <enderecos de todos processos...>")
+		os.Exit(2)
+	}
+
+	kv, err := KV.NewKV(addresses, *id, *stripes, *dbg)
+	if err != nil {
+		fmt.Println("Error starting KV:", err)
+		os.Exit(1)
+	}
+	go func() {
+		for err := range kv.Pp2plink.Err {
+			fmt.Println("Link error:", err)
+		}
+	}()
+
+	// respostas voltam por Id para o cliente que pediu
+	var mutex sync.Mutex
+	waiting := make(map[int]chan KV.KV_Ind_Message)
+	go func() {
+		for ind := range kv.Ind {
+			mutex.Lock()
+			ch := waiting[ind.Id]
+			delete(waiting, ind.Id)
+			mutex.Unlock()
+			ch <- ind
+		}
+	}()
+	nextId := 0
+	do := func(req KV.KV_Req_Message) KV.KV_Ind_Message {
+		ch := make(chan KV.KV_Ind_Message, 1)
+		mutex.Lock()
+		nextId++
+		req.Id = nextId
+		waiting[req.Id] = ch
+		mutex.Unlock()
+		kv.Req <- req
+		return <-ch
+	}
+
+	history := KV.NewHistory()
+	deadline := time.Now().Add(*duration)
+	var wg sync.WaitGroup
+	for c := 0; c < *clients; c++ {
+		wg.Add(1)
+		go func(c int) {
+			defer wg.Done()
+			client := "p" + strconv.Itoa(*id) + "c" + strconv.Itoa(c)
+			r := rand.New(rand.NewSource(time.Now().UnixNano() + int64(c)))
+			for time.Now().Before(deadline) {
+				req := KV.KV_Req_Message{Key: "k" + strconv.Itoa(r.Intn(*keys))}
+				switch x := r.Intn(10); {
+				case x < 5:
+					req.Op = KV.GET
+				case x < 8:
+					req.Op, req.Value = KV.ADD, "1"
+				default:
+					req.Op, req.Value = KV.PUT, strconv.Itoa(r.Intn(1000))
+				}
+				op := history.Call(client, req.Op, req.Key, req.Value)
+				ind := do(req)
+				if ind.Err != nil {
+					fmt.Println("Error:", ind.Err)
+					continue // sem retorno na historia: pode ou nao ter tido efeito
+				}
+				history.Return(op, ind.Value)
+			}
+		}(c)
+	}
+	wg.Wait()
+
+	path := filepath.Join(*dir, "history_proc_"+strconv.Itoa(*id)+".json")
+	if err := history.WriteFile(path); err != nil {
+		fmt.Println("Error writing history:", err)
+		os.Exit(1)
+	}
+	fmt.Println("processo", *id, ":", len(history.Operations()), "operacoes gravadas em", path)
+	time.Sleep(*linger)
+}
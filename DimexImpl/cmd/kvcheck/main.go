@@ -0,0 +1,79 @@
+// Verifica a linearizabilidade das historias gravadas pelo cmd/kv (ver KV/Linearizability.go).
+// Todas as historias de uma execucao devem ser passadas juntas.
+// Uso:
+//   go run ./cmd/kvcheck [-v] history_proc_0.json history_proc_1.json ...
+// Sai com codigo 1 se alguma chave nao e' linearizavel.
+
+package main
+
+import (
+	KV "SD/KV"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+func main() {
+	verbose := flag.Bool("v", false, "mostra as operacoes das chaves nao linearizaveis")
+	flag.Parse()
+	if flag.NArg() == 0 {
+		fmt.Println("uso: go run ./cmd/kvcheck [-v] <historias...>")
+		os.Exit(2)
+	}
+
+	var ops []KV.Operation
+	for _, path := range flag.Args() {
+		f, err := os.Open(path)
+		if err != nil {
+			fmt.Println("Error opening history:", err)
+			os.Exit(2)
+		}
+		h, err := KV.ReadHistory(f)
+		f.Close()
+		if err != nil {
+			fmt.Println("Error reading history:", path, err)
+			os.Exit(2)
+		}
+		ops = append(ops, h...)
+	}
+
+	start := time.Now()
+	results := KV.CheckLinearizable(ops)
+	violations := 0
+	for _, r := range results {
+		status := "linearizavel"
+		if !r.Linearizable {
+			status = "NAO linearizavel"
+			violations++
+		}
+		fmt.Printf("chave %-8s %6d operacoes: %s\n", r.Key, r.Ops, status)
+		if !r.Linearizable && *verbose {
+			printOps(ops, r.Key)
+		}
+	}
+	fmt.Printf("\nTotal: %d operacoes, %d chave(s), %d nao linearizavel(is) (%v)\n",
+		len(ops), len(results), violations, time.Since(start).Round(time.Millisecond))
+	if violations > 0 {
+		os.Exit(1)
+	}
+}
+
+func printOps(ops []KV.Operation, key string) {
+	var keyOps []KV.Operation
+	for _, o := range ops {
+		if o.Key == key {
+			keyOps = append(keyOps, o)
+		}
+	}
+	sort.Slice(keyOps, func(i, j int) bool { return keyOps[i].Call < keyOps[j].Call })
+	base := keyOps[0].Call
+	for _, o := range keyOps {
+		ret := "        -"
+		if o.Return != 0 {
+			ret = fmt.Sprintf("%9.3f", float64(o.Return-base)/1e6)
+		}
+		fmt.Printf("   %9.3f %s ms  %-6s %-4s in=%-5s out=%s\n", float64(o.Call-base)/1e6, ret, o.Client, o.Op, o.Input, o.Output)
+	}
+}
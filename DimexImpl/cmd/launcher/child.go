@@ -0,0 +1,220 @@
+package main
+
+import (
+	"SD/Bench"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// childCluster roda cada processo DIMEX como processo filho
+type childCluster struct {
+	cfg    Config
+	addrs  []string
+	logs   *logMux
+	tmpDir string // binario compilado pelo launcher
+
+	mutex    sync.Mutex
+	procs    []*exec.Cmd
+	done     []chan struct{} // fechado quando o filho termina
+	states   []string
+	stopping bool
+	killed   []bool
+	exitedCh chan int
+}
+
+func newChildCluster(cfg Config, addrs []string, logs *logMux) *childCluster {
+	return &childCluster{cfg: cfg, addrs: addrs, logs: logs,
+		states: make([]string, cfg.N), killed: make([]bool, cfg.N), exitedCh: make(chan int, cfg.N)}
+}
+
+func (c *childCluster) start() error {
+	binary := c.cfg.Binary
+	if binary == "" {
+		var err error
+		if binary, err = c.build(); err != nil {
+			return err
+		}
+	}
+	for i := range c.addrs {
+		args := []string{"-id", strconv.Itoa(i), "-peers", strings.Join(c.addrs, ","),
+			"-debug", strconv.Itoa(c.cfg.Debug), "-algorithm", c.cfg.Algorithm,
+			"-readyTimeout", time.Duration(c.cfg.ReadyTimeout).String()}
+		if i == c.cfg.SnapshotInitiator {
+			args = append(args, "-snapshot", "-snapshotInterval", time.Duration(c.cfg.SnapshotInterval).String())
+		}
+		if c.cfg.Benchmark {
+			w := c.cfg.Bench
+			args = append(args, "-bench", "-arrival", w.Arrival, "-rate", fmt.Sprint(w.Rate),
+				"-hold", w.Hold.String(), "-benchThink", w.Think.String(), "-skew", fmt.Sprint(w.Skew),
+				"-duration", time.Duration(w.Duration).String(), "-seed", fmt.Sprint(w.Seed))
+		}
+		cmd := exec.Command(binary, args...)
+		cmd.Dir = c.cfg.Dir
+		out := c.logs.process(i)
+		cmd.Stdout = out
+		cmd.Stderr = out
+		if err := cmd.Start(); err != nil {
+			return fmt.Errorf("processo %d: %w", i, err)
+		}
+		c.logs.launcher(fmt.Sprintf("processo %d: pid %d", i, cmd.Process.Pid))
+		done := make(chan struct{})
+		c.mutex.Lock()
+		c.procs = append(c.procs, cmd)
+		c.done = append(c.done, done)
+		c.mutex.Unlock()
+		go c.wait(i, cmd, done)
+	}
+	return nil
+}
+
+// build compila o useDIMEX-f.go uma vez (em vez de um "go run" por processo)
+func (c *childCluster) build() (string, error) {
+	dir, err := os.MkdirTemp("", "dimex-launcher")
+	if err != nil {
+		return "", err
+	}
+	c.tmpDir = dir
+	binary := filepath.Join(dir, "useDIMEX-f")
+	c.logs.launcher("compilando useDIMEX-f.go")
+	cmd := exec.Command("go", "build", "-o", binary, "useDIMEX-f.go")
+	cmd.Dir = c.cfg.Dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("go build: %v\n%s", err, out)
+	}
+	return binary, nil
+}
+
+func (c *childCluster) wait(i int, cmd *exec.Cmd, done chan struct{}) {
+	err := cmd.Wait()
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	var exitErr *exec.ExitError
+	switch {
+	case c.killed[i]:
+		c.states[i] = "morto apos stopTimeout"
+	case c.stopping:
+		c.states[i] = "encerrado pelo launcher"
+	case err == nil:
+		c.states[i] = "terminou (codigo 0)"
+	case errors.As(err, &exitErr):
+		c.states[i] = "terminou: " + exitErr.String()
+	default:
+		c.states[i] = "terminou: " + err.Error()
+	}
+	if !c.stopping {
+		c.exitedCh <- i
+	}
+	close(done)
+}
+
+// ready espera cada processo aceitar conexoes no seu endereco; a barreira de partida
+// entre eles (hello de todos antes do primeiro pedido) e' feita pelo proprio useDIMEX-f
+func (c *childCluster) ready(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for i, addr := range c.addrs {
+		for {
+			conn, err := net.DialTimeout("tcp", addr, 200*time.Millisecond)
+			if err == nil {
+				conn.Close()
+				c.logs.launcher(fmt.Sprintf("processo %d pronto em %s", i, addr))
+				break
+			}
+			select {
+			case id := <-c.exitedCh:
+				c.exitedCh <- id // devolve para quem acompanha a execucao
+				return fmt.Errorf("processo %d terminou antes de ficar pronto", id)
+			default:
+			}
+			if time.Now().After(deadline) {
+				return fmt.Errorf("processo %d nao escuta em %s apos %v", i, addr, timeout)
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+	}
+	return nil
+}
+
+// benchResults espera cada processo gravar bench_proc_<id>.json no diretorio de trabalho
+func (c *childCluster) benchResults(timeout time.Duration) ([]Bench.Result, error) {
+	deadline := time.Now().Add(timeout)
+	res := make([]Bench.Result, len(c.addrs))
+	for i := range c.addrs {
+		for {
+			r, err := Bench.ReadResult(benchPath(c.cfg, i))
+			if err == nil { // o arquivo pode estar pela metade: entao tenta de novo
+				res[i] = r
+				break
+			}
+			select {
+			case id := <-c.exitedCh:
+				c.exitedCh <- id
+				return nil, fmt.Errorf("processo %d terminou antes do fim do benchmark", id)
+			default:
+			}
+			if time.Now().After(deadline) {
+				return nil, fmt.Errorf("processo %d sem resultado do benchmark apos %v", i, timeout)
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+	return res, nil
+}
+
+func (c *childCluster) exited() <-chan int {
+	return c.exitedCh
+}
+
+// stop envia SIGINT a todos e mata quem nao terminar em timeout
+func (c *childCluster) stop(timeout time.Duration) {
+	c.mutex.Lock()
+	c.stopping = true
+	procs, done := c.procs, c.done
+	c.mutex.Unlock()
+	for i, cmd := range procs {
+		select {
+		case <-done[i]:
+		default:
+			cmd.Process.Signal(os.Interrupt)
+		}
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	expired := false
+	for i, cmd := range procs {
+		if !expired {
+			select {
+			case <-done[i]:
+				continue
+			case <-timer.C:
+				expired = true
+			}
+		}
+		select {
+		case <-done[i]:
+			continue
+		default:
+		}
+		c.mutex.Lock()
+		c.killed[i] = true
+		c.mutex.Unlock()
+		cmd.Process.Kill()
+		<-done[i]
+	}
+	if c.tmpDir != "" {
+		os.RemoveAll(c.tmpDir)
+	}
+}
+
+func (c *childCluster) status() []string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return append([]string(nil), c.states...)
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"SD/Bench"
+	"SD/DIMEX"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// inprocCluster roda os N modulos DIMEX no proprio launcher, cada um com o laco
+// do useDIMEX-f ("|." no mxOUT.txt) ou o benchmark numa goroutine, iniciado depois da barreira de partida.
+// O debug dos modulos (nivel 2) sai sem prefixo.
+type inprocCluster struct {
+	cfg   Config
+	addrs []string
+	logs  *logMux
+
+	procs    []mutex
+	stopCh   chan struct{}
+	wg       sync.WaitGroup
+	mutex    sync.Mutex
+	states   []string
+	exitedCh chan int
+	benchCh  chan Bench.Result
+}
+
+func newInprocCluster(cfg Config, addrs []string, logs *logMux) *inprocCluster {
+	return &inprocCluster{cfg: cfg, addrs: addrs, logs: logs,
+		stopCh: make(chan struct{}), states: make([]string, cfg.N), exitedCh: make(chan int, cfg.N),
+		benchCh: make(chan Bench.Result, cfg.N)}
+}
+
+func (c *inprocCluster) start() error {
+	sink := DIMEX.NewFileSnapshotSink(filepath.Join(c.cfg.Dir, "..", "SnapshotAnalysis"), 1<<20, 5)
+	dbg := c.cfg.Debug >= 2
+	procs := make([]mutex, len(c.addrs))
+	for i := range c.addrs {
+		dmx, err := DIMEX.NewDIMEX(c.addrs, i, dbg, sink)
+		if err != nil {
+			return fmt.Errorf("processo %d: %w", i, err)
+		}
+		procs[i] = mutex{
+			enter:    func() { dmx.Req <- DIMEX.ENTER },
+			wait:     func(stop <-chan struct{}) bool { return waitInd(dmx.Ind, stop) },
+			exit:     func() { dmx.Req <- DIMEX.EXIT },
+			snapshot: func() { dmx.Req <- DIMEX.SNAPSHOT },
+			ready:    dmx.WaitReady,
+			sent:     func() uint64 { return dmx.Pp2plink.Metrics().Sent },
+			errs:     dmx.Err}
+	}
+	c.procs = procs
+	for i, p := range procs {
+		out := c.logs.process(i)
+		go func(errs <-chan error) {
+			for err := range errs {
+				fmt.Fprintln(out, "[ ERRO DIMEX: ", err, " ]")
+			}
+		}(p.errs)
+	}
+	return nil
+}
+
+// mutex esconde qual modulo de exclusao mutua o processo usa
+type mutex struct {
+	enter    func()
+	wait     func(stop <-chan struct{}) bool // espera a entrada na SC; false se stop fechou antes
+	exit     func()
+	snapshot func()
+	ready    func(timeout time.Duration) error // barreira de partida (WaitReady)
+	sent     func() uint64                     // mensagens enviadas pelo PP2PLink
+	errs     <-chan error
+}
+
+func waitInd[T any](ind <-chan T, stop <-chan struct{}) bool {
+	select {
+	case <-ind:
+		return true
+	case <-stop:
+		return false
+	}
+}
+
+// run e' o laco do useDIMEX-f ate o launcher pedir para parar
+func (c *inprocCluster) run(id int, dmx mutex, out io.Writer) {
+	defer c.wg.Done()
+	state := "encerrado pelo launcher"
+	defer func() {
+		c.mutex.Lock()
+		c.states[id] = state
+		c.mutex.Unlock()
+	}()
+	file, err := os.OpenFile(filepath.Join(c.cfg.Dir, "mxOUT.txt"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		state = "erro: " + err.Error()
+		c.exitedCh <- id
+		return
+	}
+	defer file.Close()
+	for {
+		select {
+		case <-c.stopCh:
+			return
+		default:
+		}
+		fmt.Fprintln(out, "[ APP id: ", id, " PEDE   MX ]")
+		dmx.enter()
+		if !dmx.wait(c.stopCh) {
+			state = "encerrado pelo launcher esperando a SC"
+			return
+		}
+		_, err := file.WriteString("|")
+		if err == nil {
+			fmt.Fprintln(out, "[ APP id: ", id, " *EM*   MX ]")
+			_, err = file.WriteString(".")
+		}
+		dmx.exit()
+		if err != nil {
+			state = "erro: " + err.Error()
+			c.exitedCh <- id
+			return
+		}
+		fmt.Fprintln(out, "[ APP id: ", id, " FORA   MX ]")
+	}
+}
+
+// bench roda a carga de Bench; no fim o modulo continua respondendo aos outros
+func (c *inprocCluster) bench(id int, dmx mutex, out io.Writer) {
+	defer c.wg.Done()
+	state := "encerrado pelo launcher"
+	defer func() {
+		c.mutex.Lock()
+		c.states[id] = state
+		c.mutex.Unlock()
+	}()
+	file, err := os.OpenFile(filepath.Join(c.cfg.Dir, "mxOUT.txt"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		state = "erro: " + err.Error()
+		c.exitedCh <- id
+		return
+	}
+	defer file.Close()
+	var writeErr error
+	mark := func(s string) {
+		if _, err := file.WriteString(s); err != nil && writeErr == nil {
+			writeErr = err
+		}
+	}
+	res := Bench.Run(c.cfg.Bench, id, Bench.Mutex{
+		Enter: func() bool {
+			dmx.enter()
+			if !dmx.wait(c.stopCh) {
+				return false
+			}
+			mark("|")
+			return true
+		},
+		Exit: func() { mark("."); dmx.exit() },
+		Sent: dmx.sent})
+	switch {
+	case writeErr != nil:
+		state = "erro: " + writeErr.Error()
+		c.exitedCh <- id
+	case res.Aborted:
+		state = "encerrado pelo launcher durante o benchmark"
+	default:
+		fmt.Fprintln(out, "[ APP id: ", id, " FIM DO BENCHMARK:", res.Entries, "entradas ]")
+		c.benchCh <- res
+	}
+}
+
+func (c *inprocCluster) benchResults(timeout time.Duration) ([]Bench.Result, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	var res []Bench.Result
+	for len(res) < len(c.addrs) {
+		select {
+		case r := <-c.benchCh:
+			res = append(res, r)
+		case <-timer.C:
+			return nil, fmt.Errorf("%d de %d processos sem resultado do benchmark apos %v", len(c.addrs)-len(res), len(c.addrs), timeout)
+		}
+	}
+	return res, nil
+}
+
+// snapshots pede um snapshot a cada SnapshotInterval, como startSnapshot do useDIMEX-f
+func (c *inprocCluster) snapshots(id int, dmx mutex, out io.Writer) {
+	for {
+		select {
+		case <-c.stopCh:
+			return
+		case <-time.After(time.Duration(c.cfg.SnapshotInterval)):
+		}
+		fmt.Fprintln(out, "[ APP id: ", id, " PEDE SNAPSHOT ]")
+		dmx.snapshot()
+	}
+}
+
+// ready espera a barreira de partida de cada modulo e so entao inicia os lacos da aplicacao
+func (c *inprocCluster) ready(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for i, p := range c.procs {
+		left := time.Until(deadline)
+		if left <= 0 {
+			left = time.Nanosecond // 0 seria sem limite
+		}
+		if err := p.ready(left); err != nil {
+			return fmt.Errorf("processo %d: %w", i, err)
+		}
+		c.logs.launcher(fmt.Sprintf("processo %d pronto em %s", i, c.addrs[i]))
+	}
+	for i, p := range c.procs {
+		out := c.logs.process(i)
+		c.wg.Add(1)
+		if c.cfg.Benchmark {
+			go c.bench(i, p, out)
+		} else {
+			go c.run(i, p, out)
+		}
+		if i == c.cfg.SnapshotInitiator {
+			go c.snapshots(i, p, out)
+		}
+	}
+	return nil
+}
+
+func (c *inprocCluster) exited() <-chan int {
+	return c.exitedCh
+}
+
+func (c *inprocCluster) stop(timeout time.Duration) {
+	close(c.stopCh)
+	done := make(chan struct{})
+	go func() {
+		c.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		c.logs.launcher("processos nao terminaram apos " + timeout.String())
+	}
+}
+
+func (c *inprocCluster) status() []string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return append([]string(nil), c.states...)
+}
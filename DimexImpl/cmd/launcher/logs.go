@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// logMux junta os logs dos processos numa saida, uma linha inteira por vez, com prefixo
+type logMux struct {
+	mutex   sync.Mutex
+	out     io.Writer
+	level   int
+	writers []*lineWriter
+}
+
+func newLogMux(out io.Writer, level int) *logMux {
+	return &logMux{out: out, level: level}
+}
+
+func (m *logMux) launcher(s string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	fmt.Fprintln(m.out, "[launcher] "+s)
+}
+
+// process devolve o writer das saidas do processo id
+func (m *logMux) process(id int) io.Writer {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	w := &lineWriter{mux: m, prefix: "[p" + strconv.Itoa(id) + "] "}
+	m.writers = append(m.writers, w)
+	return w
+}
+
+// flush escreve as linhas incompletas que sobraram
+func (m *logMux) flush() {
+	m.mutex.Lock()
+	writers := m.writers
+	m.mutex.Unlock()
+	for _, w := range writers {
+		w.flush()
+	}
+}
+
+func (m *logMux) emit(prefix string, line string) {
+	if !m.show(line) {
+		return
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	io.WriteString(m.out, prefix+line+"\n")
+}
+
+// show filtra pelo nivel: 0 so erros, 1 sem o debug dos modulos, 2 tudo
+func (m *logMux) show(line string) bool {
+	switch m.level {
+	case 0:
+		return strings.Contains(line, "rror") || strings.Contains(line, "ERRO") || strings.Contains(line, "panic")
+	case 1:
+		return !strings.HasPrefix(line, ". . . .") && !strings.HasPrefix(line, "&{")
+	}
+	return true
+}
+
+type lineWriter struct {
+	mutex  sync.Mutex
+	mux    *logMux
+	prefix string
+	buf    []byte
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.mux.emit(w.prefix, string(w.buf[:i]))
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+func (w *lineWriter) flush() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if len(w.buf) > 0 {
+		w.mux.emit(w.prefix, string(w.buf))
+		w.buf = nil
+	}
+}
@@ -0,0 +1,292 @@
+// Lanca um cluster de N processos DIMEX (substitui o antigo run.sh).
+// Os processos rodam como processos filhos (binario do useDIMEX-f.go, compilado uma vez)
+// ou todos dentro do launcher (-mode inproc). O launcher:
+//   - monta os enderecos host:basePort+i*portStep (padrao 127.0.0.1:5000, 6001, 7002 ...)
+//   - prefixa e junta os logs ([p0] ...), filtrados pelo nivel de debug
+//   - espera todos escutarem (prontos) antes de contar a duracao; cada processo ainda
+//     espera a barreira de partida (hello de todos) antes do primeiro pedido
+//   - encerra com SIGINT (e kill apos stopTimeout) no fim da duracao ou no ctrl+c
+//   - apaga mxOUT.txt e, com snapshots, os arquivos de snapshot anteriores
+//   - ao sair, verifica o mxOUT.txt, conta os snapshots novos e mostra o status de cada processo
+//   - com -bench, os processos rodam a carga de Bench por -duration e o launcher junta os
+//     resultados (bench_proc_<id>.json): vazao, percentis de latencia, mensagens por entrada e justica
+// Uso:
+//   go run ./cmd/launcher                              3 processos ate ctrl+c
+//   go run ./cmd/launcher -n 5 -duration 30s -debug 1
+//   go run ./cmd/launcher -config launcher-example.json
+//   go run ./cmd/launcher -bench -arrival poisson -rate 200 -hold exp:1ms -skew 1 -duration 20s -snapshot -1
+// Flags passadas explicitamente tem precedencia sobre o arquivo de configuracao.
+
+package main
+
+import (
+	"SD/Bench"
+	"SD/Units"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+type Config struct {
+	N                 int            `json:"n"`
+	Host              string         `json:"host"`
+	BasePort          int            `json:"basePort"`
+	PortStep          int            `json:"portStep"`
+	SnapshotInitiator int            `json:"snapshotInitiator"` // -1: sem snapshots
+	SnapshotInterval  Units.Duration `json:"snapshotInterval"`
+	Debug             int            `json:"debug"`     // 0: erros e resumo; 1: + aplicacao; 2: tudo
+	Mode              string         `json:"mode"`      // "child" ou "inproc"
+	Algorithm         string         `json:"algorithm"` // por enquanto so "ricart-agrawala"
+	Duration          Units.Duration `json:"duration"`  // 0: ate ctrl+c; no benchmark, tempo fazendo pedidos
+	ReadyTimeout      Units.Duration `json:"readyTimeout"`
+	StopTimeout       Units.Duration `json:"stopTimeout"`
+	Dir               string         `json:"dir"`    // diretorio de trabalho dos processos (mxOUT.txt, ../SnapshotAnalysis)
+	Binary            string         `json:"binary"` // modo child: binario ja compilado (vazio: compila useDIMEX-f.go)
+	Benchmark         bool           `json:"benchmark"`
+	Bench             Bench.Workload `json:"bench"` // a duracao vem de Duration
+}
+
+func defaultConfig() Config {
+	return Config{
+		N:                 3,
+		Host:              "127.0.0.1",
+		BasePort:          5000,
+		PortStep:          1001,
+		SnapshotInitiator: 0,
+		SnapshotInterval:  Units.Duration(5 * time.Second),
+		Debug:             1,
+		Mode:              "child",
+		Algorithm:         "ricart-agrawala",
+		ReadyTimeout:      Units.Duration(15 * time.Second),
+		StopTimeout:       Units.Duration(3 * time.Second),
+		Dir:               ".",
+		Bench:             Bench.DefaultWorkload()}
+}
+
+func (cfg Config) validate() error {
+	switch {
+	case cfg.N < 2:
+		return fmt.Errorf("n deve ser pelo menos 2 (%d)", cfg.N)
+	case cfg.BasePort < 1 || cfg.BasePort+(cfg.N-1)*cfg.PortStep > 65535:
+		return fmt.Errorf("portas fora do intervalo: basePort %d, portStep %d, n %d", cfg.BasePort, cfg.PortStep, cfg.N)
+	case cfg.PortStep < 1:
+		return fmt.Errorf("portStep deve ser positivo (%d)", cfg.PortStep)
+	case cfg.SnapshotInitiator < -1 || cfg.SnapshotInitiator >= cfg.N:
+		return fmt.Errorf("snapshotInitiator %d nao e' um processo (use -1 para nenhum)", cfg.SnapshotInitiator)
+	case cfg.SnapshotInitiator >= 0 && cfg.SnapshotInterval <= 0:
+		return fmt.Errorf("snapshotInterval deve ser positivo (%v)", time.Duration(cfg.SnapshotInterval))
+	case cfg.Debug < 0 || cfg.Debug > 2:
+		return fmt.Errorf("debug deve ser 0, 1 ou 2 (%d)", cfg.Debug)
+	case cfg.Mode != "child" && cfg.Mode != "inproc":
+		return fmt.Errorf("mode deve ser child ou inproc (%q)", cfg.Mode)
+	case cfg.Algorithm != "ricart-agrawala":
+		return fmt.Errorf("algorithm deve ser ricart-agrawala (%q)", cfg.Algorithm)
+	case cfg.Benchmark && cfg.SnapshotInitiator >= 0:
+		return fmt.Errorf("snapshots atrasam as entradas e distorcem o benchmark: use -snapshot -1")
+	}
+	if cfg.Benchmark {
+		return cfg.Bench.Validate()
+	}
+	return nil
+}
+
+func (cfg Config) addresses() []string {
+	addrs := make([]string, cfg.N)
+	for i := range addrs {
+		addrs[i] = cfg.Host + ":" + strconv.Itoa(cfg.BasePort+i*cfg.PortStep)
+	}
+	return addrs
+}
+
+// loadConfig aplica, nesta ordem: padroes, arquivo -config e flags passadas
+func loadConfig() (Config, error) {
+	cfg := defaultConfig()
+	fs := flag.NewFlagSet("launcher", flag.ExitOnError)
+	path := fs.String("config", "", "arquivo JSON de configuracao")
+	n := fs.Int("n", cfg.N, "numero de processos")
+	host := fs.String("host", cfg.Host, "host dos processos")
+	basePort := fs.Int("basePort", cfg.BasePort, "porta do processo 0")
+	portStep := fs.Int("portStep", cfg.PortStep, "distancia entre as portas de processos seguidos")
+	snap := fs.Int("snapshot", cfg.SnapshotInitiator, "processo que inicia snapshots periodicos (-1: nenhum)")
+	snapshotInterval := fs.Duration("snapshotInterval", time.Duration(cfg.SnapshotInterval), "intervalo entre snapshots")
+	debug := fs.Int("debug", cfg.Debug, "0: erros e resumo; 1: + logs da aplicacao; 2: tudo")
+	mode := fs.String("mode", cfg.Mode, "child (processos filhos) ou inproc (goroutines no launcher)")
+	algorithm := fs.String("algorithm", cfg.Algorithm, "algoritmo de exclusao mutua (ricart-agrawala)")
+	duration := fs.Duration("duration", 0, "tempo de execucao depois de prontos (0: ate ctrl+c; no benchmark, tempo fazendo pedidos, padrao 10s)")
+	readyTimeout := fs.Duration("readyTimeout", time.Duration(cfg.ReadyTimeout), "espera maxima para todos escutarem e passarem a barreira de partida")
+	stopTimeout := fs.Duration("stopTimeout", time.Duration(cfg.StopTimeout), "espera apos SIGINT antes de matar os filhos")
+	dir := fs.String("dir", cfg.Dir, "diretorio de trabalho dos processos")
+	binary := fs.String("binary", cfg.Binary, "binario do useDIMEX-f ja compilado (modo child)")
+	benchmark := fs.Bool("bench", cfg.Benchmark, "modo benchmark (ver Bench/Workload.go)")
+	arrival := fs.String("arrival", cfg.Bench.Arrival, "benchmark: closed ou poisson")
+	rate := fs.Float64("rate", cfg.Bench.Rate, "benchmark poisson: pedidos por segundo do processo 0")
+	hold, benchThink := cfg.Bench.Hold, cfg.Bench.Think
+	fs.Var(&hold, "hold", "benchmark: tempo na SC (2ms, uniform:1ms,3ms ou exp:2ms)")
+	fs.Var(&benchThink, "benchThink", "benchmark closed: tempo entre sair da SC e pedir de novo")
+	skew := fs.Float64("skew", cfg.Bench.Skew, "benchmark: o processo i gera 1/(i+1)^skew da carga do processo 0")
+	seed := fs.Int64("seed", cfg.Bench.Seed, "benchmark: semente (0: aleatoria)")
+	fs.Parse(os.Args[1:])
+
+	if *path != "" {
+		f, err := os.Open(*path)
+		if err != nil {
+			return cfg, err
+		}
+		// campo desconhecido no arquivo e' erro (ex: nome errado), nao e' ignorado
+		dec := json.NewDecoder(f)
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&cfg)
+		f.Close()
+		if err != nil {
+			return cfg, fmt.Errorf("%s: %w", *path, err)
+		}
+	}
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "n":
+			cfg.N = *n
+		case "host":
+			cfg.Host = *host
+		case "basePort":
+			cfg.BasePort = *basePort
+		case "portStep":
+			cfg.PortStep = *portStep
+		case "snapshot":
+			cfg.SnapshotInitiator = *snap
+		case "snapshotInterval":
+			cfg.SnapshotInterval = Units.Duration(*snapshotInterval)
+		case "debug":
+			cfg.Debug = *debug
+		case "mode":
+			cfg.Mode = *mode
+		case "algorithm":
+			cfg.Algorithm = *algorithm
+		case "duration":
+			cfg.Duration = Units.Duration(*duration)
+		case "readyTimeout":
+			cfg.ReadyTimeout = Units.Duration(*readyTimeout)
+		case "stopTimeout":
+			cfg.StopTimeout = Units.Duration(*stopTimeout)
+		case "dir":
+			cfg.Dir = *dir
+		case "binary":
+			cfg.Binary = *binary
+		case "bench":
+			cfg.Benchmark = *benchmark
+		case "arrival":
+			cfg.Bench.Arrival = *arrival
+		case "rate":
+			cfg.Bench.Rate = *rate
+		case "hold":
+			cfg.Bench.Hold = hold
+		case "benchThink":
+			cfg.Bench.Think = benchThink
+		case "skew":
+			cfg.Bench.Skew = *skew
+		case "seed":
+			cfg.Bench.Seed = *seed
+		}
+	})
+	if cfg.Duration > 0 {
+		cfg.Bench.Duration = cfg.Duration
+	}
+	return cfg, cfg.validate()
+}
+
+// cluster e' o que cada modo sabe fazer
+type cluster interface {
+	start() error
+	ready(timeout time.Duration) error
+	exited() <-chan int // id de um processo que terminou sozinho
+	stop(timeout time.Duration)
+	status() []string                                           // situacao final de cada processo
+	benchResults(timeout time.Duration) ([]Bench.Result, error) // espera o resultado do benchmark de todos
+}
+
+func main() {
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Println("Erro:", err)
+		os.Exit(2)
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	os.Exit(launch(cfg, os.Stdout, signals))
+}
+
+// launch roda o cluster ate o fim da duracao, do benchmark ou de um sinal, mostra o
+// resultado em out e devolve o status de saida (0 ok, 1 falha)
+func launch(cfg Config, out io.Writer, signals <-chan os.Signal) int {
+	logs := newLogMux(out, cfg.Debug)
+	addrs := cfg.addresses()
+	logs.launcher(fmt.Sprintf("%d processos (%s): %v", cfg.N, cfg.Mode, addrs))
+
+	results, err := newResults(cfg)
+	if err != nil {
+		logs.launcher("Erro: " + err.Error())
+		return 1
+	}
+
+	var c cluster
+	if cfg.Mode == "inproc" {
+		c = newInprocCluster(cfg, addrs, logs)
+	} else {
+		c = newChildCluster(cfg, addrs, logs)
+	}
+
+	code := 0
+	if err := c.start(); err != nil {
+		logs.launcher("Erro: " + err.Error())
+		code = 1
+	} else if err := c.ready(time.Duration(cfg.ReadyTimeout)); err != nil {
+		logs.launcher("Erro: " + err.Error())
+		code = 1
+	} else {
+		logs.launcher("todos os processos prontos")
+		var timer <-chan time.Time
+		var benchDone chan struct{}
+		var benchRes []Bench.Result
+		var benchErr error
+		if cfg.Benchmark {
+			logs.launcher("benchmark: " + cfg.Bench.String())
+			benchDone = make(chan struct{})
+			go func() {
+				benchRes, benchErr = c.benchResults(time.Duration(cfg.Bench.Duration) + time.Duration(cfg.ReadyTimeout))
+				close(benchDone)
+			}()
+		} else if cfg.Duration > 0 {
+			timer = time.After(time.Duration(cfg.Duration))
+		}
+		select {
+		case <-timer:
+			logs.launcher("fim da duracao")
+		case <-benchDone:
+			if benchErr != nil {
+				logs.launcher("Erro: " + benchErr.Error())
+				code = 1
+			} else {
+				logs.launcher("fim do benchmark")
+				results.bench = benchRes
+			}
+		case s := <-signals:
+			logs.launcher("recebeu " + s.String())
+		case id := <-c.exited():
+			logs.launcher(fmt.Sprintf("processo %d terminou, encerrando os demais", id))
+			code = 1
+		}
+	}
+	logs.launcher("encerrando")
+	c.stop(time.Duration(cfg.StopTimeout))
+	logs.flush()
+
+	if !results.report(out, c.status()) {
+		code = 1
+	}
+	return code
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"SD/Units"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// freePorts procura n portas seguidas livres em 127.0.0.1 e devolve a primeira
+func freePorts(t *testing.T, n int) int {
+	t.Helper()
+	for try := 0; try < 20; try++ {
+		l, err := net.Listen("tcp4", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		base := l.Addr().(*net.TCPAddr).Port
+		l.Close()
+		free := base+n-1 <= 65535
+		for p := base; free && p < base+n; p++ {
+			l, err := net.Listen("tcp4", fmt.Sprintf("127.0.0.1:%d", p))
+			if err != nil {
+				free = false
+				break
+			}
+			l.Close()
+		}
+		if free {
+			return base
+		}
+	}
+	t.Fatal("sem portas livres seguidas")
+	return 0
+}
+
+// 3 processos no launcher por pouco tempo, com snapshots: exclusao mutua verificada
+// no mxOUT.txt, snapshots contados e todos os processos encerrados pelo launcher
+func TestLaunchInproc(t *testing.T) {
+	root := t.TempDir()
+	cfg := defaultConfig()
+	cfg.Mode = "inproc"
+	cfg.Debug = 0
+	cfg.BasePort = freePorts(t, cfg.N)
+	cfg.PortStep = 1
+	cfg.Duration = Units.Duration(700 * time.Millisecond)
+	cfg.SnapshotInterval = Units.Duration(200 * time.Millisecond)
+	cfg.Dir = filepath.Join(root, "run")
+	if err := os.Mkdir(cfg.Dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	var out strings.Builder
+	start := time.Now()
+	code := launch(cfg, &out, make(chan os.Signal))
+	if code != 0 {
+		t.Fatalf("status %d, esperado 0:\n%s", code, out.String())
+	}
+	if elapsed := time.Since(start); elapsed > time.Duration(cfg.ReadyTimeout)+time.Duration(cfg.Duration)+time.Duration(cfg.StopTimeout) {
+		t.Fatalf("launcher demorou %v para encerrar", elapsed)
+	}
+
+	report := out.String()
+	for i := 0; i < cfg.N; i++ {
+		if !strings.Contains(report, fmt.Sprintf("processo %d: encerrado pelo launcher", i)) {
+			t.Errorf("processo %d nao encerrado pelo launcher:\n%s", i, report)
+		}
+	}
+	if !strings.Contains(report, "sem violacao da exclusao mutua") {
+		t.Errorf("mxOUT.txt nao verificado:\n%s", report)
+	}
+	data, err := os.ReadFile(filepath.Join(cfg.Dir, "mxOUT.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entries, bad := checkMx(data); bad >= 0 || entries == 0 {
+		t.Fatalf("mxOUT.txt com %d entradas, erro na posicao %d: %q", entries, bad, data)
+	}
+	if !strings.Contains(report, "snapshots do processo 0") || strings.Contains(report, "snapshots do processo 0: 0 novos") {
+		t.Errorf("nenhum snapshot contado:\n%s", report)
+	}
+}
+
+func TestCheckMx(t *testing.T) {
+	cases := []struct {
+		data    string
+		entries int
+		bad     int
+	}{
+		{"", 0, -1},
+		{"|.|.|.", 3, -1},
+		{"|.|", 1, -1}, // encerrado dentro da SC
+		{"|.||..", 1, 3},
+		{"|..", 1, 2},
+		{".|", 0, 0},
+	}
+	for _, c := range cases {
+		entries, bad := checkMx([]byte(c.data))
+		if entries != c.entries || bad != c.bad {
+			t.Errorf("%q: %d entradas, erro em %d; esperado %d, %d", c.data, entries, bad, c.entries, c.bad)
+		}
+	}
+}
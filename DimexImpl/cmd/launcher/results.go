@@ -0,0 +1,134 @@
+package main
+
+import (
+	"SD/Bench"
+	"SD/DIMEX"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// results coleta o que os processos deixaram: mxOUT.txt e snapshots
+type results struct {
+	cfg       Config
+	mxPath    string
+	snapPaths []string
+	bench     []Bench.Result
+}
+
+// newResults apaga o mxOUT.txt (como fazia o run.sh), os resultados de benchmark anteriores
+// e, se esta execucao faz snapshots, os arquivos de snapshot anteriores (inclusive os
+// rotacionados): o sink acrescenta aos arquivos e a contagem e a analise incluiriam execucoes anteriores
+func newResults(cfg Config) (*results, error) {
+	r := &results{cfg: cfg, mxPath: filepath.Join(cfg.Dir, "mxOUT.txt")}
+	old := []string{r.mxPath}
+	for i := 0; i < cfg.N; i++ {
+		old = append(old, benchPath(cfg, i))
+	}
+	for i := 0; i < cfg.N; i++ {
+		path := filepath.Join(cfg.Dir, "..", "SnapshotAnalysis", fmt.Sprintf("snapshot_proc_%d.txt", i))
+		r.snapPaths = append(r.snapPaths, path)
+		if cfg.SnapshotInitiator >= 0 {
+			rotated, _ := filepath.Glob(path + ".*")
+			old = append(append(old, path), rotated...)
+		}
+	}
+	for _, path := range old {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+func benchPath(cfg Config, id int) string {
+	return filepath.Join(cfg.Dir, fmt.Sprintf("bench_proc_%d.json", id))
+}
+
+// countSnapshots conta as linhas de snapshot do arquivo, sem os marcadores de execucao
+func countSnapshots(path string) int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	return bytes.Count(data, []byte("\n")) - bytes.Count(data, []byte(DIMEX.RunMarker))
+}
+
+// checkMx verifica se o arquivo e' uma sequencia de "|."; um "|" final sem "." e'
+// aceito (processo encerrado dentro da SC). Devolve entradas e posicao do primeiro erro (-1 se ok)
+func checkMx(data []byte) (entries int, bad int) {
+	for i := 0; i < len(data); i += 2 {
+		if data[i] != '|' {
+			return entries, i
+		}
+		if i+1 == len(data) {
+			return entries, -1
+		}
+		if data[i+1] != '.' {
+			return entries, i + 1
+		}
+		entries++
+	}
+	return entries, -1
+}
+
+// report mostra o resultado; false se a exclusao mutua falhou ou algum processo terminou sozinho
+func (r *results) report(w io.Writer, states []string) bool {
+	ok := true
+	fmt.Fprintln(w, "\n=== resultado ===")
+	for i, s := range states {
+		if s == "" {
+			s = "nao iniciado"
+		}
+		if !strings.HasPrefix(s, "encerrado") {
+			ok = false
+		}
+		fmt.Fprintf(w, "processo %d: %s\n", i, s)
+	}
+
+	data, err := os.ReadFile(r.mxPath)
+	switch {
+	case err != nil:
+		fmt.Fprintln(w, "mxOUT.txt:", err)
+		ok = false
+	default:
+		entries, bad := checkMx(data)
+		if bad >= 0 {
+			ok = false
+			end := bad + 10
+			if end > len(data) {
+				end = len(data)
+			}
+			fmt.Fprintf(w, "mxOUT.txt: ERRO na posicao %d (%q) apos %d entradas na SC\n", bad, data[bad:end], entries)
+		} else {
+			fmt.Fprintf(w, "mxOUT.txt: %d entradas na SC, sem violacao da exclusao mutua\n", entries)
+		}
+	}
+
+	if r.cfg.SnapshotInitiator >= 0 {
+		for i, path := range r.snapPaths {
+			n := countSnapshots(path)
+			rotated, _ := filepath.Glob(path + ".*")
+			for _, p := range rotated {
+				n += countSnapshots(p)
+			}
+			fmt.Fprintf(w, "snapshots do processo %d: %d novos em %s\n", i, n, path)
+		}
+		fmt.Fprintln(w, "analise: cd ../SnapshotAnalysis && go run .")
+	}
+
+	if r.cfg.Benchmark {
+		fmt.Fprintln(w, "\n=== benchmark ===")
+		if len(r.bench) == 0 {
+			fmt.Fprintln(w, "sem resultados (benchmark interrompido)")
+			ok = false
+		} else {
+			fmt.Fprintln(w, r.cfg.Bench.String())
+			Bench.Report(w, r.bench)
+		}
+	}
+	return ok
+}
@@ -0,0 +1,159 @@
+// Reproduz offline o trace gravado por um processo DIMEX (DIMEX_TRACE_DIR).
+// O modulo DIMEX roda sobre um PP2PLink em memoria e recebe as suas entradas na ordem
+// em que foram tratadas no processo gravado: pedidos da aplicacao (APP) e mensagens
+// recebidas (DLVR). Cada entrada e' tratada por inteiro antes da seguinte, sem esperas
+// por tempo, entao o mesmo trace da sempre o mesmo resultado. A sequencia de envios do
+// modulo e' comparada com a dos SEND gravados.
+// Uso:
+//   go run ./cmd/replay -id 1 -trace traces/trace_proc_1.txt 127.0.0.1:5000 127.0.0.1:6001 127.0.0.1:7002
+
+package main
+
+import (
+	"SD/DIMEX"
+	PP2PLink "SD/PP2PLink"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+func main() {
+	id := flag.Int("id", 0, "id do processo que gravou o trace")
+	tracePath := flag.String("trace", "", "arquivo trace_proc_<id>.txt")
+	dbg := flag.Bool("dbg", false, "liga debug do DIMEX")
+	flag.Parse()
+	addresses := flag.Args()
+
+	if *tracePath == "" || len(addresses) == 0 || *id < 0 || *id >= len(addresses) {
+		fmt.Println("uso: go run ./cmd/replay -id <id> -trace <arquivo> <enderecos de todos processos...>")
+		os.Exit(2)
+	}
+
+	f, err := os.Open(*tracePath)
+	if err != nil {
+		fmt.Println("Erro abrindo o trace:", err)
+		os.Exit(2)
+	}
+	events, err := PP2PLink.ReadTrace(f)
+	f.Close()
+	if err != nil {
+		fmt.Println("Erro lendo o trace:", err)
+		os.Exit(2)
+	}
+
+	sink := DIMEX.NewMemorySnapshotSink()
+	divergences, err := replay(events, addresses, *id, *dbg, sink, os.Stdout)
+	if err != nil {
+		fmt.Println("Erro:", err)
+		os.Exit(2)
+	}
+
+	for _, rec := range sink.Records() {
+		fmt.Print("snapshot: ", rec.Data)
+	}
+	fmt.Printf("%d eventos reproduzidos, %d divergencias\n", len(events), divergences)
+	if divergences > 0 {
+		os.Exit(1)
+	}
+}
+
+// replay entrega ao modulo os eventos APP e DLVR na ordem gravada e devolve quantos
+// envios do modulo diferem dos SEND gravados (cada divergencia e' descrita em out)
+func replay(events []PP2PLink.PP2PLink_Trace_Event, addresses []string, id int, dbg bool, sink DIMEX.SnapshotSink, out io.Writer) (int, error) {
+	var expected []PP2PLink.PP2PLink_Trace_Event
+	inputs := 0
+	for _, ev := range events {
+		switch {
+		case ev.IsSend():
+			expected = append(expected, ev)
+		case ev.IsApp(), ev.IsDeliver():
+			inputs++
+		}
+	}
+	if inputs == 0 && len(expected) > 0 {
+		return 0, errors.New("trace sem eventos APP e DLVR (gravado por uma versao antiga do DIMEX?)")
+	}
+
+	link := PP2PLink.NewMemPP2PLink(addresses[id], dbg)
+	sends := newCollector(link)
+	defer sends.stop()
+	dmx := DIMEX.NewDIMEXReplay(addresses, id, dbg, sink, link)
+
+	for i, ev := range events {
+		switch {
+		case ev.IsApp():
+			req, err := DIMEX.ParseRequest(ev.Message)
+			if err != nil {
+				return 0, fmt.Errorf("evento %d: %w", i+1, err)
+			}
+			dmx.Request(req)
+		case ev.IsDeliver():
+			dmx.Deliver(PP2PLink.PP2PLink_Ind_Message{From: ev.Peer, Message: ev.Message})
+		default:
+			continue
+		}
+		// a aplicacao gravada ja sabe que entrou: o pedido seguinte dela esta no trace
+		select {
+		case <-dmx.Ind:
+		default:
+		}
+	}
+
+	produced := sends.collected()
+	divergences := 0
+	for i := 0; i < len(expected) || i < len(produced); i++ {
+		switch {
+		case i >= len(produced):
+			fmt.Fprintf(out, "envio %d: esperava %q para %s, modulo nao enviou nada\n", i+1, expected[i].Message, expected[i].Peer)
+		case i >= len(expected):
+			fmt.Fprintf(out, "envio %d: envio extra do modulo: %q para %s\n", i+1, produced[i].Message, produced[i].To)
+		case produced[i].To != expected[i].Peer || produced[i].Message != expected[i].Message:
+			fmt.Fprintf(out, "envio %d: esperava %q para %s, modulo enviou %q para %s\n",
+				i+1, expected[i].Message, expected[i].Peer, produced[i].Message, produced[i].To)
+		default:
+			continue
+		}
+		divergences++
+	}
+	return divergences, nil
+}
+
+// collector faz o papel da rede do link em memoria: guarda os envios do modulo na ordem
+type collector struct {
+	sync chan chan []PP2PLink.PP2PLink_Req_Message
+	quit chan struct{}
+}
+
+func newCollector(link *PP2PLink.PP2PLink) *collector {
+	c := &collector{sync: make(chan chan []PP2PLink.PP2PLink_Req_Message), quit: make(chan struct{})}
+	go func() {
+		var sent []PP2PLink.PP2PLink_Req_Message
+		for {
+			select {
+			case msg := <-link.Req:
+				sent = append(sent, msg)
+			case batch := <-link.ReqBatch:
+				sent = append(sent, batch...)
+			case reply := <-c.sync:
+				reply <- sent
+			case <-c.quit:
+				return
+			}
+		}
+	}()
+	return c
+}
+
+// collected devolve os envios ate agora. Os canais do link nao tem buffer: todo envio
+// que ja voltou no modulo foi recebido pela rotina, que so atende o pedido depois de guarda-lo
+func (c *collector) collected() []PP2PLink.PP2PLink_Req_Message {
+	reply := make(chan []PP2PLink.PP2PLink_Req_Message)
+	c.sync <- reply
+	return <-reply
+}
+
+func (c *collector) stop() {
+	close(c.quit)
+}
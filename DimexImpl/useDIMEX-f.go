@@ -1,15 +1,20 @@
 // Construido como parte da disciplina: Sistemas Distribuidos - PUCRS - Escola Politecnica
 //  Professor: Fernando Dotti  (https://fldotti.github.io/)
 // Uso p exemplo:
-//   go run usaDIMEX.go 0 127.0.0.1:5000  127.0.0.1:6001  127.0.0.1:7002 ")
-//   go run usaDIMEX.go 1 127.0.0.1:5000  127.0.0.1:6001  127.0.0.1:7002 ")
-//   go run usaDIMEX.go 2 127.0.0.1:5000  127.0.0.1:6001  127.0.0.1:7002 ")
+//   go run useDIMEX-f.go -id 0 -peers 127.0.0.1:5000,127.0.0.1:6001,127.0.0.1:7002 -snapshot
+//   go run useDIMEX-f.go -id 1 -peers 127.0.0.1:5000,127.0.0.1:6001,127.0.0.1:7002
+//   go run useDIMEX-f.go -id 2 -peers 127.0.0.1:5000,127.0.0.1:6001,127.0.0.1:7002
+// ou com arquivo de configuracao JSON (ver dimex-example.json), flags tem precedencia:
+//   go run useDIMEX-f.go -config dimex-example.json -id 1
+// A forma antiga continua aceita:  go run useDIMEX-f.go 0 127.0.0.1:5000 127.0.0.1:6001 127.0.0.1:7002 --s
+// "go run useDIMEX-f.go -h" lista todas as opcoes.
 // ----------
 // LANCAR N PROCESSOS EM SHELL's DIFERENTES, UMA PARA CADA PROCESSO.
 // para cada processo fornecer: seu id único (0, 1, 2 ...) e a mesma lista de processos.
 // o endereco de cada processo é o dado na lista, na posicao do seu id.
 // no exemplo acima o processo com id=1  usa a porta 6001 para receber e as portas
 // 5000 e 7002 para mandar mensagens respectivamente para processos com id=0 e 2
+// Ou lancar todos de uma vez com:  go run ./cmd/launcher  (ver cmd/launcher/main.go)
 // -----------
 // Esta versão supõe que todos processos tem acesso a um mesmo arquivo chamado "mxOUT.txt"
 // Todos processos escrevem neste arquivo, usando o protocolo dimex para exclusao mutua.
@@ -25,89 +30,511 @@
 // "|."  dos processos de diversas diferentes formas.
 // Ou seja, o padrão correto acima é garantido pelo dimex.
 // Ainda assim, isto é apenas um teste.  E testes são frágeis em sistemas distribuídos.
+// -----------
+// Opcoes de comunicacao: -trace <dir> grava todas as mensagens enviadas e recebidas em
+// <dir>/trace_proc_<id>.txt (ver cmd/replay); -tls <dir> usa TLS mutuo com os certificados
+// de cmd/dimexca; -authKey <arquivo> autentica os quadros com HMAC; -faults <arquivo.json>
+// passa as mensagens pela camada de injecao de falhas (ver PP2PLink/Faults.go).
+// Os padroes dessas quatro vem das variaveis DIMEX_TRACE_DIR, DIMEX_TLS_DIR, DIMEX_AUTH_KEY e DIMEX_FAULTS.
+// -----------
+// Modo benchmark (-bench, ver Bench/Workload.go): no lugar do laco acima, pedidos em laco fechado
+// ou com chegadas de Poisson (-arrival, -rate), tempo na SC com distribuicao (-hold), skew entre
+// processos (-skew) e duracao (-duration). No fim grava bench_proc_<id>.json com vazao, latencias
+// de aquisicao e mensagens enviadas; "go run ./cmd/launcher -bench" junta os de todos os processos.
+// Nao aceita -snapshot. ctrl+c durante o benchmark grava o resultado parcial (aborted) e termina.
+//   go run useDIMEX-f.go -id 0 -peers ... -bench -arrival poisson -rate 200 -hold exp:1ms -duration 20s
 
 package main
 
 import (
+	"SD/Bench"
 	"SD/DIMEX"
+	PP2PLink "SD/PP2PLink"
+	"SD/Units"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"net"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"syscall"
 	"time"
 )
 
-func startSnapshot(dmx *DIMEX.DIMEX_Module, id int) {
+// Workload e' o formato do laco da aplicacao
+type Workload struct {
+	CS    Units.Duration `json:"cs"`    // tempo dentro da SC
+	Think Units.Duration `json:"think"` // tempo fora da SC entre uma saida e o proximo pedido
+	Count int            `json:"count"` // entradas na SC antes de parar de pedir (0: sem fim)
+}
+
+type Config struct {
+	Id               int            `json:"id"`
+	Peers            []string       `json:"peers"`     // enderecos de todos, na mesma ordem em todos os processos
+	Algorithm        string         `json:"algorithm"` // por enquanto so "ricart-agrawala" (DIMEX)
+	Snapshot         bool           `json:"snapshot"`  // este processo inicia snapshots periodicos
+	SnapshotInterval Units.Duration `json:"snapshotInterval"`
+	SnapshotDir      string         `json:"snapshotDir"`
+	Output           string         `json:"output"`       // arquivo compartilhado escrito dentro da SC
+	Debug            int            `json:"debug"`        // 0: so erros; 1: + aplicacao; 2: + DIMEX e PP2PLink
+	ReadyTimeout     Units.Duration `json:"readyTimeout"` // espera maxima pelos outros processos antes do primeiro pedido
+	Workload         Workload       `json:"workload"`
+	TraceDir         string         `json:"traceDir"`
+	TLSDir           string         `json:"tlsDir"`
+	AuthKey          string         `json:"authKey"`
+	Faults           string         `json:"faults"`
+	Benchmark        bool           `json:"benchmark"` // mede a exclusao mutua com a carga de Bench no lugar do laco de Workload
+	Bench            Bench.Workload `json:"bench"`
+	BenchOut         string         `json:"benchOut"` // resultado em JSON (vazio: bench_proc_<id>.json)
+}
+
+func defaultConfig() Config {
+	return Config{
+		Id:               -1,
+		Algorithm:        "ricart-agrawala",
+		SnapshotInterval: Units.Duration(5 * time.Second),
+		SnapshotDir:      "../SnapshotAnalysis",
+		Output:           "mxOUT.txt",
+		Debug:            1,
+		ReadyTimeout:     Units.Duration(30 * time.Second),
+		TraceDir:         os.Getenv("DIMEX_TRACE_DIR"),
+		TLSDir:           os.Getenv("DIMEX_TLS_DIR"),
+		AuthKey:          os.Getenv("DIMEX_AUTH_KEY"),
+		Faults:           os.Getenv("DIMEX_FAULTS"),
+		Bench:            Bench.DefaultWorkload()}
+}
+
+// loadConfig aplica, nesta ordem: padroes, arquivo -config e flags passadas (ou a forma antiga posicional)
+func loadConfig(args []string) (Config, error) {
+	cfg := defaultConfig()
+	fs := flag.NewFlagSet("useDIMEX-f", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "uso: go run useDIMEX-f.go -id <id> -peers <end0,end1,...> [opcoes]")
+		fs.PrintDefaults()
+	}
+	path := fs.String("config", "", "arquivo JSON de configuracao")
+	id := fs.Int("id", cfg.Id, "id deste processo (indice em -peers)")
+	peers := fs.String("peers", "", "enderecos de todos os processos, separados por virgula")
+	algorithm := fs.String("algorithm", cfg.Algorithm, "algoritmo de exclusao mutua (ricart-agrawala)")
+	snapshot := fs.Bool("snapshot", cfg.Snapshot, "este processo inicia snapshots periodicos")
+	snapshotInterval := fs.Duration("snapshotInterval", time.Duration(cfg.SnapshotInterval), "intervalo entre snapshots")
+	snapshotDir := fs.String("snapshotDir", cfg.SnapshotDir, "diretorio dos arquivos de snapshot")
+	output := fs.String("output", cfg.Output, "arquivo compartilhado escrito dentro da SC")
+	debug := fs.Int("debug", cfg.Debug, "0: so erros; 1: + aplicacao; 2: + DIMEX e PP2PLink")
+	readyTimeout := fs.Duration("readyTimeout", time.Duration(cfg.ReadyTimeout), "espera maxima por todos os processos antes do primeiro pedido (0: sem limite)")
+	cs := fs.Duration("cs", 0, "tempo dentro da SC")
+	think := fs.Duration("think", 0, "tempo entre sair da SC e pedir de novo")
+	count := fs.Int("count", 0, "entradas na SC antes de parar de pedir; o processo segue respondendo aos outros (0: sem fim)")
+	traceDir := fs.String("trace", cfg.TraceDir, "diretorio do trace do PP2PLink (DIMEX_TRACE_DIR)")
+	tlsDir := fs.String("tls", cfg.TLSDir, "diretorio dos certificados TLS (DIMEX_TLS_DIR)")
+	authKey := fs.String("authKey", cfg.AuthKey, "arquivo com a chave HMAC do cluster (DIMEX_AUTH_KEY)")
+	faults := fs.String("faults", cfg.Faults, "arquivo JSON de injecao de falhas (DIMEX_FAULTS)")
+	benchmark := fs.Bool("bench", cfg.Benchmark, "modo benchmark: carga de -arrival/-hold/... e relatorio no fim")
+	arrival := fs.String("arrival", cfg.Bench.Arrival, "benchmark: closed (laco fechado) ou poisson (laco aberto)")
+	rate := fs.Float64("rate", cfg.Bench.Rate, "benchmark poisson: pedidos por segundo do processo 0")
+	hold, benchThink := cfg.Bench.Hold, cfg.Bench.Think
+	fs.Var(&hold, "hold", "benchmark: tempo na SC (2ms, uniform:1ms,3ms ou exp:2ms)")
+	fs.Var(&benchThink, "benchThink", "benchmark closed: tempo entre sair da SC e pedir de novo (mesmo formato de -hold)")
+	skew := fs.Float64("skew", cfg.Bench.Skew, "benchmark: o processo i gera 1/(i+1)^skew da carga do processo 0")
+	duration := fs.Duration("duration", time.Duration(cfg.Bench.Duration), "benchmark: tempo fazendo pedidos")
+	seed := fs.Int64("seed", cfg.Bench.Seed, "benchmark: semente (0: aleatoria)")
+	benchOut := fs.String("benchOut", cfg.BenchOut, "benchmark: arquivo do resultado (padrao bench_proc_<id>.json)")
+	fs.Parse(args)
+
+	if *path != "" {
+		f, err := os.Open(*path)
+		if err != nil {
+			return cfg, err
+		}
+		// campo desconhecido no arquivo e' erro (ex: nome errado), nao e' ignorado
+		dec := json.NewDecoder(f)
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&cfg)
+		f.Close()
+		if err != nil {
+			return cfg, fmt.Errorf("%s: %w", *path, err)
+		}
+	}
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "id":
+			cfg.Id = *id
+		case "peers":
+			cfg.Peers = splitPeers(*peers)
+		case "algorithm":
+			cfg.Algorithm = *algorithm
+		case "snapshot":
+			cfg.Snapshot = *snapshot
+		case "snapshotInterval":
+			cfg.SnapshotInterval = Units.Duration(*snapshotInterval)
+		case "snapshotDir":
+			cfg.SnapshotDir = *snapshotDir
+		case "output":
+			cfg.Output = *output
+		case "debug":
+			cfg.Debug = *debug
+		case "readyTimeout":
+			cfg.ReadyTimeout = Units.Duration(*readyTimeout)
+		case "cs":
+			cfg.Workload.CS = Units.Duration(*cs)
+		case "think":
+			cfg.Workload.Think = Units.Duration(*think)
+		case "count":
+			cfg.Workload.Count = *count
+		case "trace":
+			cfg.TraceDir = *traceDir
+		case "tls":
+			cfg.TLSDir = *tlsDir
+		case "authKey":
+			cfg.AuthKey = *authKey
+		case "faults":
+			cfg.Faults = *faults
+		case "bench":
+			cfg.Benchmark = *benchmark
+		case "arrival":
+			cfg.Bench.Arrival = *arrival
+		case "rate":
+			cfg.Bench.Rate = *rate
+		case "hold":
+			cfg.Bench.Hold = hold
+		case "benchThink":
+			cfg.Bench.Think = benchThink
+		case "skew":
+			cfg.Bench.Skew = *skew
+		case "duration":
+			cfg.Bench.Duration = Units.Duration(*duration)
+		case "seed":
+			cfg.Bench.Seed = *seed
+		case "benchOut":
+			cfg.BenchOut = *benchOut
+		}
+	})
+
+	// forma antiga: <id> <enderecos...> [--s]
+	if rest := fs.Args(); len(rest) > 0 {
+		if len(cfg.Peers) > 0 {
+			return cfg, fmt.Errorf("argumentos sobrando: %v (use -peers ou a forma antiga, nao os dois)", rest)
+		}
+		if rest[len(rest)-1] == "--s" {
+			cfg.Snapshot = true
+			rest = rest[:len(rest)-1]
+		}
+		v, err := strconv.Atoi(rest[0])
+		if err != nil {
+			return cfg, fmt.Errorf("forma antiga: o primeiro argumento deve ser o id, nao %q", rest[0])
+		}
+		cfg.Id, cfg.Peers = v, rest[1:]
+	}
+	return cfg, cfg.validate()
+}
+
+func splitPeers(s string) []string {
+	var peers []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			peers = append(peers, p)
+		}
+	}
+	return peers
+}
+
+// validate lista todos os problemas de uma vez
+func (cfg Config) validate() error {
+	var problems []string
+	add := func(format string, a ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, a...))
+	}
+	if len(cfg.Peers) == 0 {
+		add("informe os enderecos de todos os processos com -peers (ou \"peers\" no arquivo) e o id deste com -id")
+	} else if len(cfg.Peers) == 1 {
+		add("sao necessarios pelo menos 2 processos em peers")
+	}
+	if len(cfg.Peers) > 0 && (cfg.Id < 0 || cfg.Id >= len(cfg.Peers)) {
+		add("id %d fora da lista de %d enderecos (informe -id entre 0 e %d)", cfg.Id, len(cfg.Peers), len(cfg.Peers)-1)
+	}
+	seen := make(map[string]bool)
+	for _, p := range cfg.Peers {
+		if network, address, err := PP2PLink.ParseAddress(p, false); err != nil {
+			add("%v", err)
+		} else if _, _, err := net.SplitHostPort(address); network != "unix" && err != nil {
+			add("endereco %q: esperado host:porta", p)
+		}
+		if seen[p] {
+			add("endereco %s repetido em peers", p)
+		}
+		seen[p] = true
+	}
+	switch cfg.Algorithm {
+	case "ricart-agrawala":
+	default:
+		add("algoritmo %q desconhecido (ricart-agrawala)", cfg.Algorithm)
+	}
+	if cfg.Snapshot && cfg.SnapshotInterval <= 0 {
+		add("snapshotInterval deve ser positivo (%v)", time.Duration(cfg.SnapshotInterval))
+	}
+	if cfg.Output == "" {
+		add("output nao pode ser vazio")
+	}
+	if cfg.Debug < 0 || cfg.Debug > 2 {
+		add("debug deve ser 0, 1 ou 2 (%d)", cfg.Debug)
+	}
+	if cfg.ReadyTimeout < 0 || cfg.Workload.CS < 0 || cfg.Workload.Think < 0 {
+		add("readyTimeout, cs e think nao podem ser negativos")
+	}
+	if cfg.Workload.Count < 0 {
+		add("count nao pode ser negativo (%d)", cfg.Workload.Count)
+	}
+	if cfg.Benchmark {
+		if err := cfg.Bench.Validate(); err != nil {
+			add("%v", err)
+		}
+		if cfg.Workload.Count > 0 || cfg.Workload.CS > 0 || cfg.Workload.Think > 0 {
+			add("cs, think e count sao do laco de demonstracao: no benchmark use -hold, -benchThink e -duration")
+		}
+		if cfg.Snapshot {
+			add("snapshots atrasam as entradas e distorcem o benchmark: retire -snapshot")
+		}
+	}
+	if len(problems) > 0 {
+		return errors.New("configuracao invalida:\n  - " + strings.Join(problems, "\n  - "))
+	}
+	return nil
+}
+
+func startSnapshot(dmx *DIMEX.DIMEX_Module, id int, interval time.Duration, logf func(...interface{})) {
 	for {
-		time.Sleep(3 * time.Second)
-		fmt.Println("[ APP id: ", id, " PEDE SNAPSHOT ]")
+		time.Sleep(interval)
+		logf("[ APP id: ", id, " PEDE SNAPSHOT ]")
 		dmx.Req <- DIMEX.SNAPSHOT
-		time.Sleep(2 * time.Second)
 	}
 }
 
+// waitInd espera a entrada na SC; false se stop fechou antes (stop nil: sem desistencia)
+func waitInd[T any](ind <-chan T, stop <-chan struct{}) bool {
+	select {
+	case <-ind:
+		return true
+	case <-stop:
+		return false
+	}
+}
+
+// runBenchmark roda a carga de cfg.Bench (marcando "|." no arquivo como o laco normal)
+// e grava o resultado para o launcher (ou outra ferramenta) juntar com o dos outros processos.
+// ctrl+c ou SIGTERM (ex: launcher encerrando) durante o benchmark desiste do pedido pendente:
+// o resultado parcial e' gravado com aborted e runBenchmark devolve erro
+func runBenchmark(cfg Config, file *os.File, enter func(stop <-chan struct{}) bool, exit func(), sent func() uint64) error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	stop, done := make(chan struct{}), make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-signals:
+			close(stop)
+		case <-done:
+		}
+	}()
+
+	var writeErr error
+	mark := func(s string) {
+		if _, err := file.WriteString(s); err != nil && writeErr == nil {
+			writeErr = err
+		}
+	}
+	mx := Bench.Mutex{
+		Enter: func() bool {
+			if !enter(stop) {
+				return false
+			}
+			mark("|")
+			return true
+		},
+		Exit: func() { mark("."); exit() },
+		Sent: sent}
+	fmt.Println("[ APP id: ", cfg.Id, " BENCHMARK ", cfg.Bench, " ]")
+	res := Bench.Run(cfg.Bench, cfg.Id, mx)
+
+	path := cfg.BenchOut
+	if path == "" {
+		path = fmt.Sprintf("bench_proc_%d.json", cfg.Id)
+	}
+	if err := res.WriteFile(path); err != nil {
+		return fmt.Errorf("gravando resultado do benchmark: %w", err)
+	}
+	Bench.Report(os.Stdout, []Bench.Result{res})
+	fmt.Println("[ APP id: ", cfg.Id, " FIM DO BENCHMARK, resultado em ", path, " ]")
+	if writeErr != nil {
+		return fmt.Errorf("gravando em %s: %w", cfg.Output, writeErr)
+	}
+	if res.Aborted {
+		return fmt.Errorf("benchmark interrompido, resultado parcial em %s", path)
+	}
+	return nil
+}
+
+// newLink cria o PP2PLink com as opcoes de trace, TLS, HMAC e falhas.
+// sent conta as mensagens enviadas pelo modulo de cima (Metrics().Sent).
+func newLink(cfg Config, dbg bool) (*PP2PLink.PP2PLink, func() uint64, func(), error) {
+	var opts PP2PLink.PP2PLink_Options
+	closeTrace := func() {}
+	if cfg.TraceDir != "" {
+		traceFile, err := os.Create(filepath.Join(cfg.TraceDir, fmt.Sprintf("trace_proc_%d.txt", cfg.Id)))
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("abrindo arquivo de trace: %w", err)
+		}
+		closeTrace = func() { traceFile.Close() }
+		opts.Trace = traceFile
+	}
+	// TLS opcional: certificados gerados com "go run ./cmd/dimexca"
+	if cfg.TLSDir != "" {
+		tlsConfig, err := PP2PLink.LoadProcessTLSConfig(cfg.TLSDir, cfg.Id)
+		if err != nil {
+			return nil, nil, closeTrace, fmt.Errorf("carregando certificados TLS: %w", err)
+		}
+		opts.TLS = tlsConfig
+		opts.Peers = cfg.Peers
+	}
+	// HMAC opcional dos quadros: arquivo com a chave compartilhada do cluster
+	if cfg.AuthKey != "" {
+		key, err := PP2PLink.LoadAuthKey(cfg.AuthKey)
+		if err != nil {
+			return nil, nil, closeTrace, fmt.Errorf("carregando chave de autenticacao: %w", err)
+		}
+		opts.AuthKey = key
+	}
+	p2p, err := PP2PLink.NewPP2PLinkWithOptions(cfg.Peers[cfg.Id], dbg, opts)
+	if err != nil {
+		return nil, nil, closeTrace, fmt.Errorf("iniciando PP2PLink: %w", err)
+	}
+	sent := func() uint64 { return p2p.Metrics().Sent }
+	// erros do link (ex: accept, handshake TLS) apenas sao reportados
+	go func() {
+		for err := range p2p.Err {
+			fmt.Println("[ APP id: ", cfg.Id, " ERRO PP2PLink: ", err, " ]")
+		}
+	}()
+	// injecao de falhas opcional
+	if cfg.Faults != "" {
+		faults, err := PP2PLink.LoadFaultConfig(cfg.Faults)
+		if err != nil {
+			return nil, nil, closeTrace, fmt.Errorf("carregando configuracao de falhas: %w", err)
+		}
+		PP2PLink.NewFaultyPP2PLink(p2p, faults)
+	}
+	return p2p, sent, closeTrace, nil
+}
+
 func main() {
+	cfg, err := loadConfig(os.Args[1:])
+	if err != nil {
+		fmt.Println("Erro:", err)
+		fmt.Println("go run useDIMEX-f.go -h  lista as opcoes")
+		os.Exit(2)
+	}
+	if err := run(cfg); err != nil {
+		fmt.Println("Erro:", err)
+		os.Exit(1)
+	}
+}
 
-	if len(os.Args) < 2 {
-		fmt.Println("Please specify at least one address:port!")
-		// --s -> flag opcional para snapshot
-		fmt.Println("go run useDIMEX-f.go 0 127.0.0.1:5000  127.0.0.1:6001  127.0.0.1:7002 --s")
-		fmt.Println("go run useDIMEX-f.go 1 127.0.0.1:5000  127.0.0.1:6001  127.0.0.1:7002")
-		fmt.Println("go run useDIMEX-f.go 2 127.0.0.1:5000  127.0.0.1:6001  127.0.0.1:7002")
-		return
+// run e' o processo da aplicacao; so volta em caso de erro (no fim segue respondendo ate ctrl+c)
+func run(cfg Config) error {
+	id := cfg.Id
+	dbg := cfg.Debug >= 2
+	logf := func(a ...interface{}) {
+		if cfg.Debug >= 1 {
+			fmt.Println(a...)
+		}
 	}
 
-	id, _ := strconv.Atoi(os.Args[1])
+	p2p, sent, closeTrace, err := newLink(cfg, dbg)
+	if closeTrace != nil {
+		defer closeTrace()
+	}
+	if err != nil {
+		return err
+	}
 
-	addresses := os.Args[2:]
-	if os.Args[len(os.Args)-1] == "--s" {
-		addresses = os.Args[2 : len(os.Args)-1] // retira flag
+	// snapshots gravados em cfg.SnapshotDir, rotacionando a cada 1MB (mantem 5 arquivos)
+	sink := DIMEX.NewFileSnapshotSink(cfg.SnapshotDir, 1<<20, 5)
+	dmx := DIMEX.NewDIMEXWithLink(cfg.Peers, id, dbg, sink, p2p)
+	if dbg {
+		fmt.Println(dmx)
+	}
+	// o laco da aplicacao so depende de entrar (false se stop fechou antes da entrada na SC) e sair
+	enter := func(stop <-chan struct{}) bool { dmx.Req <- DIMEX.ENTER; return waitInd(dmx.Ind, stop) }
+	exit := func() { dmx.Req <- DIMEX.EXIT }
+	waitReady := dmx.WaitReady
+	errs := dmx.Err
+	// opcao para iniciar snapshot periodico (depois que todos estao prontos)
+	if cfg.Snapshot {
+		go func() {
+			<-dmx.Ready
+			startSnapshot(dmx, id, time.Duration(cfg.SnapshotInterval), logf)
+		}()
 	}
-	// fmt.Print("id: ", id, "   ") fmt.Println(addresses)
 
-	var dmx *DIMEX.DIMEX_Module = DIMEX.NewDIMEX(addresses, id, true)
-	fmt.Println(dmx)
+	// erros do modulo (ex: falha ao gravar snapshot) apenas sao reportados
+	go func() {
+		for err := range errs {
+			fmt.Println("[ APP id: ", id, " ERRO DIMEX: ", err, " ]")
+		}
+	}()
 
 	// abre arquivo que TODOS processos devem poder usar
-	file, err := os.OpenFile("./mxOUT.txt", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	file, err := os.OpenFile(cfg.Output, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
-		fmt.Println("Error opening file:", err)
-		return
+		return fmt.Errorf("abrindo %s: %w", cfg.Output, err)
 	}
 	defer file.Close() // Ensure the file is closed at the end of the function
 
-	// espera para facilitar inicializacao de todos processos (a mao)
-	time.Sleep(7 * time.Second)
-
-	// opcao para iniciar snapshot periodico
-	if len(os.Args) > 3 && os.Args[len(os.Args)-1] == "--s" {
-		go startSnapshot(dmx, id)
+	// barreira de partida: espera todos os processos estarem no ar (lancados a mao, em qualquer ordem)
+	logf("[ APP id: ", id, " ESPERA OS OUTROS PROCESSOS ]")
+	if err := waitReady(time.Duration(cfg.ReadyTimeout)); err != nil {
+		return err
 	}
+	logf("[ APP id: ", id, " PRONTO ]")
 
-	for {
-		// SOLICITA ACESSO AO DIMEX
-		fmt.Println("[ APP id: ", id, " PEDE   MX ]")
-		dmx.Req <- DIMEX.ENTER
+	if cfg.Benchmark {
+		if err := runBenchmark(cfg, file, enter, exit, sent); err != nil {
+			return err
+		}
+		// como no -count: os outros ainda podem precisar das respostas deste processo
+		select {}
+	}
 
-		// ESPERA LIBERACAO DO MODULO DIMEX
-		<-dmx.Ind //
+	for n := 0; cfg.Workload.Count == 0 || n < cfg.Workload.Count; n++ {
+		// SOLICITA ACESSO AO DIMEX E ESPERA LIBERACAO
+		logf("[ APP id: ", id, " PEDE   MX ]")
+		enter(nil)
 
 		// A PARTIR DAQUI ESTA ACESSANDO O ARQUIVO SOZINHO
 		_, err = file.WriteString("|") // marca entrada no arquivo
 		if err != nil {
-			fmt.Println("Error writing to file:", err)
-			return
+			return fmt.Errorf("gravando em %s: %w", cfg.Output, err)
 		}
 
-		fmt.Println("[ APP id: ", id, " *EM*   MX ]")
+		logf("[ APP id: ", id, " *EM*   MX ]")
+		time.Sleep(time.Duration(cfg.Workload.CS))
 
 		_, err = file.WriteString(".") // marca saida no arquivo
 		if err != nil {
-			fmt.Println("Error writing to file:", err)
-			return
+			return fmt.Errorf("gravando em %s: %w", cfg.Output, err)
 		}
 
 		// AGORA VAI LIBERAR O ARQUIVO PARA OUTROS
-		dmx.Req <- DIMEX.EXIT //
-		fmt.Println("[ APP id: ", id, " FORA   MX ]")
+		exit()
+		logf("[ APP id: ", id, " FORA   MX ]")
+		time.Sleep(time.Duration(cfg.Workload.Think))
 	}
+
+	// os outros ainda podem precisar das respostas deste processo: continua ate ctrl+c
+	logf("[ APP id: ", id, " TERMINOU ", cfg.Workload.Count, " ENTRADAS ]")
+	select {}
 }
@@ -0,0 +1,144 @@
+package analysis
+
+import (
+	"fmt"
+	"strings"
+)
+
+// invariantes do DIMEX (Ricart-Agrawala), registradas no modo ModeDIMEX
+func init() {
+	Register(NewInvariant("one-in-cs", "Invariante 1: No max. um processo na SC", ModeDIMEX, checkOnlyOneInSC))
+	Register(NewInvariant("idle-no-waiting", "Invariante 2: Se todos noMX entao sem waitings nem mensagens", ModeDIMEX, checkIfAllNotWantingThenNoWaitings))
+	Register(NewInvariant("waiting-needs-interest", "Invariante 3: Se waiting[q] em p entao p esta InMX ou WantMX", ModeDIMEX, checkIfHaveWaitingThenInSCOrWanting))
+	Register(NewInvariant("resp-count", "Invariante 4: Consistencia de nbrResps para WantMX", ModeDIMEX, checkIfWantingThenMessageCount))
+	Register(NewInvariant("timestamps", "Invariante 5: Consistencia de timestamps", ModeDIMEX, checkTimestampConsistency))
+	Register(NewInvariant("stuck-wanting", "Invariante 6: Detecção de Deadlock (Travado em WantMX)", ModeDIMEX, checkStuckAtWanting))
+}
+
+// invariante 1
+func checkOnlyOneInSC(snapshot Snapshot) (bool, string) {
+	inSCCount := 0
+	var inSCProcesses []int
+
+	for _, process := range snapshot.Processes {
+		if process.State == InMX {
+			inSCCount++
+			inSCProcesses = append(inSCProcesses, process.ID)
+		}
+	}
+
+	if inSCCount > 1 {
+		return false, fmt.Sprintf("Violação: %d processos na SC simultaneamente: %v", inSCCount, inSCProcesses)
+	}
+	return true, ""
+}
+
+// invariante 2
+func checkIfAllNotWantingThenNoWaitings(snapshot Snapshot) (bool, string) {
+	// todaos os processos estao em noMX?
+	for _, process := range snapshot.Processes {
+		if process.State != NoMX {
+			return true, "" //não se aplica caso não estejam todos em NoMX
+		}
+	}
+
+	// todos estao em noMX, verifica se nao tem waitings nem mensagens
+	for _, process := range snapshot.Processes {
+		// olha por waitings (verifica se tem algum bit '1')
+		if strings.Contains(process.Waiting, "1") {
+			return false, fmt.Sprintf("Violação: Processo %d tem waiting=%s mas todos estão em noMX", process.ID, process.Waiting)
+		}
+
+		// olha por mensagens em transito
+		if len(process.Messages) > 0 {
+			return false, fmt.Sprintf("Violação: Processo %d tem mensagens em trânsito mas todos estão em noMX: %v", process.ID, process.Messages)
+		}
+	}
+
+	return true, ""
+}
+
+// invariante 3
+func checkIfHaveWaitingThenInSCOrWanting(snapshot Snapshot) (bool, string) {
+	for _, process := range snapshot.Processes {
+		// olha se tem algum bit '1' no waiting
+		if strings.Contains(process.Waiting, "1") && process.State == NoMX {
+			return false, fmt.Sprintf("Violação: Processo %d tem waiting=%s mas está em noMX", process.ID, process.Waiting)
+		}
+	}
+	return true, ""
+}
+
+// Invariante 4
+func checkIfWantingThenMessageCount(snapshot Snapshot) (bool, string) {
+	N := len(snapshot.Processes)
+
+	for _, process := range snapshot.Processes {
+		if process.State == WantMX {
+			message_count := 0
+
+			message_count += process.NbrResps
+			for _, msg := range process.Messages {
+				if strings.Contains(msg, "respOk") {
+					message_count++
+				}
+			}
+
+			for _, otherProcess := range snapshot.Processes {
+				if otherProcess.ID != process.ID {
+					// olha se o indice esta dentro dos limites
+					if process.ID < len(otherProcess.Waiting) && otherProcess.Waiting[process.ID] == '1' {
+						message_count++
+					}
+
+					for _, msg := range otherProcess.Messages {
+						if strings.Contains(msg, fmt.Sprintf("reqEntry,%d", process.ID)) {
+							message_count++
+						}
+					}
+				}
+			}
+			// nmr msg recebidas
+			// nmr msg em transito
+			// flags em outros processos
+			// soma de tudo < N
+
+			if message_count != N-1 {
+				return false, fmt.Sprintf("Violacao: Processo %d em wantMX tem sum de mensagens (%d) != N-1 (%d)", process.ID, message_count, N-1)
+			}
+		}
+	}
+
+	return true, ""
+}
+
+// invariante 5
+func checkTimestampConsistency(snapshot Snapshot) (bool, string) {
+	for _, process := range snapshot.Processes {
+		// se o processo esta WantMX ou InMX, reqTs deve ser > 0
+		if (process.State == WantMX || process.State == InMX) && process.ReqTs <= 0 {
+			return false, fmt.Sprintf("Violação: Processo %d em estado %v deve ter reqTs > 0, mas tem %d",
+				process.ID, process.State, process.ReqTs)
+		}
+
+		// reqTs deve ser <= lcl (pois foi definido quando lcl tinha esse valor)
+		if process.ReqTs > process.Lcl {
+			return false, fmt.Sprintf("Violação: Processo %d tem reqTs=%d > lcl=%d",
+				process.ID, process.ReqTs, process.Lcl)
+		}
+	}
+
+	return true, ""
+}
+
+// invariante 6
+func checkStuckAtWanting(snapshot Snapshot) (bool, string) {
+	N := len(snapshot.Processes)
+
+	for _, processo := range snapshot.Processes {
+		if processo.State == WantMX && processo.NbrResps == N-1 {
+			return false, fmt.Sprintf("Violação: Processo %d está em WantMX mas já tem %d respostas (de %d necessárias), indicando um deadlock.", processo.ID, processo.NbrResps, N-1)
+		}
+	}
+	return true, ""
+}
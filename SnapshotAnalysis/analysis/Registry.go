@@ -0,0 +1,100 @@
+package analysis
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// modos (algoritmos) conhecidos; invariantes com ModeAny valem para qualquer modo
+const (
+	ModeAny   = ""
+	ModeDIMEX = "dimex"
+)
+
+// Invariant e' uma propriedade verificada em cada snapshot global
+type Invariant interface {
+	Name() string        // identificador curto, usado na flag -inv
+	Description() string // texto exibido nos relatorios
+	Mode() string        // algoritmo/modo ao qual se aplica (ModeAny = todos)
+	Check(snapshot Snapshot) (bool, string)
+}
+
+type funcInvariant struct {
+	name        string
+	description string
+	mode        string
+	fn          func(Snapshot) (bool, string)
+}
+
+func (inv funcInvariant) Name() string        { return inv.name }
+func (inv funcInvariant) Description() string { return inv.description }
+func (inv funcInvariant) Mode() string        { return inv.mode }
+func (inv funcInvariant) Check(snapshot Snapshot) (bool, string) {
+	return inv.fn(snapshot)
+}
+
+// NewInvariant cria uma invariante a partir de uma funcao de verificacao
+func NewInvariant(name, description, mode string, fn func(Snapshot) (bool, string)) Invariant {
+	return funcInvariant{name: name, description: description, mode: mode, fn: fn}
+}
+
+var (
+	registryMutex sync.Mutex
+	registry      []Invariant
+)
+
+// Register adiciona uma invariante ao registro. Normalmente chamado no init()
+// do pacote que define a invariante. Nomes repetidos causam panic.
+func Register(inv Invariant) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	for _, other := range registry {
+		if other.Name() == inv.Name() {
+			panic(fmt.Sprintf("analysis: invariante %q registrada duas vezes", inv.Name()))
+		}
+	}
+	registry = append(registry, inv)
+}
+
+// Invariants devolve as invariantes registradas, na ordem de registro
+func Invariants() []Invariant {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	return append([]Invariant(nil), registry...)
+}
+
+// ForMode devolve as invariantes que se aplicam ao modo (inclui as de ModeAny)
+func ForMode(mode string) []Invariant {
+	var selected []Invariant
+	for _, inv := range Invariants() {
+		if inv.Mode() == ModeAny || inv.Mode() == mode {
+			selected = append(selected, inv)
+		}
+	}
+	return selected
+}
+
+// Lookup procura uma invariante pelo nome
+func Lookup(name string) (Invariant, bool) {
+	for _, inv := range Invariants() {
+		if inv.Name() == name {
+			return inv, true
+		}
+	}
+	return nil, false
+}
+
+// Modes devolve os modos que tem alguma invariante registrada
+func Modes() []string {
+	seen := make(map[string]bool)
+	var modes []string
+	for _, inv := range Invariants() {
+		if inv.Mode() != ModeAny && !seen[inv.Mode()] {
+			seen[inv.Mode()] = true
+			modes = append(modes, inv.Mode())
+		}
+	}
+	sort.Strings(modes)
+	return modes
+}
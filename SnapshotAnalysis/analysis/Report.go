@@ -0,0 +1,71 @@
+package analysis
+
+// ------------------------------------------------------------------------------------
+// ------- resultado da analise
+// ------------------------------------------------------------------------------------
+
+type InvariantResult struct {
+	Invariant string `json:"invariant"` // nome curto (Invariant.Name)
+	Name      string `json:"name"`      // descricao (Invariant.Description)
+	Valid     bool   `json:"valid"`
+	Message   string `json:"message,omitempty"`
+}
+
+type SnapshotReport struct {
+	Run       int               `json:"run"` // execucao do DIMEX, ver ReadHistory
+	ID        int               `json:"id"`
+	Processes []ProcessState    `json:"processes"`
+	Results   []InvariantResult `json:"results"`
+	Valid     bool              `json:"valid"`
+}
+
+// SequenceResult e' o resultado de uma propriedade temporal
+type SequenceResult struct {
+	Property   string              `json:"property"` // nome curto (SequenceProperty.Name)
+	Name       string              `json:"name"`     // descricao
+	Violations []SequenceViolation `json:"violations"`
+}
+
+type Report struct {
+	Snapshots       []SnapshotReport `json:"snapshots"`
+	Problems        []string         `json:"problems"` // snapshots incompletos ou duplicados
+	Sequence        []SequenceResult `json:"sequence"` // propriedades temporais
+	TotalViolations int              `json:"totalViolations"`
+}
+
+// Failed indica se a analise deve terminar com status de erro
+func (r Report) Failed() bool {
+	return r.TotalViolations > 0 || len(r.Problems) > 0
+}
+
+func (sr SnapshotReport) Key() SnapshotKey {
+	return SnapshotKey{Run: sr.Run, ID: sr.ID}
+}
+
+// Analyze verifica as invariantes em cada snapshot
+func Analyze(snapshots []Snapshot, problems []string, selected []Invariant) Report {
+	report := Report{Problems: problems}
+
+	// olha cada snapshot, testa cada invariante
+	for _, snapshot := range snapshots {
+		sr := SnapshotReport{Run: snapshot.Run, ID: snapshot.ID, Processes: snapshot.Processes, Valid: true}
+		for _, inv := range selected {
+			valid, message := inv.Check(snapshot)
+			sr.Results = append(sr.Results, InvariantResult{Invariant: inv.Name(), Name: inv.Description(), Valid: valid, Message: message})
+			if !valid {
+				sr.Valid = false
+				report.TotalViolations++
+			}
+		}
+		report.Snapshots = append(report.Snapshots, sr)
+	}
+	return report
+}
+
+// AddSequence inclui no relatorio o resultado das propriedades temporais
+func (r *Report) AddSequence(results []SequenceResult) {
+	r.Sequence = append(r.Sequence, results...)
+	for _, result := range results {
+		r.TotalViolations += len(result.Violations)
+	}
+}
@@ -0,0 +1,420 @@
+// Package analysis le os snapshots gravados pelo DIMEX e verifica invariantes sobre eles.
+// Invariantes novas (de outros algoritmos/modos) sao adicionadas com Register,
+// sem alterar a ferramenta: basta importar o pacote que as registra.
+package analysis
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type State int
+
+const (
+	NoMX State = iota
+	WantMX
+	InMX
+)
+
+func (s State) String() string {
+	switch s {
+	case NoMX:
+		return "noMX"
+	case WantMX:
+		return "wantMX"
+	case InMX:
+		return "inMX"
+	}
+	return "State(" + strconv.Itoa(int(s)) + ")"
+}
+
+func (s State) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+type ProcessState struct {
+	ID       int      `json:"id"`
+	State    State    `json:"state"`
+	Waiting  string   `json:"waiting"`
+	Lcl      int      `json:"lcl"`
+	ReqTs    int      `json:"reqTs"`
+	NbrResps int      `json:"nbrResps"`
+	Messages []string `json:"messages"`
+}
+
+type Snapshot struct {
+	Run       int // execucao do DIMEX (0 = primeira nos arquivos), ver ReadHistory
+	ID        int
+	Processes []ProcessState
+}
+
+// SnapshotKey identifica um snapshot: os ids recomecam em 0 a cada execucao do DIMEX
+type SnapshotKey struct {
+	Run, ID int
+}
+
+// Label e' o id do snapshot, com a execucao quando nao e' a primeira
+func (k SnapshotKey) Label() string {
+	if k.Run == 0 {
+		return strconv.Itoa(k.ID)
+	}
+	return fmt.Sprintf("%d da execucao %d", k.ID, k.Run+1)
+}
+
+func (s Snapshot) Key() SnapshotKey {
+	return SnapshotKey{Run: s.Run, ID: s.ID}
+}
+
+// snapshot_proc_<id>.txt, com sufixo opcional .N dos arquivos rotacionados
+var snapshotFileRe = regexp.MustCompile(`^snapshot_proc_(\d+)\.txt(\.\d+)?$`)
+
+// ParseSnapshotLine interpreta uma linha gravada pelo DIMEX (SnapshotToString)
+// e devolve o id do snapshot e o estado do processo proc_id.
+func ParseSnapshotLine(line string, proc_id int) (int, ProcessState, error) {
+	parts := strings.Split(line, " ")
+	if len(parts) < 6 {
+		return 0, ProcessState{}, fmt.Errorf("formato inválido da linha: %s", line)
+	}
+
+	// id do snapshot (primeiro campo)
+	snapshotID, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, ProcessState{}, fmt.Errorf("erro ao parsear snapshot ID: %s - %v", parts[0], err)
+	}
+
+	// estado do processo (segundo campo)
+	var state State
+	switch parts[1] {
+	case "noMX":
+		state = NoMX
+	case "wantMX":
+		state = WantMX
+	case "inMX":
+		state = InMX
+	default:
+		return 0, ProcessState{}, fmt.Errorf("estado desconhecido: %s", parts[1])
+	}
+
+	// flags de waiting (terceiro campo)
+	waiting := parts[2]
+
+	// pegar lcl, reqTs, nbrResps (quarto, quinto e sexto campos)
+	lcl, err := strconv.Atoi(parts[3])
+	if err != nil {
+		return 0, ProcessState{}, fmt.Errorf("erro ao parsear lcl: %v", err)
+	}
+
+	reqTs, err := strconv.Atoi(parts[4])
+	if err != nil {
+		return 0, ProcessState{}, fmt.Errorf("erro ao parsear reqTs: %v", err)
+	}
+
+	nbrResps, err := strconv.Atoi(parts[5])
+	if err != nil {
+		return 0, ProcessState{}, fmt.Errorf("erro ao parsear nbrResps: %v", err)
+	}
+
+	// mensagens em trânsito (a partir do sétimo campo, se existir)
+	var messages []string
+	if len(parts) > 6 {
+		messagesPart := strings.Join(parts[6:], " ")
+		if strings.Contains(messagesPart, ";;") {
+			messages = strings.Split(messagesPart, ";;")
+			// eemove elementos vazios
+			var filteredMessages []string
+			for _, msg := range messages {
+				if strings.TrimSpace(msg) != "" {
+					filteredMessages = append(filteredMessages, strings.TrimSpace(msg))
+				}
+			}
+			messages = filteredMessages
+		}
+	}
+
+	return snapshotID, ProcessState{
+		ID:       proc_id,
+		State:    state,
+		Waiting:  waiting,
+		Lcl:      lcl,
+		ReqTs:    reqTs,
+		NbrResps: nbrResps,
+		Messages: messages,
+	}, nil
+}
+
+// SnapshotFiles lista os arquivos snapshot_* do diretorio dir
+func SnapshotFiles(dir string) ([]string, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler o diretório: %v", err)
+	}
+	var paths []string
+	for _, file := range files {
+		if !file.IsDir() && strings.HasPrefix(file.Name(), "snapshot") {
+			paths = append(paths, filepath.Join(dir, file.Name()))
+		}
+	}
+	return paths, nil
+}
+
+// History e' o resultado da leitura dos arquivos de snapshot
+type History struct {
+	Snapshots    []Snapshot    // snapshots completos, ordenados por execucao e id
+	Recorded     map[int][]int // processo -> ids dos snapshots na ordem em que foram gravados
+	RecordedRuns map[int][]int // processo -> execucao de cada id em Recorded
+}
+
+// Runs separa a historia por execucao (uma so se os arquivos tem uma execucao)
+func (h History) Runs() []History {
+	last := -1
+	for _, s := range h.Snapshots {
+		if s.Run > last {
+			last = s.Run
+		}
+	}
+	for _, runs := range h.RecordedRuns {
+		for _, r := range runs {
+			if r > last {
+				last = r
+			}
+		}
+	}
+	if last <= 0 {
+		return []History{h}
+	}
+	runs := make([]History, last+1)
+	for r := range runs {
+		runs[r] = History{Recorded: make(map[int][]int), RecordedRuns: make(map[int][]int)}
+	}
+	for _, s := range h.Snapshots {
+		runs[s.Run].Snapshots = append(runs[s.Run].Snapshots, s)
+	}
+	for p, ids := range h.Recorded {
+		for i, id := range ids {
+			r := h.RecordedRuns[p][i]
+			runs[r].Recorded[p] = append(runs[r].Recorded[p], id)
+			runs[r].RecordedRuns[p] = append(runs[r].RecordedRuns[p], r)
+		}
+	}
+	return runs
+}
+
+// ReadSnapshots e' ReadHistory devolvendo apenas os snapshots completos
+func ReadSnapshots(paths []string, nProcs int) ([]Snapshot, []string, error) {
+	history, problems, err := ReadHistory(paths, nProcs)
+	return history.Snapshots, problems, err
+}
+
+// rotation devolve o sufixo de rotacao do arquivo (0 = arquivo atual, N = mais antigo)
+func rotation(match []string) int {
+	if match[2] == "" {
+		return 0
+	}
+	n, _ := strconv.Atoi(match[2][1:])
+	return n
+}
+
+// fileOrder devolve processo e rotacao do arquivo (-1 se o nome estiver fora do formato)
+func fileOrder(path string) (int, int) {
+	match := snapshotFileRe.FindStringSubmatch(filepath.Base(path))
+	if match == nil {
+		return -1, 0
+	}
+	proc, _ := strconv.Atoi(match[1])
+	return proc, rotation(match)
+}
+
+// ReadHistory junta os pedacos de cada snapshot pelo id gravado na linha,
+// e o processo pelo id no nome do arquivo. O FileSnapshotSink acrescenta ao arquivo e os ids
+// recomecam em 0 a cada execucao do DIMEX: cada execucao comeca cada arquivo que escreve com
+// o marcador "# execucao <id>" (runMarker), e os pedacos sao juntados por (execucao, id).
+// As execucoes sao numeradas a partir de 0 pela ordem dos ids dos marcadores; linhas antes
+// de qualquer marcador (gravadas por versoes sem marcador) formam a primeira execucao.
+// Snapshots incompletos (falta algum processo)
+// ou duplicados (mesmo processo gravou o mesmo id mais de uma vez) nao sao analisados,
+// sao devolvidos como problemas. Com nProcs > 0 espera os processos 0..nProcs-1,
+// senao considera os processos que tem arquivo.
+func ReadHistory(paths []string, nProcs int) (History, []string, error) {
+	history := History{Recorded: make(map[int][]int), RecordedRuns: make(map[int][]int)}
+	pieces := make(map[SnapshotKey]map[int]ProcessState) // snapshot -> processo -> estado
+	duplicated := make(map[SnapshotKey]map[int]bool)     // snapshot -> processo duplicado
+	procs := make(map[int]bool)                          // processos com arquivo de snapshot
+	lines := make(map[int][]recordedLine)                // processo -> linhas na ordem dos arquivos
+	runs := make(map[string]bool)                        // execucoes com alguma linha
+	var problems []string
+
+	for p := 0; p < nProcs; p++ {
+		procs[p] = true
+	}
+
+	// arquivos rotacionados mais antigos primeiro, para Recorded ficar em ordem cronologica
+	paths = append([]string(nil), paths...)
+	sort.SliceStable(paths, func(i, j int) bool {
+		pi, ri := fileOrder(paths[i])
+		pj, rj := fileOrder(paths[j])
+		if pi != pj {
+			return pi < pj
+		}
+		return ri > rj
+	})
+
+	// ler todos os arquivos snapshot
+	for _, filePath := range paths {
+		name := filepath.Base(filePath)
+		match := snapshotFileRe.FindStringSubmatch(name)
+		if match == nil {
+			log.Printf("Aviso: arquivo %s ignorado, nome fora do formato snapshot_proc_<id>.txt", name)
+			continue
+		}
+		processID, _ := strconv.Atoi(match[1])
+		if nProcs > 0 && processID >= nProcs {
+			log.Printf("Aviso: arquivo %s ignorado, processo %d fora de 0..%d", name, processID, nProcs-1)
+			continue
+		}
+		procs[processID] = true
+
+		f, err := os.Open(filePath)
+		if err != nil {
+			return history, nil, fmt.Errorf("não foi possível abrir o arquivo %s: %v", name, err)
+		}
+
+		scanner := bufio.NewScanner(f)
+		lineNumber := 0
+		run := "" // execucao do ultimo marcador deste arquivo
+		for scanner.Scan() {
+			lineNumber++
+			line := scanner.Text()
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			if strings.HasPrefix(line, runMarker) {
+				run = strings.TrimSpace(strings.TrimPrefix(line, runMarker))
+				continue
+			}
+			if strings.HasPrefix(line, "#") {
+				continue
+			}
+			snapshotID, processState, err := ParseSnapshotLine(line, processID)
+			if err != nil {
+				log.Printf("Erro ao parsear linha %d de %s: %v", lineNumber, name, err)
+				continue
+			}
+			lines[processID] = append(lines[processID], recordedLine{run: run, id: snapshotID, state: processState})
+			runs[run] = true
+		}
+		f.Close()
+
+		if err := scanner.Err(); err != nil {
+			return history, nil, fmt.Errorf("erro ao ler o arquivo %s: %v", name, err)
+		}
+	}
+
+	order := runOrder(runs)
+	for processID, recorded := range lines {
+		for _, l := range recorded {
+			key := SnapshotKey{Run: order[l.run], ID: l.id}
+			history.Recorded[processID] = append(history.Recorded[processID], l.id)
+			history.RecordedRuns[processID] = append(history.RecordedRuns[processID], key.Run)
+			if pieces[key] == nil {
+				pieces[key] = make(map[int]ProcessState)
+			}
+			if _, ok := pieces[key][processID]; ok {
+				if duplicated[key] == nil {
+					duplicated[key] = make(map[int]bool)
+				}
+				duplicated[key][processID] = true
+				continue
+			}
+			pieces[key][processID] = l.state
+		}
+	}
+
+	var keys []SnapshotKey
+	for key := range pieces {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Run != keys[j].Run {
+			return keys[i].Run < keys[j].Run
+		}
+		return keys[i].ID < keys[j].ID
+	})
+
+	// converte para a estrutura manipulavel
+	snapshots := history.Snapshots
+	for _, id := range keys {
+		if len(duplicated[id]) > 0 {
+			problems = append(problems, fmt.Sprintf("Snapshot %s duplicado nos processos %v", id.Label(), sortedKeys(duplicated[id])))
+			continue
+		}
+		var missing []int
+		for p := range procs {
+			if _, ok := pieces[id][p]; !ok {
+				missing = append(missing, p)
+			}
+		}
+		if len(missing) > 0 {
+			sort.Ints(missing)
+			problems = append(problems, fmt.Sprintf("Snapshot %s incompleto, faltam os processos %v", id.Label(), missing))
+			continue
+		}
+
+		var processes []ProcessState
+		for _, p := range sortedKeys(pieces[id]) {
+			processes = append(processes, pieces[id][p])
+		}
+		snapshots = append(snapshots, Snapshot{
+			Run:       id.Run,
+			ID:        id.ID,
+			Processes: processes,
+		})
+	}
+	history.Snapshots = snapshots
+	return history, problems, nil
+}
+
+// runMarker inicia as linhas de uma execucao (DIMEX.RunMarker)
+const runMarker = "# execucao "
+
+// recordedLine e' uma linha de snapshot com a execucao do marcador anterior a ela
+type recordedLine struct {
+	run   string
+	id    int
+	state ProcessState
+}
+
+// runOrder numera as execucoes pelos ids dos marcadores (instante em que o sink foi criado),
+// qualquer que seja a ordem dos arquivos. Linhas sem marcador ("") vem antes de todas.
+func runOrder(runs map[string]bool) map[string]int {
+	var ids []string
+	for m := range runs {
+		ids = append(ids, m)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if len(ids[i]) != len(ids[j]) {
+			return len(ids[i]) < len(ids[j]) // ids numericos: menos digitos, menor
+		}
+		return ids[i] < ids[j]
+	})
+	order := make(map[string]int)
+	for _, m := range ids {
+		order[m] = len(order)
+	}
+	return order
+}
+
+func sortedKeys[V any](m map[int]V) []int {
+	var keys []int
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	return keys
+}
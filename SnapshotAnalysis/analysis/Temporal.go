@@ -0,0 +1,371 @@
+package analysis
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ------------------------------------------------------------------------------------
+// ------- propriedades temporais (sobre a sequencia de snapshots)
+// ------------------------------------------------------------------------------------
+
+// SequenceProperty e' uma propriedade verificada sobre a sequencia de snapshots,
+// como vivacidade e espera limitada, que nao aparecem olhando um snapshot isolado
+type SequenceProperty interface {
+	Name() string
+	Description() string
+	Mode() string
+	CheckSequence(history History) []SequenceViolation
+}
+
+// SequenceViolation descreve uma violacao ao longo de uma faixa de snapshots
+type SequenceViolation struct {
+	Run       int    `json:"run"`       // execucao do DIMEX, ver ReadHistory
+	Process   int    `json:"process"`   // processo afetado (-1 se nao for de um processo)
+	From      int    `json:"from"`      // id do primeiro snapshot envolvido
+	To        int    `json:"to"`        // id do ultimo snapshot envolvido
+	Snapshots int    `json:"snapshots"` // quantos snapshots consecutivos
+	Message   string `json:"message"`
+}
+
+// Limites usados pelas propriedades temporais (ajustaveis pela linha de comando)
+type TemporalLimits struct {
+	MaxWaitSnapshots  int // snapshots seguidos em wantMX com o mesmo reqTs antes de acusar inanicao
+	MaxStallSnapshots int // snapshots seguidos sem lcl avancar, com alguem querendo a SC
+}
+
+var Limits = TemporalLimits{
+	MaxWaitSnapshots:  5,
+	MaxStallSnapshots: 3,
+}
+
+type funcSequenceProperty struct {
+	name        string
+	description string
+	mode        string
+	fn          func(History) []SequenceViolation
+}
+
+func (p funcSequenceProperty) Name() string        { return p.name }
+func (p funcSequenceProperty) Description() string { return p.description }
+func (p funcSequenceProperty) Mode() string        { return p.mode }
+func (p funcSequenceProperty) CheckSequence(history History) []SequenceViolation {
+	return p.fn(history)
+}
+
+// NewSequenceProperty cria uma propriedade temporal a partir de uma funcao de verificacao
+func NewSequenceProperty(name, description, mode string, fn func(History) []SequenceViolation) SequenceProperty {
+	return funcSequenceProperty{name: name, description: description, mode: mode, fn: fn}
+}
+
+var (
+	sequenceMutex    sync.Mutex
+	sequenceRegistry []SequenceProperty
+)
+
+// RegisterSequence adiciona uma propriedade temporal ao registro. Nomes repetidos causam panic.
+func RegisterSequence(prop SequenceProperty) {
+	sequenceMutex.Lock()
+	defer sequenceMutex.Unlock()
+	for _, other := range sequenceRegistry {
+		if other.Name() == prop.Name() {
+			panic(fmt.Sprintf("analysis: propriedade %q registrada duas vezes", prop.Name()))
+		}
+	}
+	sequenceRegistry = append(sequenceRegistry, prop)
+}
+
+// SequenceProperties devolve as propriedades temporais registradas, na ordem de registro
+func SequenceProperties() []SequenceProperty {
+	sequenceMutex.Lock()
+	defer sequenceMutex.Unlock()
+	return append([]SequenceProperty(nil), sequenceRegistry...)
+}
+
+// SequenceForMode devolve as propriedades temporais que se aplicam ao modo
+func SequenceForMode(mode string) []SequenceProperty {
+	var selected []SequenceProperty
+	for _, prop := range SequenceProperties() {
+		if prop.Mode() == ModeAny || prop.Mode() == mode {
+			selected = append(selected, prop)
+		}
+	}
+	return selected
+}
+
+// LookupSequence procura uma propriedade temporal pelo nome
+func LookupSequence(name string) (SequenceProperty, bool) {
+	for _, prop := range SequenceProperties() {
+		if prop.Name() == name {
+			return prop, true
+		}
+	}
+	return nil, false
+}
+
+func init() {
+	RegisterSequence(NewSequenceProperty("monotonic-ids", "Temporal 1: Ids de snapshot crescentes e sem lacunas", ModeAny, checkMonotonicIDs))
+	RegisterSequence(NewSequenceProperty("lcl-progress", "Temporal 2: Relogio logico (lcl) progride", ModeDIMEX, checkLclProgress))
+	RegisterSequence(NewSequenceProperty("bounded-waiting", "Temporal 3: Espera limitada (mesmo pedido em wantMX)", ModeDIMEX, checkBoundedWaiting))
+	RegisterSequence(NewSequenceProperty("fair-entry", "Temporal 4: Entrada na SC na ordem (reqTs, id)", ModeDIMEX, checkFairEntry))
+}
+
+// temporal 1: cada processo grava ids estritamente crescentes, e a sequencia global nao tem buracos.
+// Um id gravado por algum processo mas fora de Snapshots (incompleto ou duplicado) nao e' buraco:
+// ReadHistory ja o devolve como problema
+func checkMonotonicIDs(history History) []SequenceViolation {
+	var violations []SequenceViolation
+
+	recorded := make(map[int]bool)
+	for _, p := range sortedKeys(history.Recorded) {
+		ids := history.Recorded[p]
+		for _, id := range ids {
+			recorded[id] = true
+		}
+		for i := 1; i < len(ids); i++ {
+			if ids[i] <= ids[i-1] {
+				violations = append(violations, SequenceViolation{
+					Process: p, From: ids[i-1], To: ids[i], Snapshots: 2,
+					Message: fmt.Sprintf("Violação: Processo %d gravou o snapshot %d depois do %d", p, ids[i], ids[i-1]),
+				})
+			}
+		}
+	}
+
+	for i := 1; i < len(history.Snapshots); i++ {
+		prev, cur := history.Snapshots[i-1].ID, history.Snapshots[i].ID
+		var missing []int
+		for id := prev + 1; id < cur; id++ {
+			if !recorded[id] {
+				missing = append(missing, id)
+			}
+		}
+		if len(missing) > 0 {
+			violations = append(violations, SequenceViolation{
+				Process: -1, From: prev, To: cur, Snapshots: 2,
+				Message: fmt.Sprintf("Violação: faltam os snapshots %s entre %d e %d", idRanges(missing), prev, cur),
+			})
+		}
+	}
+	return violations
+}
+
+// temporal 2: lcl nunca diminui, e nao fica parado por muitos snapshots enquanto ha pedidos pendentes
+func checkLclProgress(history History) []SequenceViolation {
+	var violations []SequenceViolation
+	snapshots := history.Snapshots
+
+	for _, p := range processIDs(snapshots) {
+		stallStart := -1 // indice do primeiro snapshot do trecho parado
+		for i := 1; i < len(snapshots); i++ {
+			prev, okPrev := processIn(snapshots[i-1], p)
+			cur, okCur := processIn(snapshots[i], p)
+			if !okPrev || !okCur {
+				stallStart = -1
+				continue
+			}
+			if cur.Lcl < prev.Lcl {
+				violations = append(violations, SequenceViolation{
+					Process: p, From: snapshots[i-1].ID, To: snapshots[i].ID, Snapshots: 2,
+					Message: fmt.Sprintf("Violação: Processo %d teve lcl reduzido de %d para %d", p, prev.Lcl, cur.Lcl),
+				})
+			}
+			if cur.Lcl == prev.Lcl && someoneWants(snapshots[i]) {
+				if stallStart < 0 {
+					stallStart = i - 1
+				}
+				continue
+			}
+			violations = appendStall(violations, snapshots, p, stallStart, i-1)
+			stallStart = -1
+		}
+		violations = appendStall(violations, snapshots, p, stallStart, len(snapshots)-1)
+	}
+	return violations
+}
+
+func appendStall(violations []SequenceViolation, snapshots []Snapshot, p, start, end int) []SequenceViolation {
+	if start < 0 || end-start+1 <= Limits.MaxStallSnapshots {
+		return violations
+	}
+	count := end - start + 1
+	proc, _ := processIn(snapshots[end], p)
+	return append(violations, SequenceViolation{
+		Process: p, From: snapshots[start].ID, To: snapshots[end].ID, Snapshots: count,
+		Message: fmt.Sprintf("Violação: Processo %d com lcl=%d parado por %d snapshots (%d..%d) com pedidos pendentes",
+			p, proc.Lcl, count, snapshots[start].ID, snapshots[end].ID),
+	})
+}
+
+// temporal 3: um mesmo pedido (mesmo reqTs) nao fica em wantMX por mais de MaxWaitSnapshots snapshots
+func checkBoundedWaiting(history History) []SequenceViolation {
+	var violations []SequenceViolation
+	snapshots := history.Snapshots
+
+	for _, p := range processIDs(snapshots) {
+		start := -1
+		reqTs := 0
+		flush := func(end int) {
+			if start < 0 {
+				return
+			}
+			count := end - start + 1
+			if count > Limits.MaxWaitSnapshots {
+				violations = append(violations, SequenceViolation{
+					Process: p, From: snapshots[start].ID, To: snapshots[end].ID, Snapshots: count,
+					Message: fmt.Sprintf("Violação: Processo %d em wantMX com reqTs=%d por %d snapshots (%d..%d), possivel inanição",
+						p, reqTs, count, snapshots[start].ID, snapshots[end].ID),
+				})
+			}
+			start = -1
+		}
+
+		for i, snapshot := range snapshots {
+			proc, ok := processIn(snapshot, p)
+			if ok && proc.State == WantMX && start >= 0 && proc.ReqTs == reqTs {
+				continue // mesmo pedido ainda esperando
+			}
+			flush(i - 1)
+			if ok && proc.State == WantMX {
+				start, reqTs = i, proc.ReqTs
+			}
+		}
+		flush(len(snapshots) - 1)
+	}
+	return violations
+}
+
+// temporal 4: quem entra na SC nao passa na frente de um pedido anterior (menor (reqTs, id)) ainda pendente
+func checkFairEntry(history History) []SequenceViolation {
+	var violations []SequenceViolation
+	snapshots := history.Snapshots
+
+	type overtake struct{ start, end, by int }
+	open := make(map[int]*overtake) // processo ultrapassado -> trecho atual
+
+	flush := func(p int) {
+		o := open[p]
+		if o == nil {
+			return
+		}
+		count := o.end - o.start + 1
+		violations = append(violations, SequenceViolation{
+			Process: p, From: snapshots[o.start].ID, To: snapshots[o.end].ID, Snapshots: count,
+			Message: fmt.Sprintf("Violação: Processo %d tinha pedido anterior mas o processo %d entrou na SC antes (%d snapshot(s), %d..%d)",
+				p, o.by, count, snapshots[o.start].ID, snapshots[o.end].ID),
+		})
+		delete(open, p)
+	}
+
+	for i, snapshot := range snapshots {
+		overtaken := make(map[int]int)
+		for _, in := range snapshot.Processes {
+			if in.State != InMX {
+				continue
+			}
+			for _, q := range snapshot.Processes {
+				if q.State == WantMX && before(q.ID, q.ReqTs, in.ID, in.ReqTs) {
+					overtaken[q.ID] = in.ID
+				}
+			}
+		}
+		for p := range open {
+			if _, ok := overtaken[p]; !ok {
+				flush(p)
+			}
+		}
+		for p, by := range overtaken {
+			if o := open[p]; o != nil && o.by == by {
+				o.end = i
+			} else {
+				flush(p)
+				open[p] = &overtake{start: i, end: i, by: by}
+			}
+		}
+	}
+	for _, p := range sortedKeys(open) {
+		flush(p)
+	}
+	return violations
+}
+
+// ------------------------------------------------------------------------------------
+// ------- funcoes de ajuda
+// ------------------------------------------------------------------------------------
+
+// AnalyzeSequence verifica as propriedades temporais sobre a historia, separadamente
+// em cada execucao do DIMEX (relogios e ids recomecam a cada execucao)
+func AnalyzeSequence(history History, selected []SequenceProperty) []SequenceResult {
+	runs := history.Runs()
+	var results []SequenceResult
+	for _, prop := range selected {
+		result := SequenceResult{Property: prop.Name(), Name: prop.Description()}
+		for r, run := range runs {
+			for _, v := range prop.CheckSequence(run) {
+				v.Run = r
+				if len(runs) > 1 {
+					v.Message = fmt.Sprintf("execucao %d: %s", r+1, v.Message)
+				}
+				result.Violations = append(result.Violations, v)
+			}
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// before segue a ordem de prioridade do DIMEX: menor timestamp, desempate pelo menor id
+func before(oneId, oneTs, othId, othTs int) bool {
+	if oneTs != othTs {
+		return oneTs < othTs
+	}
+	return oneId < othId
+}
+
+func processIn(snapshot Snapshot, id int) (ProcessState, bool) {
+	for _, process := range snapshot.Processes {
+		if process.ID == id {
+			return process, true
+		}
+	}
+	return ProcessState{}, false
+}
+
+func processIDs(snapshots []Snapshot) []int {
+	ids := make(map[int]bool)
+	for _, snapshot := range snapshots {
+		for _, process := range snapshot.Processes {
+			ids[process.ID] = true
+		}
+	}
+	return sortedKeys(ids)
+}
+
+// idRanges escreve ids crescentes como faixas: [3 4 5 8] -> "3..5, 8"
+func idRanges(ids []int) string {
+	var parts []string
+	for i := 0; i < len(ids); {
+		j := i
+		for j+1 < len(ids) && ids[j+1] == ids[j]+1 {
+			j++
+		}
+		if j == i {
+			parts = append(parts, strconv.Itoa(ids[i]))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d..%d", ids[i], ids[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ", ")
+}
+
+func someoneWants(snapshot Snapshot) bool {
+	for _, process := range snapshot.Processes {
+		if process.State == WantMX {
+			return true
+		}
+	}
+	return false
+}
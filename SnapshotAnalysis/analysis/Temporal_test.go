@@ -0,0 +1,105 @@
+package analysis
+
+import (
+	"strings"
+	"testing"
+)
+
+// snap monta um snapshot com um ProcessState por processo, na ordem dos ids
+func snap(id int, procs ...ProcessState) Snapshot {
+	for i := range procs {
+		procs[i].ID = i
+	}
+	return Snapshot{ID: id, Processes: procs}
+}
+
+func st(state State, lcl, reqTs int) ProcessState {
+	return ProcessState{State: state, Lcl: lcl, ReqTs: reqTs}
+}
+
+// recordedAll e' o Recorded de uma historia em que todos gravaram todos os snapshots
+func recordedAll(snapshots []Snapshot) map[int][]int {
+	recorded := make(map[int][]int)
+	for _, s := range snapshots {
+		for _, p := range s.Processes {
+			recorded[p.ID] = append(recorded[p.ID], s.ID)
+		}
+	}
+	return recorded
+}
+
+func historyOf(snapshots ...Snapshot) History {
+	return History{Snapshots: snapshots, Recorded: recordedAll(snapshots)}
+}
+
+func TestSequenceProperties(t *testing.T) {
+	cases := []struct {
+		name     string
+		property string
+		history  History
+		want     int    // violacoes esperadas
+		contains string // trecho da mensagem da primeira violacao
+	}{
+		{"ids em ordem", "monotonic-ids",
+			historyOf(snap(0, st(NoMX, 1, 0)), snap(1, st(NoMX, 2, 0)), snap(2, st(NoMX, 3, 0))), 0, ""},
+		{"id gravado fora de ordem", "monotonic-ids",
+			History{Snapshots: []Snapshot{snap(0, st(NoMX, 1, 0)), snap(1, st(NoMX, 2, 0))}, Recorded: map[int][]int{0: {1, 0}}},
+			1, "gravou o snapshot 0 depois do 1"},
+		{"snapshots que ninguem gravou", "monotonic-ids",
+			historyOf(snap(0, st(NoMX, 1, 0)), snap(3, st(NoMX, 2, 0)), snap(4, st(NoMX, 3, 0))), 1, "faltam os snapshots 1..2"},
+		{"snapshot incompleto ja e' problema de ReadHistory", "monotonic-ids",
+			History{
+				Snapshots: []Snapshot{snap(0, st(NoMX, 1, 0), st(NoMX, 1, 0)), snap(2, st(NoMX, 2, 0), st(NoMX, 2, 0))},
+				Recorded:  map[int][]int{0: {0, 1, 2}, 1: {0, 2}}},
+			0, ""},
+
+		{"lcl avanca", "lcl-progress",
+			historyOf(snap(0, st(WantMX, 1, 1)), snap(1, st(WantMX, 2, 1)), snap(2, st(InMX, 3, 1))), 0, ""},
+		{"lcl diminui", "lcl-progress",
+			historyOf(snap(0, st(NoMX, 5, 0)), snap(1, st(NoMX, 4, 0))), 1, "lcl reduzido de 5 para 4"},
+		{"lcl parado com pedido pendente", "lcl-progress",
+			historyOf(snap(0, st(WantMX, 2, 2)), snap(1, st(WantMX, 2, 2)), snap(2, st(WantMX, 2, 2)),
+				snap(3, st(WantMX, 2, 2)), snap(4, st(WantMX, 2, 2))), 1, "parado por 5 snapshots"},
+		{"lcl parado sem pedidos", "lcl-progress",
+			historyOf(snap(0, st(NoMX, 2, 0)), snap(1, st(NoMX, 2, 0)), snap(2, st(NoMX, 2, 0)),
+				snap(3, st(NoMX, 2, 0)), snap(4, st(NoMX, 2, 0))), 0, ""},
+
+		{"espera dentro do limite", "bounded-waiting",
+			historyOf(snap(0, st(WantMX, 1, 1)), snap(1, st(WantMX, 2, 1)), snap(2, st(WantMX, 3, 1)),
+				snap(3, st(WantMX, 4, 1)), snap(4, st(WantMX, 5, 1)), snap(5, st(InMX, 6, 1))), 0, ""},
+		{"mesmo pedido esperando demais", "bounded-waiting",
+			historyOf(snap(0, st(WantMX, 1, 1)), snap(1, st(WantMX, 2, 1)), snap(2, st(WantMX, 3, 1)),
+				snap(3, st(WantMX, 4, 1)), snap(4, st(WantMX, 5, 1)), snap(5, st(WantMX, 6, 1))), 1, "por 6 snapshots"},
+		{"pedidos diferentes em seguida", "bounded-waiting",
+			historyOf(snap(0, st(WantMX, 1, 1)), snap(1, st(WantMX, 2, 1)), snap(2, st(WantMX, 3, 1)),
+				snap(3, st(WantMX, 4, 4)), snap(4, st(WantMX, 5, 4)), snap(5, st(WantMX, 6, 4))), 0, ""},
+
+		{"entra quem pediu antes", "fair-entry",
+			historyOf(snap(0, st(InMX, 4, 3), st(WantMX, 5, 5))), 0, ""},
+		{"empate de reqTs desfeito pelo id", "fair-entry",
+			historyOf(snap(0, st(InMX, 4, 3), st(WantMX, 5, 3))), 0, ""},
+		{"entra na frente de pedido anterior", "fair-entry",
+			historyOf(snap(0, st(InMX, 6, 5), st(WantMX, 5, 3)), snap(1, st(InMX, 7, 5), st(WantMX, 6, 3))),
+			1, "processo 0 entrou na SC antes (2 snapshot(s), 0..1)"},
+	}
+	for _, c := range cases {
+		prop, ok := LookupSequence(c.property)
+		if !ok {
+			t.Fatalf("propriedade %s nao registrada", c.property)
+		}
+		violations := prop.CheckSequence(c.history)
+		if len(violations) != c.want {
+			t.Errorf("%s: %d violacoes, esperadas %d: %+v", c.name, len(violations), c.want, violations)
+			continue
+		}
+		if c.want > 0 && !strings.Contains(violations[0].Message, c.contains) {
+			t.Errorf("%s: mensagem %q sem %q", c.name, violations[0].Message, c.contains)
+		}
+	}
+}
+
+func TestIDRanges(t *testing.T) {
+	if got := idRanges([]int{1, 3, 4, 5, 8}); got != "1, 3..5, 8" {
+		t.Fatalf("idRanges: %q", got)
+	}
+}
@@ -0,0 +1,246 @@
+// Package cli implementa a linha de comando do analisador de snapshots.
+package cli
+
+import (
+	"SnapshotAnalysis/analysis"
+	"SnapshotAnalysis/diagram"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// selectInvariants interpreta a flag -inv: "all" (todas do modo), "none" ou lista de
+// nomes e/ou posicoes (1..N) na lista de invariantes do modo
+func selectInvariants(spec string, mode string) ([]analysis.Invariant, error) {
+	available := analysis.ForMode(mode)
+	if len(available) == 0 {
+		return nil, fmt.Errorf("nenhuma invariante registrada para o modo %q (modos: %v)", mode, analysis.Modes())
+	}
+	switch spec {
+	case "", "all":
+		return available, nil
+	case "none":
+		return nil, nil
+	}
+	var selected []analysis.Invariant
+	for _, field := range strings.Split(spec, ",") {
+		field = strings.TrimSpace(field)
+		if n, err := strconv.Atoi(field); err == nil {
+			if n < 1 || n > len(available) {
+				return nil, fmt.Errorf("invariante inválida %q (use 1..%d)", field, len(available))
+			}
+			selected = append(selected, available[n-1])
+			continue
+		}
+		inv, ok := analysis.Lookup(field)
+		if !ok {
+			return nil, fmt.Errorf("invariante desconhecida %q (veja -list)", field)
+		}
+		selected = append(selected, inv)
+	}
+	return selected, nil
+}
+
+// selectSequence interpreta a flag -temporal: "all", "none" ou lista de nomes
+func selectSequence(spec string, mode string) ([]analysis.SequenceProperty, error) {
+	switch spec {
+	case "", "all":
+		return analysis.SequenceForMode(mode), nil
+	case "none":
+		return nil, nil
+	}
+	var selected []analysis.SequenceProperty
+	for _, field := range strings.Split(spec, ",") {
+		prop, ok := analysis.LookupSequence(strings.TrimSpace(field))
+		if !ok {
+			return nil, fmt.Errorf("propriedade temporal desconhecida %q (veja -list)", field)
+		}
+		selected = append(selected, prop)
+	}
+	return selected, nil
+}
+
+func writeInvariantList(w io.Writer, mode string) {
+	for i, inv := range analysis.Invariants() {
+		invMode := inv.Mode()
+		if invMode == analysis.ModeAny {
+			invMode = "*"
+		}
+		marker := " "
+		if inv.Mode() == analysis.ModeAny || inv.Mode() == mode {
+			marker = "*" // ativa no modo selecionado
+		}
+		fmt.Fprintf(w, "%s %2d  %-24s [%s] %s\n", marker, i+1, inv.Name(), invMode, inv.Description())
+	}
+	fmt.Fprintln(w)
+	for _, prop := range analysis.SequenceProperties() {
+		propMode := prop.Mode()
+		if propMode == analysis.ModeAny {
+			propMode = "*"
+		}
+		marker := " "
+		if prop.Mode() == analysis.ModeAny || prop.Mode() == mode {
+			marker = "*"
+		}
+		fmt.Fprintf(w, "%s     %-24s [%s] %s\n", marker, prop.Name(), propMode, prop.Description())
+	}
+}
+
+// status de saida: 0 tudo ok, 1 violacoes ou snapshots incompletos, 2 erro de uso/leitura
+const (
+	exitOK        = 0
+	exitViolation = 1
+	exitError     = 2
+)
+
+// Run executa o analisador com os argumentos args e devolve o status de saida.
+// Um main proprio pode importar pacotes com invariantes extras (que chamam
+// analysis.Register no init) e delegar para Run.
+func Run(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("AnalysisSnasphots", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	dir := flags.String("dir", ".", "diretório com os arquivos snapshot_proc_<id>.txt (ignorado se arquivos forem passados como argumentos)")
+	nProcs := flags.Int("n", 0, "número de processos esperado (0 = deduz pelos arquivos encontrados)")
+	invSpec := flags.String("inv", "all", "invariantes a verificar: all, none ou lista de nomes/posições como one-in-cs,4,6")
+	mode := flags.String("mode", analysis.ModeDIMEX, "algoritmo/modo dos snapshots; seleciona as invariantes registradas para ele")
+	temporalSpec := flags.String("temporal", "all", "propriedades temporais (entre snapshots): all, none ou lista de nomes")
+	flags.IntVar(&analysis.Limits.MaxWaitSnapshots, "max-wait", analysis.Limits.MaxWaitSnapshots, "snapshots seguidos em wantMX com o mesmo pedido antes de acusar inanição")
+	flags.IntVar(&analysis.Limits.MaxStallSnapshots, "max-stall", analysis.Limits.MaxStallSnapshots, "snapshots seguidos sem lcl avançar, com pedidos pendentes")
+	dotDir := flags.String("dot", "", "diretório onde gravar diagramas espaço-tempo em Graphviz DOT (snapshot_<id>.dot)")
+	svgDir := flags.String("svg", "", "diretório onde gravar diagramas espaço-tempo em SVG (snapshot_<id>.svg)")
+	diagrams := flags.String("diagrams", "failed", "snapshots desenhados: failed (só os inválidos) ou all")
+	traceDir := flags.String("trace-dir", "", "diretório com trace_proc_<id>.txt para desenhar as mensagens completas")
+	window := flags.Int("window", 30, "eventos do trace desenhados antes e depois do corte (0 = todos)")
+	list := flags.Bool("list", false, "lista as invariantes registradas e termina")
+	format := flags.String("format", "text", "formato de saída: text, json ou junit")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "uso: go run AnalysisSnasphots.go [flags] [arquivos snapshot_proc_<id>.txt ...]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+
+	if *list {
+		writeInvariantList(stdout, *mode)
+		return exitOK
+	}
+
+	selected, err := selectInvariants(*invSpec, *mode)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	sequence, err := selectSequence(*temporalSpec, *mode)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		if paths, err = analysis.SnapshotFiles(*dir); err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitError
+		}
+	}
+
+	// le todos os snapshots
+	history, problems, err := analysis.ReadHistory(paths, *nProcs)
+	if err != nil {
+		fmt.Fprintf(stderr, "Erro ao ler os snapshots: %v\n", err)
+		return exitError
+	}
+
+	report := analysis.Analyze(history.Snapshots, problems, selected)
+	report.AddSequence(analysis.AnalyzeSequence(history, sequence))
+
+	if *dotDir != "" || *svgDir != "" {
+		if err := writeDiagrams(report, history, *diagrams, *traceDir, *window, *dotDir, *svgDir); err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitError
+		}
+	}
+
+	switch *format {
+	case "text":
+		writeText(stdout, report)
+	case "json":
+		err = writeJSON(stdout, report)
+	case "junit":
+		err = writeJUnit(stdout, report)
+	default:
+		fmt.Fprintf(stderr, "formato desconhecido: %s (use text, json ou junit)\n", *format)
+		return exitError
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	if report.Failed() {
+		return exitViolation
+	}
+	return exitOK
+}
+
+// writeDiagrams grava um diagrama espaco-tempo por snapshot selecionado
+func writeDiagrams(report analysis.Report, history analysis.History, which, traceDir string, window int, dotDir, svgDir string) error {
+	var trace []diagram.TraceEvent
+	if traceDir != "" {
+		paths, err := diagram.TraceFiles(traceDir)
+		if err != nil {
+			return err
+		}
+		if trace, err = diagram.ReadTrace(paths); err != nil {
+			return err
+		}
+	}
+
+	// o trace e' recriado a cada execucao do DIMEX: so descreve a ultima
+	lastRun := 0
+	for _, snapshot := range history.Snapshots {
+		if snapshot.Run > lastRun {
+			lastRun = snapshot.Run
+		}
+	}
+
+	for i, sr := range report.Snapshots {
+		if which != "all" && sr.Valid {
+			continue
+		}
+		snapshot := history.Snapshots[i]
+		d := diagram.FromSnapshot(snapshot)
+		if trace != nil && snapshot.Run == lastRun {
+			if withTrace, err := diagram.FromTrace(snapshot, trace, window); err == nil {
+				d = withTrace
+			}
+		}
+		name := fmt.Sprintf("snapshot_%d", snapshot.ID)
+		if snapshot.Run > 0 {
+			name = fmt.Sprintf("snapshot_r%d_%d", snapshot.Run+1, snapshot.ID)
+		}
+		if dotDir != "" {
+			if err := writeFile(dotDir, name+".dot", d.DOT()); err != nil {
+				return err
+			}
+		}
+		if svgDir != "" {
+			if err := writeFile(svgDir, name+".svg", d.SVG()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func writeFile(dir, name, content string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("erro ao criar o diretório %s: %v", dir, err)
+	}
+	return os.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+}
@@ -0,0 +1,115 @@
+package cli
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fixture: um arquivo snapshot_proc_<i>.txt por processo, com as linhas dadas
+func fixture(t *testing.T, files ...[]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for proc, lines := range files {
+		path := filepath.Join(dir, fmt.Sprintf("snapshot_proc_%d.txt", proc))
+		if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// dois processos: 1 entrou na SC com a resposta de 0
+func validRun(t *testing.T) string {
+	return fixture(t,
+		[]string{"0 noMX 00 1 0 0 ;;", "1 noMX 00 4 0 0 ;;"},
+		[]string{"0 noMX 00 1 0 0 ;;", "1 inMX 00 4 3 1 ;;"})
+}
+
+// dois processos na SC ao mesmo tempo
+func violatingRun(t *testing.T) string {
+	return fixture(t,
+		[]string{"0 inMX 00 3 1 1 ;;"},
+		[]string{"0 inMX 00 3 2 1 ;;"})
+}
+
+// o processo 1 nao gravou o snapshot 1
+func incompleteRun(t *testing.T) string {
+	return fixture(t,
+		[]string{"0 noMX 00 1 0 0 ;;", "1 noMX 00 2 0 0 ;;"},
+		[]string{"0 noMX 00 1 0 0 ;;"})
+}
+
+func TestRunExitStatus(t *testing.T) {
+	cases := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{"snapshots validos", []string{"-dir", validRun(t)}, exitOK},
+		{"violacao", []string{"-dir", violatingRun(t)}, exitViolation},
+		{"snapshot incompleto", []string{"-dir", incompleteRun(t)}, exitViolation},
+		{"diretorio inexistente", []string{"-dir", filepath.Join(t.TempDir(), "nada")}, exitError},
+		{"flag desconhecida", []string{"-nada"}, exitError},
+		{"formato desconhecido", []string{"-dir", validRun(t), "-format", "xml"}, exitError},
+		{"invariante desconhecida", []string{"-dir", validRun(t), "-inv", "nada"}, exitError},
+		{"lista", []string{"-list"}, exitOK},
+	}
+	for _, c := range cases {
+		var stdout, stderr strings.Builder
+		if got := Run(c.args, &stdout, &stderr); got != c.want {
+			t.Errorf("%s: status %d, esperado %d\nstdout:\n%s\nstderr:\n%s", c.name, got, c.want, stdout.String(), stderr.String())
+		}
+	}
+}
+
+func TestRunJSON(t *testing.T) {
+	for _, c := range []struct {
+		dir        string
+		want       int
+		violations bool
+	}{{validRun(t), exitOK, false}, {violatingRun(t), exitViolation, true}} {
+		var stdout, stderr strings.Builder
+		if got := Run([]string{"-dir", c.dir, "-format", "json"}, &stdout, &stderr); got != c.want {
+			t.Fatalf("status %d, esperado %d: %s", got, c.want, stderr.String())
+		}
+		var report struct {
+			Snapshots []struct {
+				ID    int  `json:"id"`
+				Valid bool `json:"valid"`
+			} `json:"snapshots"`
+			TotalViolations int `json:"totalViolations"`
+		}
+		if err := json.Unmarshal([]byte(stdout.String()), &report); err != nil {
+			t.Fatalf("JSON invalido: %v\n%s", err, stdout.String())
+		}
+		if len(report.Snapshots) == 0 || (report.TotalViolations > 0) != c.violations {
+			t.Errorf("relatorio %+v, violacoes esperadas: %v", report, c.violations)
+		}
+	}
+}
+
+func TestRunJUnit(t *testing.T) {
+	var stdout, stderr strings.Builder
+	if got := Run([]string{"-dir", violatingRun(t), "-format", "junit"}, &stdout, &stderr); got != exitViolation {
+		t.Fatalf("status %d, esperado %d: %s", got, exitViolation, stderr.String())
+	}
+	var suites junitTestSuites
+	if err := xml.Unmarshal([]byte(stdout.String()), &suites); err != nil {
+		t.Fatalf("XML invalido: %v\n%s", err, stdout.String())
+	}
+	failures := 0
+	for _, suite := range suites.Suites {
+		if suite.Tests != len(suite.TestCases) {
+			t.Errorf("testsuite %s: tests=%d com %d testcases", suite.Name, suite.Tests, len(suite.TestCases))
+		}
+		failures += suite.Failures
+	}
+	if len(suites.Suites) == 0 || suites.Suites[0].Name != "snapshot-0" || failures == 0 {
+		t.Fatalf("testsuites sem a violacao do snapshot 0: %+v", suites)
+	}
+}
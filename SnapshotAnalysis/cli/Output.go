@@ -0,0 +1,168 @@
+package cli
+
+import (
+	"SnapshotAnalysis/analysis"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// ------------------------------------------------------------------------------------
+// ------- formatos de saida
+// ------------------------------------------------------------------------------------
+
+func writeText(w io.Writer, report analysis.Report) {
+	for _, problem := range report.Problems {
+		fmt.Fprintln(w, "Aviso:", problem)
+	}
+	if len(report.Problems) > 0 {
+		fmt.Fprintln(w)
+	}
+
+	if len(report.Snapshots) == 0 {
+		fmt.Fprintln(w, "Nenhum snapshot encontrado.")
+		return
+	}
+
+	fmt.Fprintf(w, "Analisando %d snapshot(s)...\n\n", len(report.Snapshots))
+
+	for i, snapshot := range report.Snapshots {
+		fmt.Fprintf(w, "%d--- SNAPSHOT %s ---\n", i, snapshot.Key().Label())
+
+		// exibe estado dos processos
+		fmt.Fprintln(w, "Estados dos processos:")
+		for _, process := range snapshot.Processes {
+			fmt.Fprintf(w, "  Processo %d: %s, waiting=%s, lcl=%d, reqTs=%d, nbrResps=%d, msgs=%v\n",
+				process.ID, process.State, process.Waiting, process.Lcl, process.ReqTs, process.NbrResps, process.Messages)
+		}
+		fmt.Fprintln(w)
+
+		snapshotViolations := 0
+		for _, result := range snapshot.Results {
+			if !result.Valid {
+				fmt.Fprintf(w, "%s: %s\n", result.Name, result.Message)
+				snapshotViolations++
+			}
+		}
+
+		if snapshotViolations == 0 {
+			fmt.Fprintln(w, "Snapshot VALIDO - todas as invariantes satisfeitas")
+		} else {
+			fmt.Fprintf(w, "Snapshot INVALIDO - %d problemas encontrados\n", snapshotViolations)
+		}
+		fmt.Fprintln(w)
+		fmt.Fprintln(w)
+	}
+
+	if len(report.Sequence) > 0 {
+		fmt.Fprintln(w, "--- PROPRIEDADES TEMPORAIS ---")
+		for _, result := range report.Sequence {
+			if len(result.Violations) == 0 {
+				fmt.Fprintf(w, "%s: OK\n", result.Name)
+				continue
+			}
+			for _, violation := range result.Violations {
+				fmt.Fprintf(w, "%s: %s\n", result.Name, violation.Message)
+			}
+		}
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintf(w, "Total: %d snapshot(s), %d violação(ões), %d problema(s) de leitura\n",
+		len(report.Snapshots), report.TotalViolations, len(report.Problems))
+}
+
+func writeJSON(w io.Writer, report analysis.Report) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+// writeJUnit gera um testsuite por snapshot e um testcase por invariante;
+// snapshots incompletos/duplicados viram falhas do testsuite "leitura"
+func writeJUnit(w io.Writer, report analysis.Report) error {
+	var suites junitTestSuites
+
+	if len(report.Problems) > 0 {
+		suite := junitTestSuite{Name: "leitura"}
+		for _, problem := range report.Problems {
+			suite.TestCases = append(suite.TestCases, junitTestCase{
+				Name:      problem,
+				ClassName: "leitura",
+				Failure:   &junitFailure{Message: problem},
+			})
+			suite.Tests++
+			suite.Failures++
+		}
+		suites.Suites = append(suites.Suites, suite)
+	}
+
+	for _, snapshot := range report.Snapshots {
+		suite := junitTestSuite{Name: fmt.Sprintf("snapshot-%d", snapshot.ID)}
+		if snapshot.Run > 0 {
+			suite.Name = fmt.Sprintf("snapshot-r%d-%d", snapshot.Run+1, snapshot.ID)
+		}
+		for _, result := range snapshot.Results {
+			tc := junitTestCase{Name: result.Name, ClassName: suite.Name}
+			if !result.Valid {
+				tc.Failure = &junitFailure{Message: result.Message, Text: fmt.Sprintf("%+v", snapshot.Processes)}
+				suite.Failures++
+			}
+			suite.Tests++
+			suite.TestCases = append(suite.TestCases, tc)
+		}
+		suites.Suites = append(suites.Suites, suite)
+	}
+
+	if len(report.Sequence) > 0 {
+		suite := junitTestSuite{Name: "temporal"}
+		for _, result := range report.Sequence {
+			tc := junitTestCase{Name: result.Name, ClassName: suite.Name}
+			if len(result.Violations) > 0 {
+				text := ""
+				for _, violation := range result.Violations {
+					text += violation.Message + "\n"
+				}
+				tc.Failure = &junitFailure{Message: result.Violations[0].Message, Text: text}
+				suite.Failures++
+			}
+			suite.Tests++
+			suite.TestCases = append(suite.TestCases, tc)
+		}
+		suites.Suites = append(suites.Suites, suite)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
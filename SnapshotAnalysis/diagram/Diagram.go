@@ -0,0 +1,350 @@
+package diagram
+
+import (
+	"SnapshotAnalysis/analysis"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Point e' um evento na linha de um processo; Col e' a posicao no eixo do tempo
+type Point struct {
+	Proc int
+	Col  int
+}
+
+// Arrow e' uma mensagem entre dois processos
+type Arrow struct {
+	From      Point
+	To        Point
+	Label     string
+	Kind      string // reqEntry, respOk, msgSnapshot ...
+	InTransit bool   // enviada antes do corte do emissor e recebida depois do corte do receptor
+	Counted   bool   // em transito e contada pela invariante 4
+}
+
+// Diagram e' o modelo do diagrama espaco-tempo, independente do formato de saida
+type Diagram struct {
+	Title  string
+	Procs  []int
+	Cols   int            // numero de colunas (tempo)
+	Cut    map[int]int    // processo -> coluna do corte
+	States map[int]string // processo -> estado gravado no snapshot
+	Events []Point
+	Arrows []Arrow
+}
+
+// FromSnapshot monta o diagrama so com o snapshot: cada processo tem o estado
+// gravado no corte, e as mensagens em transito cruzam o corte
+func FromSnapshot(snapshot analysis.Snapshot) Diagram {
+	d := newDiagram(snapshot)
+	d.Cols = 4
+	for _, p := range d.Procs {
+		d.Cut[p] = 2
+	}
+
+	// varias mensagens para o mesmo processo chegam em colunas seguidas
+	for _, receiver := range snapshot.Processes {
+		for i, msg := range receiver.Messages {
+			sender, ok := msgField(msg, 1)
+			if !ok {
+				continue
+			}
+			d.Arrows = append(d.Arrows, Arrow{
+				From:      Point{Proc: sender, Col: 1},
+				To:        Point{Proc: receiver.ID, Col: 3 + i},
+				Label:     msg,
+				Kind:      msgKind(msg),
+				InTransit: true,
+				Counted:   countedByInv4(snapshot, sender, receiver.ID, msg),
+			})
+			if 4+i > d.Cols {
+				d.Cols = 4 + i
+			}
+		}
+	}
+	return d
+}
+
+// FromTrace monta o diagrama com o trace de mensagens ao redor do snapshot.
+// O corte de cada processo e' o primeiro envio de msgSnapshot com o id do snapshot
+// (o processo grava o estado e logo envia o pedido aos outros). window limita o
+// numero de eventos antes e depois do corte (0 = todos).
+func FromTrace(snapshot analysis.Snapshot, events []TraceEvent, window int) (Diagram, error) {
+	d := newDiagram(snapshot)
+
+	// posicao global (coluna) de cada evento, e corte de cada processo
+	cutIndex := make(map[int]int)
+	for i, ev := range events {
+		if ev.Dir != Send || msgKind(ev.Payload) != "msgSnapshot" {
+			continue
+		}
+		if id, ok := msgField(ev.Payload, 2); ok && id == snapshot.ID {
+			if _, seen := cutIndex[ev.Proc]; !seen {
+				cutIndex[ev.Proc] = i
+			}
+		}
+	}
+	if len(cutIndex) == 0 {
+		return d, fmt.Errorf("trace não contém o snapshot %d", snapshot.ID)
+	}
+
+	first, last := 0, len(events)-1
+	if window > 0 {
+		minCut, maxCut := len(events), 0
+		for _, i := range cutIndex {
+			if i < minCut {
+				minCut = i
+			}
+			if i > maxCut {
+				maxCut = i
+			}
+		}
+		if minCut-window > first {
+			first = minCut - window
+		}
+		if maxCut+window < last {
+			last = maxCut + window
+		}
+	}
+	col := func(i int) int { return 2*(i-first) + 1 } // colunas pares ficam para os cortes
+
+	// endereco local -> processo, para saber o destino dos envios
+	procOf := make(map[string]int)
+	for _, ev := range events {
+		procOf[ev.Local] = ev.Proc
+	}
+
+	// casa cada envio com a primeira recepcao igual ainda livre no destino (canais FIFO)
+	used := make([]bool, len(events))
+	for i, ev := range events {
+		if ev.Dir != Send {
+			continue
+		}
+		to, ok := procOf[ev.Peer]
+		if !ok {
+			continue
+		}
+		for j := i + 1; j < len(events); j++ {
+			rv := events[j]
+			if used[j] || rv.Dir != Recv || rv.Proc != to || rv.Payload != ev.Payload {
+				continue
+			}
+			used[j] = true
+			if j < first || i > last {
+				break // fora da janela
+			}
+			sendCut, okS := cutIndex[ev.Proc]
+			recvCut, okR := cutIndex[rv.Proc]
+			inTransit := okS && okR && i < sendCut && j > recvCut
+			d.Arrows = append(d.Arrows, Arrow{
+				From:      Point{Proc: ev.Proc, Col: col(clamp(i, first, last))},
+				To:        Point{Proc: rv.Proc, Col: col(clamp(j, first, last))},
+				Label:     ev.Payload,
+				Kind:      msgKind(ev.Payload),
+				InTransit: inTransit,
+				Counted:   inTransit && countedByInv4(snapshot, ev.Proc, rv.Proc, ev.Payload),
+			})
+			break
+		}
+	}
+
+	for i := first; i <= last; i++ {
+		d.Events = append(d.Events, Point{Proc: events[i].Proc, Col: col(i)})
+	}
+	for p, i := range cutIndex {
+		d.Cut[p] = col(clamp(i, first, last)) - 1 // logo antes do envio de msgSnapshot
+	}
+	d.Cols = col(last) + 2
+	return d, nil
+}
+
+func newDiagram(snapshot analysis.Snapshot) Diagram {
+	d := Diagram{
+		Title:  "snapshot " + snapshot.Key().Label(),
+		Cut:    make(map[int]int),
+		States: make(map[int]string),
+	}
+	for _, process := range snapshot.Processes {
+		d.Procs = append(d.Procs, process.ID)
+		d.States[process.ID] = fmt.Sprintf("%s w=%s lcl=%d ts=%d resps=%d",
+			process.State, process.Waiting, process.Lcl, process.ReqTs, process.NbrResps)
+	}
+	sort.Ints(d.Procs)
+	return d
+}
+
+// countedByInv4 repete o criterio da invariante 4: respOk em transito para um processo
+// em wantMX, ou reqEntry em transito de um processo em wantMX
+func countedByInv4(snapshot analysis.Snapshot, sender, receiver int, msg string) bool {
+	state := func(id int) analysis.State {
+		for _, process := range snapshot.Processes {
+			if process.ID == id {
+				return process.State
+			}
+		}
+		return analysis.NoMX
+	}
+	switch msgKind(msg) {
+	case "respOk":
+		return state(receiver) == analysis.WantMX
+	case "reqEntry":
+		return sender != receiver && state(sender) == analysis.WantMX
+	}
+	return false
+}
+
+func clamp(i, lo, hi int) int {
+	if i < lo {
+		return lo
+	}
+	if i > hi {
+		return hi
+	}
+	return i
+}
+
+// ------------------------------------------------------------------------------------
+// ------- saida DOT e SVG
+// ------------------------------------------------------------------------------------
+
+const (
+	colWidth  = 40
+	rowHeight = 90
+	marginX   = 90
+	marginY   = 50
+)
+
+func (d Diagram) x(col int) int { return marginX + col*colWidth }
+func (d Diagram) y(proc int) int {
+	for i, p := range d.Procs {
+		if p == proc {
+			return marginY + i*rowHeight
+		}
+	}
+	return marginY
+}
+
+func arrowColor(a Arrow) string {
+	switch {
+	case a.Counted:
+		return "red"
+	case a.InTransit:
+		return "orange"
+	case a.Kind == "reqEntry":
+		return "blue"
+	case a.Kind == "respOk":
+		return "darkgreen"
+	}
+	return "gray40"
+}
+
+// DOT gera o diagrama em Graphviz com posicoes fixas; renderizar com
+// "neato -n2 -Tsvg" para respeitar as posicoes
+func (d Diagram) DOT() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph %q {\n", d.Title)
+	b.WriteString("  node [shape=point width=0.08];\n  edge [arrowsize=0.6 fontsize=9];\n")
+	fmt.Fprintf(&b, "  label=%q; labelloc=t;\n", d.Title)
+
+	// linhas dos processos (y invertido: graphviz cresce para cima)
+	height := marginY*2 + len(d.Procs)*rowHeight
+	pos := func(col, proc int) string {
+		return fmt.Sprintf("%d,%d!", d.x(col), height-d.y(proc))
+	}
+	for _, p := range d.Procs {
+		fmt.Fprintf(&b, "  p%d_start [shape=plaintext label=\"P%d\" pos=%q];\n", p, p, pos(-1, p))
+		fmt.Fprintf(&b, "  p%d_end [shape=none label=\"\" pos=%q];\n", p, pos(d.Cols, p))
+		fmt.Fprintf(&b, "  p%d_start -> p%d_end [arrowhead=none penwidth=2];\n", p, p)
+	}
+
+	// corte: um no por processo ligados por linha tracejada
+	for i, p := range d.Procs {
+		fmt.Fprintf(&b, "  cut_%d [shape=square width=0.12 color=purple xlabel=%q pos=%q];\n",
+			p, d.States[p], pos(d.Cut[p], p))
+		if i > 0 {
+			fmt.Fprintf(&b, "  cut_%d -> cut_%d [arrowhead=none style=dashed color=purple penwidth=2];\n", d.Procs[i-1], p)
+		}
+	}
+
+	for i, ev := range d.Events {
+		fmt.Fprintf(&b, "  e%d [pos=%q];\n", i, pos(ev.Col, ev.Proc))
+	}
+
+	for i, a := range d.Arrows {
+		fmt.Fprintf(&b, "  m%d_from [shape=point width=0.05 pos=%q];\n", i, pos(a.From.Col, a.From.Proc))
+		fmt.Fprintf(&b, "  m%d_to [shape=point width=0.05 pos=%q];\n", i, pos(a.To.Col, a.To.Proc))
+		style := "solid"
+		if a.Kind == "msgSnapshot" {
+			style = "dashed"
+		}
+		width := 1
+		if a.Counted {
+			width = 3
+		}
+		fmt.Fprintf(&b, "  m%d_from -> m%d_to [label=%q color=%s fontcolor=%s style=%s penwidth=%d];\n",
+			i, i, a.Label, arrowColor(a), arrowColor(a), style, width)
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// SVG gera o diagrama diretamente, sem depender do graphviz
+func (d Diagram) SVG() string {
+	width := d.x(d.Cols) + marginX
+	height := marginY*2 + len(d.Procs)*rowHeight
+	var b strings.Builder
+	fmt.Fprintf(&b, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" font-family=\"monospace\" font-size=\"10\">\n", width, height)
+	b.WriteString("<defs>\n")
+	for _, c := range []string{"red", "orange", "blue", "darkgreen", "gray"} {
+		fmt.Fprintf(&b, "  <marker id=\"arrow-%s\" viewBox=\"0 0 10 10\" refX=\"10\" refY=\"5\" markerWidth=\"6\" markerHeight=\"6\" orient=\"auto\"><path d=\"M0,0 L10,5 L0,10 z\" fill=\"%s\"/></marker>\n", c, c)
+	}
+	b.WriteString("</defs>\n")
+	fmt.Fprintf(&b, "<text x=\"10\" y=\"20\" font-size=\"14\">%s</text>\n", escape(d.Title))
+
+	for _, p := range d.Procs {
+		y := d.y(p)
+		fmt.Fprintf(&b, "<text x=\"10\" y=\"%d\" font-size=\"12\">P%d</text>\n", y+4, p)
+		fmt.Fprintf(&b, "<line x1=\"%d\" y1=\"%d\" x2=\"%d\" y2=\"%d\" stroke=\"black\" stroke-width=\"2\"/>\n", d.x(0), y, d.x(d.Cols), y)
+	}
+	for _, ev := range d.Events {
+		fmt.Fprintf(&b, "<circle cx=\"%d\" cy=\"%d\" r=\"3\" fill=\"black\"/>\n", d.x(ev.Col), d.y(ev.Proc))
+	}
+
+	for _, a := range d.Arrows {
+		color := arrowColor(a)
+		if color == "gray40" {
+			color = "gray"
+		}
+		dash := ""
+		if a.Kind == "msgSnapshot" {
+			dash = " stroke-dasharray=\"4,3\""
+		}
+		width := 1
+		if a.Counted {
+			width = 3
+		}
+		x1, y1, x2, y2 := d.x(a.From.Col), d.y(a.From.Proc), d.x(a.To.Col), d.y(a.To.Proc)
+		fmt.Fprintf(&b, "<line x1=\"%d\" y1=\"%d\" x2=\"%d\" y2=\"%d\" stroke=\"%s\" stroke-width=\"%d\"%s marker-end=\"url(#arrow-%s)\"/>\n",
+			x1, y1, x2, y2, color, width, dash, color)
+		fmt.Fprintf(&b, "<text x=\"%d\" y=\"%d\" fill=\"%s\">%s</text>\n", (x1+x2)/2+3, (y1+y2)/2-3, color, escape(a.Label))
+	}
+
+	// corte
+	var points []string
+	for _, p := range d.Procs {
+		points = append(points, fmt.Sprintf("%d,%d", d.x(d.Cut[p]), d.y(p)))
+	}
+	fmt.Fprintf(&b, "<polyline points=\"%s\" fill=\"none\" stroke=\"purple\" stroke-width=\"2\" stroke-dasharray=\"6,4\"/>\n", strings.Join(points, " "))
+	for _, p := range d.Procs {
+		x, y := d.x(d.Cut[p]), d.y(p)
+		fmt.Fprintf(&b, "<rect x=\"%d\" y=\"%d\" width=\"8\" height=\"8\" fill=\"purple\"/>\n", x-4, y-4)
+		fmt.Fprintf(&b, "<text x=\"%d\" y=\"%d\" fill=\"purple\">%s</text>\n", x+6, y+16, escape(d.States[p]))
+	}
+	b.WriteString("</svg>\n")
+	return b.String()
+}
+
+func escape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\"", "&quot;").Replace(s)
+}
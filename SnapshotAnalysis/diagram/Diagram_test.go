@@ -0,0 +1,121 @@
+package diagram
+
+import (
+	"SnapshotAnalysis/analysis"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// P0 pediu a SC e a resposta de P1 ainda estava em transito no corte
+func pendingReply() analysis.Snapshot {
+	return analysis.Snapshot{ID: 0, Processes: []analysis.ProcessState{
+		{ID: 0, State: analysis.WantMX, Waiting: "00", Lcl: 2, ReqTs: 1, Messages: []string{"respOk,1"}},
+		{ID: 1, State: analysis.NoMX, Waiting: "00", Lcl: 3},
+	}}
+}
+
+func assertContains(t *testing.T, dot string, parts ...string) {
+	t.Helper()
+	for _, part := range parts {
+		if !strings.Contains(dot, part) {
+			t.Errorf("DOT sem %q:\n%s", part, dot)
+		}
+	}
+}
+
+func TestFromSnapshotDOT(t *testing.T) {
+	d := FromSnapshot(pendingReply())
+	if len(d.Arrows) != 1 {
+		t.Fatalf("%d mensagens, esperada 1: %+v", len(d.Arrows), d.Arrows)
+	}
+	a := d.Arrows[0]
+	if a.From.Proc != 1 || a.To.Proc != 0 || !a.InTransit || !a.Counted {
+		t.Fatalf("mensagem %+v, esperado respOk de P1 para P0 em transito e contado pela invariante 4", a)
+	}
+
+	// altura 50*2 + 2*90 = 280; P0 em y=280-50, P1 em y=280-140; corte na coluna 2 (x=90+2*40)
+	assertContains(t, d.DOT(),
+		`digraph "snapshot 0" {`,
+		`p0_start [shape=plaintext label="P0" pos="50,230!"];`,
+		`p1_start [shape=plaintext label="P1" pos="50,140!"];`,
+		`p0_start -> p0_end [arrowhead=none penwidth=2];`,
+		`cut_0 [shape=square width=0.12 color=purple xlabel="wantMX w=00 lcl=2 ts=1 resps=0" pos="170,230!"];`,
+		`cut_1 [shape=square width=0.12 color=purple xlabel="noMX w=00 lcl=3 ts=0 resps=0" pos="170,140!"];`,
+		`cut_0 -> cut_1 [arrowhead=none style=dashed color=purple penwidth=2];`,
+		`m0_from [shape=point width=0.05 pos="130,140!"];`,
+		`m0_to [shape=point width=0.05 pos="210,230!"];`,
+		`m0_from -> m0_to [label="respOk,1" color=red fontcolor=red style=solid penwidth=3];`)
+}
+
+func TestFromTraceDOT(t *testing.T) {
+	// P1 inicia o snapshot depois que o reqEntry de P0 saiu e antes de ele chegar
+	events := []TraceEvent{
+		{Proc: 0, Dir: Send, Local: "a0", Peer: "a1", Payload: "reqEntry,0,1"},
+		{Proc: 1, Dir: Send, Local: "a1", Peer: "a0", Payload: "msgSnapshot,1,0"}, // corte de P1
+		{Proc: 1, Dir: Recv, Local: "a1", Peer: "a0", Payload: "reqEntry,0,1"},
+		{Proc: 0, Dir: Recv, Local: "a0", Peer: "a1", Payload: "msgSnapshot,1,0"},
+		{Proc: 0, Dir: Send, Local: "a0", Peer: "a1", Payload: "msgSnapshot,0,0"}, // corte de P0
+		{Proc: 1, Dir: Recv, Local: "a1", Peer: "a0", Payload: "msgSnapshot,0,0"},
+	}
+	snapshot := analysis.Snapshot{ID: 0, Processes: []analysis.ProcessState{
+		{ID: 0, State: analysis.WantMX, Waiting: "00", Lcl: 1, ReqTs: 1},
+		{ID: 1, State: analysis.NoMX, Waiting: "00", Lcl: 2, Messages: []string{"reqEntry,0,1"}},
+	}}
+	d, err := FromTrace(snapshot, events, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// evento i na coluna 2i+1; o corte fica na coluna anterior ao envio de msgSnapshot
+	if d.Cut[0] != 8 || d.Cut[1] != 2 || len(d.Events) != len(events) {
+		t.Fatalf("cortes %v e %d eventos, esperados P0=8 P1=2 e %d", d.Cut, len(d.Events), len(events))
+	}
+	want := []Arrow{
+		{From: Point{0, 1}, To: Point{1, 5}, Label: "reqEntry,0,1", Kind: "reqEntry", InTransit: true, Counted: true},
+		{From: Point{1, 3}, To: Point{0, 7}, Label: "msgSnapshot,1,0", Kind: "msgSnapshot"},
+		{From: Point{0, 9}, To: Point{1, 11}, Label: "msgSnapshot,0,0", Kind: "msgSnapshot"},
+	}
+	if len(d.Arrows) != len(want) {
+		t.Fatalf("mensagens %+v, esperadas %+v", d.Arrows, want)
+	}
+	for i := range want {
+		if d.Arrows[i] != want[i] {
+			t.Errorf("mensagem %d: %+v, esperada %+v", i, d.Arrows[i], want[i])
+		}
+	}
+
+	assertContains(t, d.DOT(),
+		`cut_0 -> cut_1 [arrowhead=none style=dashed color=purple penwidth=2];`,
+		`m0_from -> m0_to [label="reqEntry,0,1" color=red fontcolor=red style=solid penwidth=3];`,
+		`m1_from -> m1_to [label="msgSnapshot,1,0" color=gray40 fontcolor=gray40 style=dashed penwidth=1];`,
+		`e5 [pos=`)
+
+	if _, err := FromTrace(analysis.Snapshot{ID: 7, Processes: snapshot.Processes}, events, 0); err == nil {
+		t.Error("trace sem o snapshot 7 aceito")
+	}
+}
+
+// o trace do DIMEX tambem grava as entradas do modulo (APP, DLVR): so as mensagens entram
+func TestReadTraceSkipsInputs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trace_proc_0.txt")
+	lines := "1\tAPP\ta0\tapp\t\"ENTER\"\n" +
+		"2\tSEND\ta0\ta1\t\"reqEntry,0,1\"\n" +
+		"3\tRECV\ta0\ta1\t\"respOk,1\"\n" +
+		"4\tDLVR\ta0\ta1\t\"respOk,1\"\n"
+	if err := os.WriteFile(path, []byte(lines), 0644); err != nil {
+		t.Fatal(err)
+	}
+	events, err := ReadTrace([]string{path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].Dir != Send || events[1].Dir != Recv {
+		t.Fatalf("eventos %+v, esperados so SEND e RECV", events)
+	}
+
+	if _, err := ParseTraceLine("5\tXYZ\ta0\ta1\t\"x\"", 0); err == nil {
+		t.Error("direcao desconhecida aceita")
+	}
+}
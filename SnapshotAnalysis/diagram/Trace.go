@@ -0,0 +1,138 @@
+// Package diagram desenha snapshots globais (e opcionalmente o trace de mensagens)
+// como diagramas espaco-tempo, em Graphviz DOT ou SVG.
+package diagram
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// TraceEvent e' uma linha do trace gravado pelo PP2PLink:
+//
+//	<unixnano>\t<SEND|RECV|APP|DLVR>\t<endereco local>\t<endereco do par>\t<payload entre aspas (strconv.Quote)>
+//
+// APP e DLVR (entradas do DIMEX, usadas pelo replay) nao sao mensagens: ReadTrace os descarta.
+type TraceEvent struct {
+	Time    int64
+	Proc    int // processo dono do arquivo (trace_proc_<id>.txt)
+	Dir     string
+	Local   string
+	Peer    string
+	Payload string
+}
+
+// maior linha de trace: um quadro de 16MB do PP2PLink entre aspas (strconv.Quote
+// usa ate 4 bytes por byte do payload) mais os outros campos
+const maxTraceLine = 4*(16<<20) + 4096
+
+const (
+	Send    = "SEND"
+	Recv    = "RECV"
+	App     = "APP"
+	Deliver = "DLVR"
+)
+
+var traceFileRe = regexp.MustCompile(`^trace_proc_(\d+)\.txt$`)
+
+// TraceFiles lista os arquivos trace_proc_<id>.txt do diretorio dir
+func TraceFiles(dir string) ([]string, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler o diretório: %v", err)
+	}
+	var paths []string
+	for _, file := range files {
+		if !file.IsDir() && traceFileRe.MatchString(file.Name()) {
+			paths = append(paths, filepath.Join(dir, file.Name()))
+		}
+	}
+	return paths, nil
+}
+
+// ParseTraceLine interpreta uma linha de trace do processo proc
+func ParseTraceLine(line string, proc int) (TraceEvent, error) {
+	parts := strings.SplitN(line, "\t", 5)
+	if len(parts) != 5 {
+		return TraceEvent{}, fmt.Errorf("formato inválido da linha de trace: %s", line)
+	}
+	ts, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return TraceEvent{}, fmt.Errorf("erro ao parsear timestamp: %v", err)
+	}
+	if parts[1] != Send && parts[1] != Recv && parts[1] != App && parts[1] != Deliver {
+		return TraceEvent{}, fmt.Errorf("direção desconhecida: %s", parts[1])
+	}
+	payload, err := strconv.Unquote(parts[4])
+	if err != nil {
+		return TraceEvent{}, fmt.Errorf("erro ao parsear payload %s: %v", parts[4], err)
+	}
+	return TraceEvent{
+		Time:    ts,
+		Proc:    proc,
+		Dir:     parts[1],
+		Local:   parts[2],
+		Peer:    parts[3],
+		Payload: payload,
+	}, nil
+}
+
+// ReadTrace le os arquivos de trace e devolve os eventos em ordem de tempo
+func ReadTrace(paths []string) ([]TraceEvent, error) {
+	var events []TraceEvent
+	for _, path := range paths {
+		match := traceFileRe.FindStringSubmatch(filepath.Base(path))
+		if match == nil {
+			return nil, fmt.Errorf("arquivo %s fora do formato trace_proc_<id>.txt", path)
+		}
+		proc, _ := strconv.Atoi(match[1])
+
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("não foi possível abrir o arquivo %s: %v", path, err)
+		}
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 64*1024), maxTraceLine)
+		lineNumber := 0
+		for scanner.Scan() {
+			lineNumber++
+			if strings.TrimSpace(scanner.Text()) == "" {
+				continue
+			}
+			ev, err := ParseTraceLine(scanner.Text(), proc)
+			if err != nil {
+				f.Close()
+				return nil, fmt.Errorf("%s:%d: %v", path, lineNumber, err)
+			}
+			if ev.Dir == Send || ev.Dir == Recv {
+				events = append(events, ev)
+			}
+		}
+		f.Close()
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("erro ao ler o arquivo %s: %v", path, err)
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Time < events[j].Time })
+	return events, nil
+}
+
+// msgKind devolve o tipo da mensagem DIMEX (primeiro campo do payload)
+func msgKind(payload string) string {
+	return strings.SplitN(payload, ",", 2)[0]
+}
+
+// msgField devolve o campo i (separado por virgulas) do payload como inteiro
+func msgField(payload string, i int) (int, bool) {
+	fields := strings.Split(payload, ",")
+	if i >= len(fields) {
+		return 0, false
+	}
+	n, err := strconv.Atoi(fields[i])
+	return n, err == nil
+}
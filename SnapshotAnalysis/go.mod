@@ -0,0 +1,3 @@
+module SnapshotAnalysis
+
+go 1.18